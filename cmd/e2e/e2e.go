@@ -7,6 +7,7 @@ import (
 
 	"github.com/jespino/mmdev/pkg/docker"
 	"github.com/jespino/mmdev/pkg/e2e"
+	"github.com/jespino/mmdev/pkg/node"
 	"github.com/spf13/cobra"
 )
 
@@ -42,9 +43,26 @@ func PlaywrightCmd() *cobra.Command {
 
 func PlaywrightRunCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "run",
+		Use:   "run [spec...]",
 		Short: "Run Playwright E2E tests",
+		Long: `Run the Playwright E2E tests in a container, all of them or the given spec
+files or directories, from the repository root or e2e-tests/playwright.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := e2e.PlaywrightOptions{Specs: args}
+			opts.Grep, _ = cmd.Flags().GetString("grep")
+			opts.Projects, _ = cmd.Flags().GetStringSlice("project")
+			opts.Workers, _ = cmd.Flags().GetString("workers")
+			opts.Shard, _ = cmd.Flags().GetString("shard")
+			opts.UpdateSnapshots, _ = cmd.Flags().GetBool("update-snapshots")
+			opts.Headed, _ = cmd.Flags().GetBool("headed")
+			if cmd.Flags().Changed("retries") {
+				retries, _ := cmd.Flags().GetInt("retries")
+				opts.Retries = &retries
+			}
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
 			// Ensure Docker image is available
 			dockerManager, err := docker.NewManager()
 			if err != nil {
@@ -59,9 +77,17 @@ func PlaywrightRunCmd() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("failed to create playwright runner: %w", err)
 			}
+			runner.SetOptions(opts)
 			return runner.RunTests()
 		},
 	}
+	cmd.Flags().String("grep", "", "Only run the tests whose title matches this regular expression")
+	cmd.Flags().StringSlice("project", nil, "Only run the tests of these Playwright projects (e.g. chrome,firefox)")
+	cmd.Flags().String("workers", "", "Number of parallel workers, or a percentage of the CPU cores like 50%")
+	cmd.Flags().String("shard", "", "Only run a part of the tests, like 1/3")
+	cmd.Flags().Int("retries", 0, "Number of retries of the failed tests (default: the Playwright configuration)")
+	cmd.Flags().Bool("update-snapshots", false, "Update the snapshots that don't match")
+	cmd.Flags().Bool("headed", false, "Run the browsers headed, on a virtual display of the container")
 	return cmd
 }
 
@@ -77,19 +103,13 @@ func PlaywrightUICmd() *cobra.Command {
 
 			// Run npm install if needed
 			if _, err := os.Stat("node_modules"); os.IsNotExist(err) {
-				installCmd := exec.Command("bash", "-c", "source ~/.nvm/nvm.sh && nvm use && npm install")
-				installCmd.Stdout = os.Stdout
-				installCmd.Stderr = os.Stderr
-				if err := installCmd.Run(); err != nil {
+				if err := runNpm("install"); err != nil {
 					return fmt.Errorf("failed to install dependencies: %w", err)
 				}
 			}
 
 			// Run playwright UI
-			runCmd := exec.Command("bash", "-c", "source ~/.nvm/nvm.sh && nvm use && npm run playwright-ui")
-			runCmd.Stdout = os.Stdout
-			runCmd.Stderr = os.Stderr
-			return runCmd.Run()
+			return runNpm("run", "playwright-ui")
 		},
 	}
 	return cmd
@@ -146,19 +166,13 @@ func CypressRunCmd() *cobra.Command {
 
 			// Run npm install if needed
 			if _, err := os.Stat("node_modules"); os.IsNotExist(err) {
-				installCmd := exec.Command("bash", "-c", "source ~/.nvm/nvm.sh && nvm use && npm install")
-				installCmd.Stdout = os.Stdout
-				installCmd.Stderr = os.Stderr
-				if err := installCmd.Run(); err != nil {
+				if err := runNpm("install"); err != nil {
 					return fmt.Errorf("failed to install dependencies: %w", err)
 				}
 			}
 
 			// Run cypress tests
-			runCmd := exec.Command("bash", "-c", "source ~/.nvm/nvm.sh && nvm use && npm run cypress:run")
-			runCmd.Stdout = os.Stdout
-			runCmd.Stderr = os.Stderr
-			return runCmd.Run()
+			return runNpm("run", "cypress:run")
 		},
 	}
 	return cmd
@@ -176,19 +190,13 @@ func CypressUICmd() *cobra.Command {
 
 			// Run npm install if needed
 			if _, err := os.Stat("node_modules"); os.IsNotExist(err) {
-				installCmd := exec.Command("bash", "-c", "source ~/.nvm/nvm.sh && nvm use && npm install")
-				installCmd.Stdout = os.Stdout
-				installCmd.Stderr = os.Stderr
-				if err := installCmd.Run(); err != nil {
+				if err := runNpm("install"); err != nil {
 					return fmt.Errorf("failed to install dependencies: %w", err)
 				}
 			}
 
 			// Run cypress UI
-			runCmd := exec.Command("bash", "-c", "source ~/.nvm/nvm.sh && nvm use && npm run cypress:open")
-			runCmd.Stdout = os.Stdout
-			runCmd.Stderr = os.Stderr
-			return runCmd.Run()
+			return runNpm("run", "cypress:open")
 		},
 	}
 	return cmd
@@ -216,3 +224,17 @@ func CypressReportCmd() *cobra.Command {
 	}
 	return cmd
 }
+
+// runNpm runs npm in the current directory with the Node version it requires
+func runNpm(args ...string) error {
+	rt, err := node.Resolve(".")
+	if err != nil {
+		return fmt.Errorf("failed to find node: %w", err)
+	}
+
+	cmd := rt.Command(".", "npm", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -5,9 +5,10 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/spf13/cobra"
+	"github.com/jespino/mmdev/pkg/mmlog"
 	"github.com/jespino/mmdev/pkg/plugins/manifest"
 	"github.com/jespino/mmdev/pkg/plugins/pluginctl"
+	"github.com/spf13/cobra"
 )
 
 func NewCommand() *cobra.Command {
@@ -88,6 +89,11 @@ var newCmd = &cobra.Command{
 	RunE:  runNew,
 }
 
+	for _, c := range []*cobra.Command{logsCmd, watchCmd} {
+		c.Flags().String("log-level", "", "Only show logs at or above this level (trace, debug, info, warn, error)")
+		c.Flags().String("grep", "", "Only show log lines matching this regular expression")
+	}
+
 	cmd.AddCommand(deployCmd)
 	cmd.AddCommand(disableCmd)
 	cmd.AddCommand(enableCmd)
@@ -142,20 +148,40 @@ func runReset(cmd *cobra.Command, args []string) error {
 	return client.Reset(cmd.Context(), args[0])
 }
 
+// logOptions builds the log filtering options from the command flags
+func logOptions(cmd *cobra.Command) (mmlog.Options, error) {
+	level, _ := cmd.Flags().GetString("log-level")
+	grep, _ := cmd.Flags().GetString("grep")
+
+	opts := mmlog.Options{Level: level}
+	if err := opts.SetGrep(grep); err != nil {
+		return opts, err
+	}
+	return opts, opts.Validate()
+}
+
 func runLogs(cmd *cobra.Command, args []string) error {
+	opts, err := logOptions(cmd)
+	if err != nil {
+		return err
+	}
 	client, err := getClient()
 	if err != nil {
 		return err
 	}
-	return client.GetLogs(cmd.Context(), args[0])
+	return client.GetLogs(cmd.Context(), args[0], opts)
 }
 
 func runWatch(cmd *cobra.Command, args []string) error {
+	opts, err := logOptions(cmd)
+	if err != nil {
+		return err
+	}
 	client, err := getClient()
 	if err != nil {
 		return err
 	}
-	return client.WatchLogs(context.Background(), args[0])
+	return client.WatchLogs(context.Background(), args[0], opts)
 }
 
 func runNew(cmd *cobra.Command, args []string) error {
@@ -3,8 +3,11 @@ package server
 import (
 	"fmt"
 	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/jespino/mmdev/pkg/generator"
+	"github.com/jespino/mmdev/pkg/gitchanges"
 	"github.com/spf13/cobra"
 )
 
@@ -14,10 +17,15 @@ func GenerateCmd() *cobra.Command {
 		Short: "Code generation commands",
 	}
 
+	cmd.PersistentFlags().Bool("check", false, "Fail if the generated code is stale instead of writing it")
+	cmd.PersistentFlags().Bool("dry-run", false, "Show a diff of the generated code instead of writing it")
+	cmd.PersistentFlags().Int("parallel", 4, "Number of generators to run at the same time (1 runs them strictly one after the other)")
+
 	cmd.AddCommand(
 		LayersCmd(),
 		MocksCmd(),
 		AllCmd(),
+		ListCmd(),
 	)
 
 	return cmd
@@ -25,29 +33,10 @@ func GenerateCmd() *cobra.Command {
 
 func LayersCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "layers",
-		Short: "Generate all layer code",
+		Use:   "layers [names...]",
+		Short: "Generate layer code, all of it or only the named generators",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			serverDir := "./server"
-			if _, err := os.Stat(serverDir); os.IsNotExist(err) {
-				return fmt.Errorf("server directory not found at %s", serverDir)
-			}
-
-			manager := generator.NewManager(serverDir)
-
-			if err := manager.GenerateAppLayers(); err != nil {
-				return fmt.Errorf("failed to generate app layers: %w", err)
-			}
-
-			if err := manager.GenerateStoreLayers(); err != nil {
-				return fmt.Errorf("failed to generate store layers: %w", err)
-			}
-
-			if err := manager.GeneratePluginAPI(); err != nil {
-				return fmt.Errorf("failed to generate plugin API: %w", err)
-			}
-
-			return nil
+			return runGroup(cmd, generator.GroupLayers, args)
 		},
 	}
 	return cmd
@@ -55,20 +44,35 @@ func LayersCmd() *cobra.Command {
 
 func MocksCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "mocks",
-		Short: "Generate all mock files",
+		Use:   "mocks [names...]",
+		Short: "Generate mock files, all of them or only the named generators",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			serverDir := "./server"
-			if _, err := os.Stat(serverDir); os.IsNotExist(err) {
-				return fmt.Errorf("server directory not found at %s", serverDir)
-			}
+			return runGroup(cmd, generator.GroupMocks, args)
+		},
+	}
+	return cmd
+}
 
-			manager := generator.NewManager(serverDir)
-			if err := manager.GenerateMocks(); err != nil {
-				return fmt.Errorf("failed to generate mocks: %w", err)
+func ListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the available generators and where they are defined",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manager, err := newGeneratorManager()
+			if err != nil {
+				return err
+			}
+			targets, err := manager.Targets()
+			if err != nil {
+				return err
 			}
 
-			return nil
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "GENERATOR\tSOURCE\tDEPENDS ON")
+			for _, target := range targets {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", target.ID(), target.Source, strings.Join(target.DependsOn, ", "))
+			}
+			return w.Flush()
 		},
 	}
 	return cmd
@@ -78,32 +82,117 @@ func AllCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "all",
 		Short: "Generate all code (layers and mocks)",
+		Long: `Run every layer and mock generator. Use --changed to run only the generators
+affected by the files changed since the merge base with the main branch.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			serverDir := "./server"
-			if _, err := os.Stat(serverDir); os.IsNotExist(err) {
-				return fmt.Errorf("server directory not found at %s", serverDir)
+			manager, err := newGeneratorManager()
+			if err != nil {
+				return err
 			}
 
-			manager := generator.NewManager(serverDir)
-
-			if err := manager.GenerateAppLayers(); err != nil {
-				return fmt.Errorf("failed to generate app layers: %w", err)
-			}
-
-			if err := manager.GenerateStoreLayers(); err != nil {
-				return fmt.Errorf("failed to generate store layers: %w", err)
+			targets, err := manager.Targets()
+			if err != nil {
+				return err
 			}
-
-			if err := manager.GeneratePluginAPI(); err != nil {
-				return fmt.Errorf("failed to generate plugin API: %w", err)
-			}
-
-			if err := manager.GenerateMocks(); err != nil {
-				return fmt.Errorf("failed to generate mocks: %w", err)
+			changedOnly, _ := cmd.Flags().GetBool("changed")
+			if changedOnly {
+				changed, err := gitchanges.ChangedFilesSinceMergeBase("./server")
+				if err != nil {
+					return err
+				}
+				targets = manager.Affected(targets, changed)
+				if len(targets) == 0 {
+					fmt.Println("No generators are affected by your changes")
+					return nil
+				}
 			}
 
-			return nil
+			return runTargets(cmd, manager, targets, true)
 		},
 	}
+	cmd.Flags().Bool("changed", false, "Run only the generators affected by the files changed since the merge base")
 	return cmd
 }
+
+func newGeneratorManager() (*generator.Manager, error) {
+	serverDir := "./server"
+	if _, err := os.Stat(serverDir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("server directory not found at %s", serverDir)
+	}
+	return generator.NewManager(serverDir), nil
+}
+
+// runGroup runs the named generators of a group, or the whole group
+func runGroup(cmd *cobra.Command, group string, names []string) error {
+	manager, err := newGeneratorManager()
+	if err != nil {
+		return err
+	}
+	targets, err := manager.Targets()
+	if err != nil {
+		return err
+	}
+	targets, err = generator.Select(targets, group, names)
+	if err != nil {
+		return err
+	}
+	return runTargets(cmd, manager, targets, false)
+}
+
+// runTargets runs the targets, or checks whether their output is stale when
+// --check is set, or shows the diff of their output when --dry-run is set.
+// With rollback, the generated files are restored if any target fails.
+func runTargets(cmd *cobra.Command, manager *generator.Manager, targets []generator.Target, rollback bool) error {
+	check, _ := cmd.Flags().GetBool("check")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	parallel, _ := cmd.Flags().GetInt("parallel")
+
+	if check && dryRun {
+		return fmt.Errorf("--check and --dry-run can't be used together")
+	}
+
+	ids := make([]string, 0, len(targets))
+	for _, target := range targets {
+		ids = append(ids, target.ID())
+	}
+
+	if dryRun {
+		// Keep stdout for the diff so it can be redirected to a patch file
+		manager.SetOutput(os.Stderr)
+		fmt.Fprintf(os.Stderr, "Generators: %s\n", strings.Join(ids, ", "))
+		changes, err := manager.DryRun(targets, parallel, os.Stdout)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			fmt.Fprintln(os.Stderr, "Generated code is up to date")
+		} else {
+			fmt.Fprintf(os.Stderr, "%d generated files would change (paths are relative to ./server)\n", len(changes))
+		}
+		return nil
+	}
+
+	fmt.Printf("Generators: %s\n", strings.Join(ids, ", "))
+
+	if !check {
+		if rollback {
+			return manager.RunWithRollback(targets, parallel)
+		}
+		return manager.Run(targets, parallel)
+	}
+
+	changes, err := manager.Check(targets, parallel)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Println("Generated code is up to date")
+		return nil
+	}
+
+	fmt.Println("Generated code is stale:")
+	for _, change := range changes {
+		fmt.Printf("  %-8s %s\n", change.Kind, change.Path)
+	}
+	return fmt.Errorf("%d generated files are stale, run the generators without --check to update them", len(changes))
+}
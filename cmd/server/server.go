@@ -1,23 +1,48 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
 	"os/signal"
-	"path/filepath"
 	"strings"
-	"sync"
 	"syscall"
 	"time"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/jespino/mmdev/cmd/docker"
+	"github.com/jespino/mmdev/internal/config"
+	"github.com/jespino/mmdev/pkg/gitchanges"
+	"github.com/jespino/mmdev/pkg/lintreport"
+	"github.com/jespino/mmdev/pkg/mmlog"
+	"github.com/jespino/mmdev/pkg/notify"
 	"github.com/jespino/mmdev/pkg/server"
+	"github.com/jespino/mmdev/pkg/supervisor"
+	"github.com/jespino/mmdev/pkg/watcher"
 	"github.com/spf13/cobra"
 )
 
-var watch bool
+// nextBinaryPath is where the server is built in build-first watch mode
+const nextBinaryPath = "bin/mattermost.next"
+
+var (
+	watch         bool
+	debug         bool
+	debugPort     int
+	debugAddress  string
+	serverPort    int
+	buildFirst    bool
+	watchDebounce time.Duration
+	watchInclude  []string
+	watchExclude  []string
+	logLevel      string
+	logGrep       string
+	logLogger     string
+	logCaller     string
+	logHighlight  []string
+	logOptions    mmlog.Options
+)
 
 func ServerCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -31,6 +56,7 @@ func ServerCmd() *cobra.Command {
 	cmd.AddCommand(
 		StartCmd(),
 		LintCmd(),
+		TestCmd(),
 		GenerateCmd(),
 	)
 	return cmd
@@ -46,14 +72,61 @@ func LintCmd() *cobra.Command {
 				return fmt.Errorf("server directory not found at %s", serverDir)
 			}
 
+			changed, _ := cmd.Flags().GetBool("changed")
+			format, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+			if err := lintreport.ValidateFormat(format); err != nil {
+				return err
+			}
+
 			manager := server.NewManager(serverDir)
-			if err := manager.Lint(); err != nil {
-				fmt.Printf("Linting found issues: %v\n", err)
+
+			var packages []string
+			if changed {
+				base, err := gitchanges.MergeBase(serverDir)
+				if err != nil {
+					return err
+				}
+				files, err := gitchanges.ChangedFilesSince(serverDir, base)
+				if err != nil {
+					return err
+				}
+				packages = gitchanges.GoPackages(serverDir, files)
+				if len(packages) == 0 {
+					fmt.Fprintln(os.Stderr, "No changed Go packages to lint")
+					if format == lintreport.FormatText {
+						return nil
+					}
+					return lintreport.WriteFile(output, format, "golangci-lint", nil)
+				}
+				// Lint the changed packages, reporting only the issues in the changed lines
+				manager.SetLintBase(base)
+			}
+
+			if format == lintreport.FormatText {
+				if err := manager.Lint(packages...); err != nil {
+					fmt.Printf("Linting found issues: %v\n", err)
+					os.Exit(1)
+				}
+				return nil
+			}
+
+			issues, err := manager.LintReport(packages...)
+			if err != nil {
+				return err
+			}
+			if err := lintreport.WriteFile(output, format, "golangci-lint", issues); err != nil {
+				return err
+			}
+			if len(issues) > 0 {
 				os.Exit(1)
 			}
 			return nil
 		},
 	}
+	cmd.Flags().Bool("changed", false, "Only report the issues in the code changed since the merge base with the main branch")
+	cmd.Flags().String("format", lintreport.FormatText, "Output format: text, json or sarif")
+	cmd.Flags().StringP("output", "o", "", "Write the json or sarif report to this file instead of stdout")
 	return cmd
 }
 
@@ -73,14 +146,14 @@ func runServer() error {
 	done := make(chan error, 1)
 
 	// Start server in a goroutine
-	manager := server.NewManager(".")
+	manager := newServerManager()
 	cmd, err := manager.Start()
 	if err != nil {
 		done <- err
 		return err
 	}
 	go func() {
-		done <- cmd.Wait()
+		done <- manager.Wait(cmd)
 	}()
 
 	// Wait for server completion, interrupt or restart signal
@@ -123,129 +196,157 @@ func runServer() error {
 				return fmt.Errorf("failed to restart server: %w", err)
 			}
 			go func() {
-				done <- cmd.Wait()
+				done <- manager.Wait(cmd)
 			}()
 		}
 	}
 }
 
 func runWithWatcher() error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	notifier, err := notify.New(notify.Options{
+		Bell:    !cfg.Notify.DisableBell,
+		OSC9:    cfg.Notify.OSC9,
+		Webhook: cfg.Notify.Webhook,
+		Command: cfg.Notify.Command,
+		Events:  cfg.Notify.Events,
+	}, os.Stdout)
+	if err != nil {
+		return err
+	}
+	tracker := notify.NewTracker()
+	// Sent in the background, a slow webhook must not hold the restarts
+	notifyServer := func(n notify.Notification, ok bool) {
+		if !ok {
+			return
+		}
+		go func() {
+			if err := notifier.Send(n); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to notify: %v\n", err)
+			}
+		}()
+	}
+
 	// Start docker services
 	if err := docker.StartDockerServices(); err != nil {
 		return fmt.Errorf("failed to start docker services: %w", err)
 	}
 	defer docker.StopDockerServices()
 
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return fmt.Errorf("failed to create watcher: %w", err)
-	}
-	defer watcher.Close()
-
-	// Add all directories with .go files
-	err = filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() && !strings.Contains(path, "vendor") && !strings.Contains(path, "node_modules") {
-			return watcher.Add(path)
-		}
-		return nil
+	w, err := watcher.New(".", watcher.Options{
+		Include:  watchInclude,
+		Exclude:  watchExclude,
+		Debounce: watchDebounce,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to add directories to watcher: %w", err)
+		return err
 	}
-
-	var cmd *exec.Cmd
-	var mu sync.Mutex
-	restart := make(chan struct{}, 1)
+	defer w.Close()
 
 	// Create a channel to listen for interrupt signals
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	// Start the server initially
-	cmd = startServer()
+	manager := newServerManager()
 
+	var cmd *exec.Cmd
 	// Create a channel to signal server completion
 	done := make(chan error, 1)
-	if cmd != nil {
+	// ready receives when the running server answers, to notify recoveries
+	ready := make(chan struct{})
+	cancelReady := func() {}
+	defer func() { cancelReady() }()
+
+	stopServer := func() {
+		cancelReady()
+		if cmd != nil && cmd.Process != nil {
+			if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+				fmt.Printf("Warning: failed to send SIGTERM to server: %v\n", err)
+				cmd.Process.Kill()
+			}
+			<-done // Wait for process to finish
+		}
+		cmd = nil
+	}
+
+	runAndWait := func() {
+		var err error
+		cmd, err = manager.Run()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting server: %v\n", err)
+			cmd = nil
+			notifyServer(tracker.Crashed("server", err.Error()))
+			return
+		}
+		current := cmd
 		go func() {
-			done <- cmd.Wait()
+			done <- manager.Wait(current)
+		}()
+
+		var readyCtx context.Context
+		readyCtx, cancelReady = context.WithCancel(context.Background())
+		go func() {
+			if manager.WaitReady(readyCtx) == nil {
+				select {
+				case ready <- struct{}{}:
+				case <-readyCtx.Done():
+				}
+			}
 		}()
 	}
 
-	// Debounce function to prevent multiple restarts
-	lastRestart := time.Now()
-	shouldRestart := func() bool {
-		mu.Lock()
-		defer mu.Unlock()
-		if time.Since(lastRestart) < time.Second {
-			return false
+	startAndWait := func() {
+		if err := manager.Build(server.BinaryPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting server: %v\n", err)
+			notifyServer(tracker.BuildFailed("server", err))
+			return
 		}
-		lastRestart = time.Now()
-		return true
+		runAndWait()
 	}
 
-	// Watch for changes
-	go func() {
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
+	// Start the server initially
+	startAndWait()
 
-				// Only watch .go files that aren't test files
-				if !strings.HasSuffix(event.Name, ".go") || strings.HasSuffix(event.Name, "_test.go") {
+	// Handle changes and signals
+	for {
+		select {
+		case files := <-w.Changes():
+			fmt.Printf("\nDetected changes in %s\n", describeChanges(files))
+
+			if buildFirst {
+				// Keep the old server running until we know the new code compiles
+				if err := manager.Build(nextBinaryPath); err != nil {
+					fmt.Printf("Build failed, keeping the current server running: %v\n", err)
+					notifyServer(tracker.BuildFailed("server", err))
 					continue
 				}
-
-				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove) != 0 && shouldRestart() {
-					select {
-					case restart <- struct{}{}:
-					default:
-					}
-				}
-
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
+				fmt.Println("Build succeeded, swapping server...")
+				stopServer()
+				if err := manager.ReplaceBinary(nextBinaryPath); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					continue
 				}
-				fmt.Fprintf(os.Stderr, "Watcher error: %v\n", err)
+				runAndWait()
+				continue
 			}
-		}
-	}()
 
-	// Handle restarts and signals
-	for {
-		select {
-		case <-restart:
-			fmt.Println("\nRestarting server...")
-			if cmd != nil && cmd.Process != nil {
-				if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
-					fmt.Printf("Warning: failed to send SIGTERM to server: %v\n", err)
-					cmd.Process.Kill()
-				}
-				<-done // Wait for process to finish
-			}
+			fmt.Println("Restarting server...")
+			stopServer()
 			fmt.Println("Starting new server instance...")
-			cmd = startServer()
-			if cmd != nil {
-				go func() {
-					done <- cmd.Wait()
-				}()
-			}
+			startAndWait()
+
+		case err := <-w.Errors():
+			fmt.Fprintf(os.Stderr, "Watcher error: %v\n", err)
+
+		case <-ready:
+			notifyServer(tracker.Ready("server"))
 
 		case <-sigChan:
 			fmt.Println("\nReceived interrupt signal. Shutting down...")
-			if cmd != nil && cmd.Process != nil {
-				if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
-					fmt.Printf("Warning: failed to send SIGTERM to server: %v\n", err)
-					cmd.Process.Kill()
-				}
-				<-done // Wait for process to finish
-			}
+			stopServer()
 			fmt.Println("Stopping docker services...")
 			if err := docker.StopDockerServices(); err != nil {
 				fmt.Printf("Warning: failed to stop docker services: %v\n", err)
@@ -253,22 +354,38 @@ func runWithWatcher() error {
 			return nil
 
 		case err := <-done:
+			cmd = nil
+			cancelReady()
 			if err != nil {
 				fmt.Printf("Server process ended with error: %v\n", err)
+				notifyServer(tracker.Crashed("server", supervisor.ExitStatus(err)))
 			}
-			return err
+			// Keep watching so a fix can bring the server back
+			fmt.Println("Waiting for changes...")
 		}
 	}
 }
 
-func startServer() *exec.Cmd {
+// describeChanges summarizes a list of changed files for display
+func describeChanges(files []string) string {
+	if len(files) <= 3 {
+		return strings.Join(files, ", ")
+	}
+	return fmt.Sprintf("%s and %d more files", strings.Join(files[:3], ", "), len(files)-3)
+}
+
+// newServerManager creates a server manager configured from the start flags
+func newServerManager() *server.Manager {
 	manager := server.NewManager(".")
-	cmd, err := manager.Start()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error starting server: %v\n", err)
-		return nil
+	manager.SetLogOutput(mmlog.NewRenderer(os.Stdout, logOptions))
+	manager.SetPort(serverPort)
+	if debug {
+		if ip := net.ParseIP(debugAddress); debugAddress != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			fmt.Printf("Warning: the Delve debugger listens on %s, anyone reaching it can run code as you\n", debugAddress)
+		}
+		manager.EnableDebug(debugAddress, debugPort)
 	}
-	return cmd
+	return manager
 }
 
 func StartCmd() *cobra.Command {
@@ -281,6 +398,19 @@ func StartCmd() *cobra.Command {
 				return fmt.Errorf("server directory not found at %s", serverDir)
 			}
 
+			logOptions = mmlog.Options{
+				Level:     logLevel,
+				Logger:    logLogger,
+				Caller:    logCaller,
+				Highlight: logHighlight,
+			}
+			if err := logOptions.SetGrep(logGrep); err != nil {
+				return err
+			}
+			if err := logOptions.Validate(); err != nil {
+				return err
+			}
+
 			// Change to server directory
 			if err := os.Chdir(serverDir); err != nil {
 				return fmt.Errorf("failed to change to server directory: %w", err)
@@ -295,5 +425,18 @@ func StartCmd() *cobra.Command {
 	}
 
 	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "Watch for changes and restart server")
+	cmd.Flags().IntVar(&serverPort, "port", server.DefaultPort, "Port for the server to listen on")
+	cmd.Flags().BoolVar(&debug, "debug", false, "Build without optimizations and run the server under a headless Delve debugger")
+	cmd.Flags().IntVar(&debugPort, "debug-port", 2345, "Port for the Delve debugger to listen on")
+	cmd.Flags().StringVar(&debugAddress, "debug-address", server.DefaultDebugHost, "Address for the Delve debugger to listen on. Anyone reaching it can run code as you, only change it on trusted networks")
+	cmd.Flags().BoolVar(&buildFirst, "build-first", false, "In watch mode, build before stopping the running server and keep it running on compile errors")
+	cmd.Flags().DurationVar(&watchDebounce, "debounce", time.Second, "In watch mode, time to wait for changes to settle before restarting")
+	cmd.Flags().StringSliceVar(&watchInclude, "include", []string{"**/*.go"}, "In watch mode, glob patterns of files that trigger a restart")
+	cmd.Flags().StringVar(&logLevel, "log-level", "", "Only show server logs at or above this level (trace, debug, info, warn, error)")
+	cmd.Flags().StringVar(&logGrep, "grep", "", "Only show server output lines matching this regular expression")
+	cmd.Flags().StringVar(&logLogger, "logger", "", "Only show server logs whose logger or plugin id contains this text")
+	cmd.Flags().StringVar(&logCaller, "caller", "", "Only show server logs whose caller contains this text")
+	cmd.Flags().StringSliceVar(&logHighlight, "highlight", nil, "Log fields to highlight (e.g. user_id,request_id)")
+	cmd.Flags().StringSliceVar(&watchExclude, "exclude", []string{"**/*_test.go"}, "In watch mode, glob patterns of files that never trigger a restart")
 	return cmd
 }
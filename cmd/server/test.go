@@ -0,0 +1,127 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/jespino/mmdev/pkg/docker"
+	"github.com/jespino/mmdev/pkg/gitchanges"
+	"github.com/jespino/mmdev/pkg/server"
+	"github.com/jespino/mmdev/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+func TestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "test [packages...]",
+		Short: "Run server tests against the docker services",
+		Long: `Run server tests with go test against the docker services managed by mmdev.
+
+Packages are given relative to the server directory (e.g. ./channels/app/...).
+With --changed, the packages touched by the current git diff are tested.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serverDir := "./server"
+			if _, err := os.Stat(serverDir); os.IsNotExist(err) {
+				return fmt.Errorf("server directory not found at %s", serverDir)
+			}
+
+			changed, _ := cmd.Flags().GetBool("changed")
+			serviceNames, _ := cmd.Flags().GetStringSlice("services")
+			opts := server.TestOptions{}
+			opts.Run, _ = cmd.Flags().GetString("run")
+			opts.Verbose, _ = cmd.Flags().GetBool("verbose")
+			opts.Race, _ = cmd.Flags().GetBool("race")
+			opts.Timeout, _ = cmd.Flags().GetString("timeout")
+
+			packages := args
+			if changed {
+				files, err := gitchanges.ChangedFiles(serverDir)
+				if err != nil {
+					return err
+				}
+				packages = append(packages, gitchanges.GoPackages(serverDir, files)...)
+				if len(packages) == 0 {
+					fmt.Println("No changed Go packages to test")
+					return nil
+				}
+			}
+			if len(packages) == 0 {
+				return fmt.Errorf("no packages given, pass packages to test or use --changed")
+			}
+
+			fmt.Printf("Testing %s\n", strings.Join(packages, " "))
+			failed, err := runTests(serverDir, packages, serviceNames, opts)
+			if err != nil {
+				return err
+			}
+			if failed {
+				os.Exit(1)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool("changed", false, "Test the packages touched by the current git diff")
+	cmd.Flags().String("run", "", "Only run tests matching this regular expression")
+	cmd.Flags().BoolP("verbose", "v", false, "Print the output of every test")
+	cmd.Flags().Bool("race", false, "Enable the race detector")
+	cmd.Flags().String("timeout", "30m", "Timeout for the whole test run")
+	cmd.Flags().StringSlice("services", []string{string(docker.Postgres)}, "Docker services to start before running the tests")
+	return cmd
+}
+
+// runTests starts the services the tests need, runs the tests and stops the
+// services it started, reporting whether any test failed
+func runTests(serverDir string, packages, serviceNames []string, opts server.TestOptions) (bool, error) {
+	dockerManager, err := docker.NewManager()
+	if err != nil {
+		return false, fmt.Errorf("failed to create docker manager: %w", err)
+	}
+	usePostgres := false
+	for _, name := range serviceNames {
+		service, err := docker.ParseService(name)
+		if err != nil {
+			return false, err
+		}
+		dockerManager.EnableService(service)
+		usePostgres = usePostgres || service == docker.Postgres
+	}
+
+	// go test gets the interrupt too, so wait for it to finish and stop the
+	// services afterwards
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt)
+	defer signal.Stop(sigChan)
+
+	// The services already running are left running after the tests
+	defer func() {
+		if err := dockerManager.StopStarted(); err != nil {
+			fmt.Printf("Warning: failed to stop docker services: %v\n", err)
+		}
+	}()
+	if err := dockerManager.Start(); err != nil {
+		return false, fmt.Errorf("failed to start docker services: %w", err)
+	}
+	if usePostgres {
+		if err := dockerManager.CreateDatabase(server.TestDatabase); err != nil {
+			return false, err
+		}
+	}
+
+	manager := server.NewManager(serverDir)
+	report, err := manager.Test(packages, opts)
+	if err != nil {
+		return false, err
+	}
+
+	report.PrintSummary(func(pkg, pattern string) string {
+		args := []string{"mmdev", "server", "test", pkg}
+		if pattern != "" {
+			args = append(args, "--run", pattern)
+		}
+		return utils.ShellJoin(args)
+	})
+	return report.Failed(), nil
+}
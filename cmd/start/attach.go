@@ -0,0 +1,194 @@
+package start
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jespino/mmdev/internal/config"
+	"github.com/jespino/mmdev/pkg/control"
+	"github.com/jespino/mmdev/pkg/notify"
+	"github.com/spf13/cobra"
+)
+
+func AttachCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "attach",
+		Short: "Show the development environment started with mmdev start --detach",
+		Long: `Show the processes started with mmdev start --detach in the terminal UI.
+
+Quitting the UI leaves them running, use :shutdown to stop them.`,
+		Annotations: map[string]string{
+			"requiresMMRepo": "true",
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := startConfig(cmd)
+			if err != nil {
+				return err
+			}
+			// The detached mmdev start runs the webhook and the command
+			notifier, err := newNotifier(cfg.Notify, os.Stdout, false)
+			if err != nil {
+				return err
+			}
+			client, err := dialDaemon(cmd)
+			if err != nil {
+				return err
+			}
+			return AttachTUI(cfg.Start, client, notifier)
+		},
+	}
+	cmd.Flags().String("layout", "", "Arrangement of the panes: rows, columns or grid (default: rows)")
+	cmd.Flags().Int("scrollback", 0, "Number of lines of output kept per pane (default: 10000)")
+	cmd.Flags().String("socket", "", "Control socket of the processes running in the background (default: one per repository)")
+	return cmd
+}
+
+func CtlCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ctl",
+		Short: "Control the development environment started with mmdev start --detach",
+	}
+	cmd.PersistentFlags().String("socket", "", "Control socket of the processes running in the background (default: one per repository)")
+
+	cmd.AddCommand(
+		CtlActionCmd("start", "Start the processes of the given panes", (*control.Client).Start),
+		CtlActionCmd("stop", "Stop the processes of the given panes", (*control.Client).Stop),
+		CtlActionCmd("restart", "Restart the processes of the given panes", (*control.Client).Restart),
+		CtlStatusCmd(),
+		CtlShutdownCmd(),
+	)
+	return cmd
+}
+
+// CtlActionCmd returns the command running an action on the processes of
+// the given panes
+func CtlActionCmd(action, short string, run func(c *control.Client, name string) error) *cobra.Command {
+	return &cobra.Command{
+		Use:   action + " <pane>...",
+		Short: short,
+		Args:  cobra.MinimumNArgs(1),
+		Annotations: map[string]string{
+			"requiresMMRepo": "true",
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := dialDaemon(cmd)
+			if err != nil {
+				return err
+			}
+			for _, name := range args {
+				if err := run(client, name); err != nil {
+					return fmt.Errorf("failed to %s %s: %w", action, name, err)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func CtlStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the state of the processes",
+		Annotations: map[string]string{
+			"requiresMMRepo": "true",
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := dialDaemon(cmd)
+			if err != nil {
+				return err
+			}
+			statuses, err := client.Status()
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "PANE\tSTATE\tPID\tUPTIME")
+			for _, status := range statuses {
+				pid, uptime := "-", "-"
+				if status.Pid != 0 {
+					pid = fmt.Sprint(status.Pid)
+				}
+				if status.State != "stopped" && !status.Since.IsZero() {
+					uptime = time.Since(status.Since).Round(time.Second).String()
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status.Process, status.State, pid, uptime)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func CtlShutdownCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "shutdown",
+		Short: "Stop all the processes and the background mmdev start",
+		Annotations: map[string]string{
+			"requiresMMRepo": "true",
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := dialDaemon(cmd)
+			if err != nil {
+				return err
+			}
+			fmt.Println("Stopping the processes...")
+			if err := client.Shutdown(); err != nil {
+				return fmt.Errorf("failed to stop the processes: %w", err)
+			}
+			fmt.Println("Stopped")
+			return nil
+		},
+	}
+}
+
+// daemonController is the controller of a TUI attached to a detached mmdev
+// start. The TUI exits once it asked to shut down, whether it worked or not.
+type daemonController struct {
+	*control.Client
+}
+
+func (c daemonController) Shutdown() {
+	c.Client.Shutdown()
+}
+
+// AttachTUI shows the processes of a detached mmdev start
+func AttachTUI(cfg config.StartConfig, client *control.Client, notifier *notify.Notifier) error {
+	attachedAt := time.Now()
+	var info daemonInfo
+	if err := client.Attach(&info); err != nil {
+		return fmt.Errorf("failed to attach: %w", err)
+	}
+	defer client.Close()
+
+	// The detached mmdev start writes the session logs, the saved logs go
+	// to the same directory
+	session := &session{dir: info.SessionDir}
+	m, err := initialModel(cfg, info.Panes, session, daemonController{client})
+	if err != nil {
+		return err
+	}
+	m.attached = true
+	m.notifier = notifier
+	// The history of the processes was already notified
+	m.notifySince = attachedAt
+	m.message = "Attached, q detaches leaving the processes running and :shutdown stops them"
+
+	p := tea.NewProgram(
+		m,
+		tea.WithAltScreen(),
+		tea.WithMouseAllMotion(),
+	)
+	if _, err := p.Run(); err != nil {
+		return err
+	}
+	switch {
+	case m.disconnected && !m.quitting:
+		fmt.Println("The background mmdev start exited")
+	case !m.quitting:
+		fmt.Println("Detached, the processes keep running in the background")
+	}
+	return nil
+}
@@ -0,0 +1,697 @@
+package start
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jespino/mmdev/internal/config"
+	"github.com/jespino/mmdev/pkg/docker"
+	"github.com/jespino/mmdev/pkg/fuzzy"
+	"github.com/jespino/mmdev/pkg/generator"
+	"github.com/jespino/mmdev/pkg/gitchanges"
+	"github.com/jespino/mmdev/pkg/mmlog"
+	"github.com/jespino/mmdev/pkg/plugins/pluginctl"
+	"github.com/jespino/mmdev/pkg/server"
+	"github.com/jespino/mmdev/pkg/supervisor"
+)
+
+// paletteSize is the number of completions shown above the command prompt
+const paletteSize = 6
+
+// generateParallel is the number of generators run at the same time
+const generateParallel = 4
+
+// command is a command of the command mode
+type command struct {
+	name    string
+	aliases []string
+	// args describes the arguments, for the palette
+	args string
+	help string
+	// complete returns the values of the argument at position n, given the
+	// previous ones, nil when it takes any value
+	complete func(m *model, n int, args []string) []string
+	// run receives the arguments and the input after the name, for the
+	// commands taking patterns with spaces
+	run func(m *model, args []string, rest string) tea.Cmd
+}
+
+// completion is a value for the command input offered by the palette
+type completion struct {
+	value       string
+	description string
+	// more is set when the value takes more arguments
+	more bool
+}
+
+// newCommands returns the commands of the command mode, the builtin ones and
+// those in the configuration
+func newCommands(configured []config.CommandConfig) ([]command, error) {
+	commands := builtinCommands()
+	for _, cfg := range configured {
+		if cfg.Name == "" || strings.ContainsAny(cfg.Name, " \t") {
+			return nil, fmt.Errorf("invalid command name %q in the start configuration", cfg.Name)
+		}
+		if cfg.Run == "" {
+			return nil, fmt.Errorf("command %q has nothing to run", cfg.Name)
+		}
+		if findCommand(commands, cfg.Name) != nil {
+			return nil, fmt.Errorf("duplicated command %q", cfg.Name)
+		}
+		commands = append(commands, shellCommand(cfg))
+	}
+	return commands, nil
+}
+
+func findCommand(commands []command, name string) *command {
+	for i, c := range commands {
+		if c.name == name || slices.Contains(c.aliases, name) {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func builtinCommands() []command {
+	return []command{
+		{
+			name:    "quit",
+			aliases: []string{"q"},
+			help:    "Exit, stopping all the processes unless attached to a detached mmdev start",
+			run: func(m *model, args []string, rest string) tea.Cmd {
+				return m.quit()
+			},
+		},
+		{
+			name: "shutdown",
+			help: "Stop all the processes and exit, also when attached",
+			run: func(m *model, args []string, rest string) tea.Cmd {
+				return m.shutdown()
+			},
+		},
+		{
+			name:     "start",
+			args:     "[pane]",
+			help:     "Start the process of a pane",
+			complete: completePanes,
+			run: paneCommand(func(m *model, p *pane) {
+				m.start(p)
+			}),
+		},
+		{
+			name:     "stop",
+			args:     "[pane]",
+			help:     "Stop the process of a pane",
+			complete: completePanes,
+			run: paneCommand(func(m *model, p *pane) {
+				m.stop(p)
+			}),
+		},
+		{
+			name:     "restart",
+			args:     "[pane]",
+			help:     "Restart the process of a pane",
+			complete: completePanes,
+			run: paneCommand(func(m *model, p *pane) {
+				m.restart(p)
+			}),
+		},
+		{
+			name:     "clear",
+			args:     "[pane]",
+			help:     "Clear the output of a pane",
+			complete: completePanes,
+			run: paneCommand(func(m *model, p *pane) {
+				p.clear()
+			}),
+		},
+		{
+			name:     "layout",
+			args:     "[rows|columns|grid]",
+			help:     "Change the layout, to the next one by default",
+			complete: completeValues(layouts...),
+			run: func(m *model, args []string, rest string) tea.Cmd {
+				if len(args) == 0 {
+					m.setLayout(nextLayout(m.layout))
+					return nil
+				}
+				if err := validateLayout(args[0]); err != nil {
+					m.message = err.Error()
+					return nil
+				}
+				m.setLayout(args[0])
+				return nil
+			},
+		},
+		{
+			name: "search",
+			args: "<regex>",
+			help: "Highlight the matching lines of the selected pane",
+			run: func(m *model, args []string, rest string) tea.Cmd {
+				m.setSearch(rest)
+				return nil
+			},
+		},
+		{
+			name: "filter",
+			args: "<regex>",
+			help: "Only show the matching lines of the selected pane",
+			run: func(m *model, args []string, rest string) tea.Cmd {
+				m.setFilter(rest)
+				return nil
+			},
+		},
+		{
+			name:     "level",
+			args:     "[level|all]",
+			help:     "Hide the log lines below a level, the next one by default",
+			complete: completeValues(append([]string{"all"}, mmlog.Levels...)...),
+			run: func(m *model, args []string, rest string) tea.Cmd {
+				if len(args) == 0 {
+					m.cycleLevel()
+					return nil
+				}
+				p := m.panes[m.selected]
+				if err := p.filter.setLevel(args[0]); err != nil {
+					m.message = err.Error()
+					return nil
+				}
+				p.rerender()
+				return nil
+			},
+		},
+		{
+			name:     "server-log-level",
+			args:     "<level>",
+			help:     "Change the log level of the running server",
+			complete: completeValues(mmlog.Levels...),
+			run:      runServerLogLevel,
+		},
+		{
+			name:     "save",
+			args:     "[pane] [file]",
+			help:     "Save the output kept by a pane to a file",
+			complete: completePanes,
+			run: func(m *model, args []string, rest string) tea.Cmd {
+				m.save(args)
+				return nil
+			},
+		},
+		{
+			name: "open",
+			help: "Open the selected file location in the editor",
+			run: func(m *model, args []string, rest string) tea.Cmd {
+				return m.openLocation()
+			},
+		},
+		{
+			name:     "docker-restart",
+			args:     "<service>",
+			help:     "Restart a docker service",
+			complete: completeDockerServices,
+			run:      runDockerRestart,
+		},
+		{
+			name:     "plugin-deploy",
+			args:     "<plugin-id> <bundle>",
+			help:     "Upload and enable a plugin bundle",
+			complete: completePluginBundles,
+			run:      runPluginDeploy,
+		},
+		{
+			name:     "generate",
+			args:     "<layers|mocks|all> [names...]",
+			help:     "Run the server code generators, all runs the ones affected by your changes",
+			complete: completeGenerators,
+			run:      runGenerate,
+		},
+	}
+}
+
+// paneCommand runs an action on the named pane, the selected one by default
+func paneCommand(action func(m *model, p *pane)) func(m *model, args []string, rest string) tea.Cmd {
+	return func(m *model, args []string, rest string) tea.Cmd {
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		p, err := m.pane(name)
+		if err != nil {
+			m.message = err.Error()
+			return nil
+		}
+		action(m, p)
+		return nil
+	}
+}
+
+func completePanes(m *model, n int, args []string) []string {
+	if n > 0 {
+		return nil
+	}
+	names := make([]string, len(m.panes))
+	for i, p := range m.panes {
+		names[i] = p.Name
+	}
+	return names
+}
+
+func completeValues(values ...string) func(m *model, n int, args []string) []string {
+	return func(m *model, n int, args []string) []string {
+		if n > 0 {
+			return nil
+		}
+		return values
+	}
+}
+
+func completeDockerServices(m *model, n int, args []string) []string {
+	if n > 0 {
+		return nil
+	}
+	services := make([]string, len(docker.DefaultServices))
+	for i, service := range docker.DefaultServices {
+		services[i] = string(service)
+	}
+	return services
+}
+
+func runDockerRestart(m *model, args []string, rest string) tea.Cmd {
+	if len(args) != 1 {
+		m.message = "usage: docker-restart <service>"
+		return nil
+	}
+	service, err := docker.ParseService(args[0])
+	if err != nil {
+		m.message = err.Error()
+		return nil
+	}
+	m.runTask("docker-restart "+args[0], dockerPane, func(ctx context.Context, out io.Writer) error {
+		manager, err := docker.NewManager()
+		if err != nil {
+			return fmt.Errorf("failed to create docker manager: %w", err)
+		}
+		manager.SetOutput(out)
+		return manager.Restart(service)
+	})
+	return nil
+}
+
+func runServerLogLevel(m *model, args []string, rest string) tea.Cmd {
+	if len(args) != 1 {
+		m.message = "usage: server-log-level <level>"
+		return nil
+	}
+	level := args[0]
+	m.runTask("server-log-level "+level, serverPane, func(ctx context.Context, out io.Writer) error {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := server.NewManager("./server").SetLogLevel(ctx, level); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Server log level set to %s\n", level)
+		return nil
+	})
+	return nil
+}
+
+// completePluginBundles offers the bundles built in the current directory or
+// the plugin directories in it
+func completePluginBundles(m *model, n int, args []string) []string {
+	if n != 1 {
+		return nil
+	}
+	bundles, _ := filepath.Glob(filepath.Join("dist", "*.tar.gz"))
+	nested, _ := filepath.Glob(filepath.Join("*", "dist", "*.tar.gz"))
+	return append(bundles, nested...)
+}
+
+func runPluginDeploy(m *model, args []string, rest string) tea.Cmd {
+	if len(args) != 2 {
+		m.message = "usage: plugin-deploy <plugin-id> <bundle>"
+		return nil
+	}
+	pluginID, bundle := args[0], args[1]
+	m.runTask("plugin-deploy "+pluginID, "", func(ctx context.Context, out io.Writer) error {
+		connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		client, err := pluginctl.NewClient(connectCtx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Deploying %s from %s\n", pluginID, bundle)
+		return client.Deploy(ctx, pluginID, bundle)
+	})
+	return nil
+}
+
+// completeGenerators offers the generator groups and then the generators of
+// the group, discovered once
+func completeGenerators(m *model, n int, args []string) []string {
+	if n == 0 {
+		return []string{"all", generator.GroupLayers, generator.GroupMocks}
+	}
+	if args[0] == "all" {
+		return nil
+	}
+	if m.generators == nil {
+		targets, err := generator.NewManager("./server").Targets()
+		if err != nil {
+			return nil
+		}
+		m.generators = targets
+	}
+
+	var names []string
+	for _, target := range m.generators {
+		if target.Group == args[0] && !slices.Contains(args[1:], target.Name) {
+			names = append(names, target.Name)
+		}
+	}
+	return names
+}
+
+func runGenerate(m *model, args []string, rest string) tea.Cmd {
+	if len(args) == 0 {
+		m.message = "usage: generate <layers|mocks|all> [names...]"
+		return nil
+	}
+	group, names := args[0], args[1:]
+	m.runTask("generate "+strings.Join(args, " "), serverPane, func(ctx context.Context, out io.Writer) error {
+		manager := generator.NewManager("./server")
+		manager.SetOutput(out)
+		targets, err := manager.Targets()
+		if err != nil {
+			return err
+		}
+
+		if group != "all" {
+			targets, err = generator.Select(targets, group, names)
+			if err != nil {
+				return err
+			}
+			return manager.Run(targets, generateParallel)
+		}
+		changed, err := gitchanges.ChangedFilesSinceMergeBase("./server")
+		if err != nil {
+			fmt.Fprintf(out, "Warning: %v, running every generator\n", err)
+		} else {
+			targets = manager.Affected(targets, changed)
+			if len(targets) == 0 {
+				fmt.Fprintln(out, "No generators are affected by your changes")
+				return nil
+			}
+		}
+		return manager.RunWithRollback(targets, generateParallel)
+	})
+	return nil
+}
+
+// shellCommand returns the command running a shell command of the
+// configuration
+func shellCommand(cfg config.CommandConfig) command {
+	help := cfg.Description
+	if help == "" {
+		help = cfg.Run
+	}
+	return command{
+		name: cfg.Name,
+		args: "[args...]",
+		help: help,
+		run: func(m *model, args []string, rest string) tea.Cmd {
+			name := strings.Join(append([]string{cfg.Name}, args...), " ")
+			m.runTask(name, cfg.Pane, func(ctx context.Context, out io.Writer) error {
+				// The name of the command is $0 and the arguments $1, $2...
+				cmd := exec.Command("sh", append([]string{"-c", cfg.Run, cfg.Name}, args...)...)
+				cmd.Dir = cfg.Dir
+				cmd.Stdout = out
+				cmd.Stderr = out
+				cmd.WaitDelay = time.Second
+				if err := cmd.Start(); err != nil {
+					return fmt.Errorf("failed to start %s: %w", cfg.Name, err)
+				}
+				return supervisor.Wait(ctx, cmd)
+			})
+			return nil
+		},
+	}
+}
+
+// command returns the command with the given name or alias
+func (m *model) command(name string) *command {
+	return findCommand(m.commands, name)
+}
+
+func (m *model) runCommand(input string) (tea.Model, tea.Cmd) {
+	fields := strings.Fields(input)
+	if len(fields) == 0 {
+		return m, nil
+	}
+	c := m.command(fields[0])
+	if c == nil {
+		m.message = fmt.Sprintf("unknown command %q", input)
+		return m, nil
+	}
+	// Patterns may contain spaces, so they take the rest of the input
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input), fields[0]))
+	return m, c.run(m, fields[1:], rest)
+}
+
+// completions returns the values completing the command input, the best
+// matches first: the command names, or the values of the argument being
+// typed
+func (m *model) completions(input string) []completion {
+	fields := strings.Fields(input)
+	typing := input != "" && !strings.HasSuffix(input, " ")
+
+	if len(fields) == 0 || (len(fields) == 1 && typing) {
+		var names []string
+		for _, c := range m.commands {
+			names = append(names, c.name)
+		}
+		pattern := strings.TrimSpace(input)
+		var completions []completion
+		for _, name := range fuzzy.Filter(pattern, names) {
+			c := m.command(name)
+			completions = append(completions, completion{
+				value:       name,
+				description: strings.TrimSpace(c.args + "  " + c.help),
+				more:        c.args != "",
+			})
+		}
+		return completions
+	}
+
+	c := m.command(fields[0])
+	if c == nil || c.complete == nil {
+		return nil
+	}
+	args := fields[1:]
+	pattern := ""
+	if typing {
+		pattern = args[len(args)-1]
+		args = args[:len(args)-1]
+	}
+	prefix := strings.Join(append([]string{fields[0]}, args...), " ") + " "
+	var completions []completion
+	for _, value := range fuzzy.Filter(pattern, c.complete(m, len(args), args)) {
+		completions = append(completions, completion{value: prefix + value})
+	}
+	return completions
+}
+
+// updatePalette finds the completions of the command input
+func (m *model) updatePalette() {
+	m.palette = nil
+	m.paletteIndex = 0
+	if m.inputMode == inputCommand {
+		m.palette = m.completions(m.commandInput.Value())
+	}
+}
+
+// movePalette selects the next or previous completion, wrapping around
+func (m *model) movePalette(forward bool) {
+	if len(m.palette) == 0 {
+		return
+	}
+	if forward {
+		m.paletteIndex = (m.paletteIndex + 1) % len(m.palette)
+	} else {
+		m.paletteIndex = (m.paletteIndex + len(m.palette) - 1) % len(m.palette)
+	}
+}
+
+// complete replaces the command input with the selected completion
+func (m *model) complete() {
+	if len(m.palette) == 0 {
+		return
+	}
+	selected := m.palette[m.paletteIndex]
+	value := selected.value
+	if selected.more {
+		value += " "
+	}
+	m.commandInput.SetValue(value)
+	m.commandInput.CursorEnd()
+	m.updatePalette()
+}
+
+// accept returns the input to run: as typed when it names a command and its
+// last argument is valid, otherwise the selected completion
+func (m *model) accept(input string) string {
+	if len(m.palette) == 0 {
+		return input
+	}
+	fields := strings.Fields(input)
+	if len(fields) == 0 {
+		return input
+	}
+	c := m.command(fields[0])
+	if c == nil {
+		return m.palette[m.paletteIndex].value
+	}
+	if len(fields) == 1 || strings.HasSuffix(input, " ") || c.complete == nil {
+		return input
+	}
+	args := fields[1 : len(fields)-1]
+	values := c.complete(m, len(args), args)
+	if values == nil || slices.Contains(values, fields[len(fields)-1]) {
+		return input
+	}
+	return m.palette[m.paletteIndex].value
+}
+
+// paletteView renders the completions shown above the command prompt
+func (m *model) paletteView() []string {
+	if len(m.palette) == 0 {
+		return nil
+	}
+	// Scroll the list to keep the selected completion visible
+	start := max(0, m.paletteIndex-paletteSize+1)
+	end := min(len(m.palette), start+paletteSize)
+
+	var lines []string
+	for i := start; i < end; i++ {
+		c := m.palette[i]
+		line := " " + c.value
+		if c.description != "" {
+			line += "  " + suggestionStyle.Render(c.description)
+		}
+		style := paletteStyle
+		if i == m.paletteIndex {
+			style = paletteSelectedStyle
+		}
+		lines = append(lines, style.Width(m.windowWidth).MaxWidth(m.windowWidth).Render(line))
+	}
+	return lines
+}
+
+// taskMsg is a line of output of a task run from the command mode, or its
+// end when done
+type taskMsg struct {
+	task string
+	pane string
+	line string
+	done bool
+	err  error
+}
+
+// runTask runs a task in the background, showing its output in the named
+// pane, or the selected one when there is no such pane. A task can't run
+// twice at the same time.
+func (m *model) runTask(name, paneName string, run func(ctx context.Context, out io.Writer) error) {
+	if m.tasks[name] {
+		m.message = fmt.Sprintf("%s is already running", name)
+		return
+	}
+	p := m.panes[m.selected]
+	if paneName != "" {
+		if named, err := m.pane(paneName); err == nil {
+			p = named
+		}
+	}
+	m.tasks[name] = true
+	p.appendDivider(name)
+
+	go func() {
+		out := &taskOutput{task: name, pane: p.Name, msgs: m.taskMsgs}
+		err := run(m.tasksCtx, out)
+		out.flush()
+		m.taskMsgs <- taskMsg{task: name, pane: p.Name, done: true, err: err}
+	}()
+}
+
+// listenTasks returns the command waiting for the next output of the tasks
+func (m *model) listenTasks() tea.Cmd {
+	msgs := m.taskMsgs
+	return func() tea.Msg {
+		return <-msgs
+	}
+}
+
+// handleTask shows the output of a task, and its result once done
+func (m *model) handleTask(msg taskMsg) {
+	p, err := m.pane(msg.pane)
+	if err != nil {
+		return
+	}
+	if !msg.done {
+		p.appendLine(msg.line)
+		return
+	}
+
+	delete(m.tasks, msg.task)
+	if msg.err != nil {
+		p.appendLine(fmt.Sprintf("%s failed: %v", msg.task, msg.err))
+		m.message = fmt.Sprintf("%s failed, see the %s pane", msg.task, p.Name)
+		return
+	}
+	p.appendLine(msg.task + " finished")
+	m.message = msg.task + " finished"
+}
+
+// taskOutput sends the lines written by a task to the TUI
+type taskOutput struct {
+	task    string
+	pane    string
+	msgs    chan<- taskMsg
+	mu      sync.Mutex
+	partial []byte
+}
+
+func (o *taskOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.partial = append(o.partial, p...)
+	for {
+		i := bytes.IndexByte(o.partial, '\n')
+		if i < 0 {
+			break
+		}
+		o.send(string(bytes.TrimSuffix(o.partial[:i], []byte("\r"))))
+		o.partial = o.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (o *taskOutput) send(line string) {
+	o.msgs <- taskMsg{task: o.task, pane: o.pane, line: line}
+}
+
+func (o *taskOutput) flush() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.partial) > 0 {
+		o.send(string(o.partial))
+		o.partial = nil
+	}
+}
@@ -0,0 +1,219 @@
+package start
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jespino/mmdev/internal/config"
+	"github.com/jespino/mmdev/pkg/control"
+	"github.com/jespino/mmdev/pkg/notify"
+	"github.com/jespino/mmdev/pkg/supervisor"
+	"github.com/spf13/cobra"
+)
+
+// daemonStartTimeout is how long mmdev start --detach waits for the
+// background process to serve the socket
+const daemonStartTimeout = 30 * time.Second
+
+// daemonInfo is sent by the detached mmdev start to the attaching TUIs
+type daemonInfo struct {
+	Panes      []config.PaneConfig `json:"panes"`
+	SessionDir string              `json:"session_dir"`
+}
+
+// socketPath returns the --socket flag, or by default the socket of the
+// detached mmdev start of the Mattermost repository in the working directory
+func socketPath(cmd *cobra.Command) (string, error) {
+	if socket, _ := cmd.Flags().GetString("socket"); socket != "" {
+		return socket, nil
+	}
+
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get cache directory: %w", err)
+		}
+		dir = cacheDir
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(wd))
+	return filepath.Join(dir, "mmdev", fmt.Sprintf("start-%x.sock", sum[:6])), nil
+}
+
+// daemonLogPath returns where the detached mmdev start writes its own
+// messages
+func daemonLogPath(socket string) string {
+	return strings.TrimSuffix(socket, ".sock") + ".log"
+}
+
+// dialDaemon connects to the detached mmdev start
+func dialDaemon(cmd *cobra.Command) (*control.Client, error) {
+	socket, err := socketPath(cmd)
+	if err != nil {
+		return nil, err
+	}
+	client, err := control.Dial(socket)
+	if err != nil {
+		return nil, fmt.Errorf("mmdev start is not running in the background for this repository, start it with mmdev start --detach")
+	}
+	return client, nil
+}
+
+// startDetached runs mmdev start again in the background, in a new session
+// so it survives the terminal, and waits for it to serve the socket
+func startDetached(socket string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the mmdev executable: %w", err)
+	}
+	var args []string
+	for _, arg := range os.Args[1:] {
+		if arg != "--detach" && !strings.HasPrefix(arg, "--detach=") {
+			args = append(args, arg)
+		}
+	}
+	args = append(args, "--daemon", "--socket", socket)
+
+	logPath := daemonLogPath(socket)
+	if err := os.MkdirAll(filepath.Dir(logPath), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", logPath, err)
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", logPath, err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start mmdev in the background: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	deadline := time.After(daemonStartTimeout)
+	for !control.Running(socket) {
+		select {
+		case err := <-exited:
+			return fmt.Errorf("mmdev start exited in the background (%v), see %s", err, logPath)
+		case <-deadline:
+			return fmt.Errorf("timeout waiting for mmdev start in the background, see %s", logPath)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	fmt.Printf("Started in the background (pid %d), logging to %s\n", cmd.Process.Pid, logPath)
+	fmt.Println("Attach with mmdev attach, control it with mmdev ctl and stop it with mmdev ctl shutdown")
+	return nil
+}
+
+// runDaemon runs the processes of the panes without UI, serving them on the
+// socket until asked to shut down or terminated
+func runDaemon(cfg config.StartConfig, panes []config.PaneConfig, socket string, notifier *notify.Notifier) error {
+	session, err := newSession(cfg, panes)
+	if err != nil {
+		return fmt.Errorf("failed to create the session: %w", err)
+	}
+	defer session.close()
+
+	s, err := newSupervisor(panes)
+	if err != nil {
+		return err
+	}
+	srv, err := control.Listen(socket, s, cfg.Scrollback, daemonInfo{Panes: panes, SessionDir: session.dir})
+	if err != nil {
+		return err
+	}
+	defer srv.Close()
+	go func() {
+		if err := srv.Serve(); err != nil {
+			fmt.Println(err)
+		}
+	}()
+
+	// There is no terminal to hang up, but the parent may send it
+	signal.Ignore(syscall.SIGHUP)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	var names []string
+	for _, pane := range panes {
+		names = append(names, pane.Name)
+		if autostart(pane) {
+			if err := s.Start(pane.Name); err != nil {
+				fmt.Printf("Failed to start %s: %v\n", pane.Name, err)
+			}
+		}
+	}
+	fmt.Printf("Running %s, serving on %s\n", strings.Join(names, ", "), socket)
+	if session.logging() {
+		fmt.Printf("Logging the session to %s\n", session.dir)
+	}
+
+	tracker := notify.NewTracker()
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-srv.ShutdownRequested():
+			fmt.Println("Shutdown requested")
+		case sig := <-signals:
+			fmt.Printf("Received %s\n", sig)
+		}
+		fmt.Println("Stopping the processes...")
+		s.Shutdown()
+		close(stopped)
+	}()
+
+	for {
+		select {
+		case event := <-s.Events():
+			publishEvent(srv, session, event)
+			notifyEvent(notifier, tracker, event)
+		case <-stopped:
+			// The processes reported their end before stopping
+			for len(s.Events()) > 0 {
+				publishEvent(srv, session, <-s.Events())
+			}
+			fmt.Println("Stopped")
+			return nil
+		}
+	}
+}
+
+// publishEvent sends an event to the attached TUIs and writes it to the
+// session log of its process, like the panes do
+func publishEvent(srv *control.Server, session *session, event supervisor.Event) {
+	srv.Publish(event)
+
+	log := session.logs[event.Process]
+	if log == nil {
+		return
+	}
+	var err error
+	if event.Type == supervisor.Starting && event.Run > 1 {
+		err = writeLogLine(log, logLine{divider: true, text: "restart", time: event.Time})
+	}
+	if line, ok := eventLine(event); ok && err == nil {
+		err = writeLogLine(log, logLine{text: line})
+	}
+	if err != nil {
+		fmt.Printf("Error writing the session log of %s, disabling it: %v\n", event.Process, err)
+		log.Close()
+		delete(session.logs, event.Process)
+	}
+}
@@ -0,0 +1,86 @@
+package start
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// layouts are the supported arrangements of the panes, in the order the
+// layout key cycles through them
+var layouts = []string{"rows", "columns", "grid"}
+
+// rect is the area of the screen given to a pane
+type rect struct {
+	x, y, width, height int
+}
+
+func (r rect) contains(x, y int) bool {
+	return x >= r.x && x < r.x+r.width && y >= r.y && y < r.y+r.height
+}
+
+func validateLayout(layout string) error {
+	for _, l := range layouts {
+		if l == layout {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown layout %q, expected one of %s", layout, strings.Join(layouts, ", "))
+}
+
+// nextLayout returns the layout following the given one
+func nextLayout(layout string) string {
+	for i, l := range layouts {
+		if l == layout {
+			return layouts[(i+1)%len(layouts)]
+		}
+	}
+	return layouts[0]
+}
+
+// layoutPanes splits a width x height area between n panes. Rows stacks them,
+// columns puts them side by side and grid arranges them in a square-ish grid,
+// with the last row sharing its width between the remaining panes.
+func layoutPanes(layout string, n, width, height int) []rect {
+	if n == 0 {
+		return nil
+	}
+
+	var rows, cols int
+	switch layout {
+	case "columns":
+		rows, cols = 1, n
+	case "grid":
+		cols = int(math.Ceil(math.Sqrt(float64(n))))
+		rows = (n + cols - 1) / cols
+	default:
+		rows, cols = n, 1
+	}
+
+	rects := make([]rect, 0, n)
+	heights := split(height, rows)
+	y := 0
+	for row := 0; row < rows; row++ {
+		inRow := cols
+		if remaining := n - len(rects); remaining < cols {
+			inRow = remaining
+		}
+		x := 0
+		for _, w := range split(width, inRow) {
+			rects = append(rects, rect{x: x, y: y, width: w, height: heights[row]})
+			x += w
+		}
+		y += heights[row]
+	}
+	return rects
+}
+
+// split divides size in n parts, giving the remainder to the last one
+func split(size, n int) []int {
+	parts := make([]int, n)
+	for i := range parts {
+		parts[i] = size / n
+	}
+	parts[n-1] += size % n
+	return parts
+}
@@ -0,0 +1,136 @@
+package start
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jespino/mmdev/pkg/editor"
+)
+
+var locationStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("#87CEEB")).
+	Underline(true)
+
+// sourceRef is a file location found in the output of a pane
+type sourceRef struct {
+	// seq is the output line, pos the position of its first row in the view
+	seq uint64
+	pos int
+	loc editor.Location
+}
+
+// editorDoneMsg is sent when the editor opened from the TUI exits
+type editorDoneMsg struct {
+	err error
+}
+
+// locationDirs returns the directories the relative locations in the output
+// of the pane are resolved from, in order
+func (p *pane) locationDirs() []string {
+	var dirs []string
+	switch {
+	case len(p.Command) > 0:
+		if p.Dir != "" {
+			dirs = append(dirs, p.Dir)
+		}
+	case p.Name == serverPane:
+		// The Go compiler prints the paths relative to the module
+		dirs = append(dirs, "server")
+	case p.Name == webappPane:
+		dirs = append(dirs, filepath.Join("webapp", "channels"), "webapp")
+	}
+	return append(dirs, ".")
+}
+
+// findLocations returns the locations in a line without colors whose file
+// exists, with the path of the file as resolved
+func (p *pane) findLocations(text string) []editor.Match {
+	var found []editor.Match
+	for _, match := range editor.Find(text) {
+		file, ok := p.resolved[match.File]
+		if !ok {
+			loc, exists := editor.Resolve(match.Location, p.locationDirs())
+			if exists {
+				file = loc.File
+			}
+			p.resolved[match.File] = file
+		}
+		if file == "" {
+			continue
+		}
+		match.File = file
+		found = append(found, match)
+	}
+	return found
+}
+
+// underlineLocations renders the locations found in a row without colors
+func underlineLocations(text string, matches []editor.Match) string {
+	for _, match := range matches {
+		text = strings.ReplaceAll(text, match.Text, locationStyle.Render(match.Text))
+	}
+	return text
+}
+
+// nextLocation moves to the following location when forward, or the
+// previous one, wrapping around. The first move goes to the last one, the
+// most recent error.
+func (p *pane) nextLocation(forward bool) {
+	if len(p.locations) == 0 {
+		return
+	}
+	switch {
+	case p.location < 0:
+		p.location = len(p.locations) - 1
+	case forward:
+		p.location = (p.location + 1) % len(p.locations)
+	default:
+		p.location = (p.location + len(p.locations) - 1) % len(p.locations)
+	}
+	p.view.scrollTo(p.locations[p.location].pos)
+}
+
+// selectLocationAt selects the first location of the output line shown in
+// the given row of the view, returning false when there is none
+func (p *pane) selectLocationAt(y int) bool {
+	r, ok := p.view.rowAt(y)
+	if !ok {
+		return false
+	}
+	for i, ref := range p.locations {
+		if ref.seq == r.seq {
+			p.location = i
+			return true
+		}
+	}
+	return false
+}
+
+// openLocation opens the selected location, or the last one when none is
+// selected, in the editor given by the template
+func (p *pane) openLocation(template string) (tea.Cmd, error) {
+	if len(p.locations) == 0 {
+		return nil, fmt.Errorf("no file locations in %s", p.Name)
+	}
+	if p.location < 0 {
+		p.location = len(p.locations) - 1
+	}
+	cmd, err := editor.Command(template, p.locations[p.location].loc)
+	if err != nil {
+		return nil, err
+	}
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return editorDoneMsg{err: err}
+	}), nil
+}
+
+// locationStatus describes the selected location for the pane title
+func (p *pane) locationStatus() string {
+	if p.location < 0 || p.location >= len(p.locations) {
+		return ""
+	}
+	return fmt.Sprintf("%s %d/%d", p.locations[p.location].loc, p.location+1, len(p.locations))
+}
@@ -0,0 +1,183 @@
+package start
+
+import (
+	"strings"
+)
+
+// row is a line of the screen, part of the wrapped output line seq
+type row struct {
+	seq  uint64
+	text string
+}
+
+// logView is a scrollable list of rows. Unlike the bubbles viewport, rows
+// are appended and dropped without rebuilding the whole content, so the cost
+// of a new line doesn't grow with the size of the log.
+type logView struct {
+	width  int
+	height int
+	rows   []row
+	// dropped counts the rows removed from the front, so positions stored
+	// as dropped+index stay valid
+	dropped int
+	yOffset int
+	// follow keeps the view at the bottom as rows are added
+	follow bool
+}
+
+func newLogView() *logView {
+	return &logView{follow: true}
+}
+
+func (v *logView) setSize(width, height int) {
+	v.width = width
+	v.height = height
+	v.clampOffset()
+}
+
+// append adds rows at the bottom
+func (v *logView) append(rows ...row) {
+	v.rows = append(v.rows, rows...)
+	if v.follow {
+		v.gotoBottom()
+	}
+}
+
+// dropBefore removes the rows of the output lines older than seq
+func (v *logView) dropBefore(seq uint64) {
+	n := 0
+	for n < len(v.rows) && v.rows[n].seq < seq {
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	v.rows = v.rows[n:]
+	v.dropped += n
+	// Keep showing the same rows when scrolled up
+	v.yOffset = max(v.yOffset-n, 0)
+	v.clampOffset()
+}
+
+// setRows replaces all the rows, keeping the first visible output line at
+// the top when not following the bottom
+func (v *logView) setRows(rows []row) {
+	var top uint64
+	scrolled := !v.follow && v.yOffset < len(v.rows)
+	if scrolled {
+		top = v.rows[v.yOffset].seq
+	}
+
+	v.dropped += len(v.rows)
+	v.rows = rows
+	v.yOffset = 0
+	if scrolled {
+		// Past the end when the lines from the top on are all gone, showing
+		// the closest ones
+		v.yOffset = len(rows)
+		for i, r := range rows {
+			if r.seq >= top {
+				v.yOffset = i
+				break
+			}
+		}
+	}
+	v.clampOffset()
+	if v.follow {
+		v.gotoBottom()
+	}
+}
+
+func (v *logView) reset() {
+	v.setRows(nil)
+	v.follow = true
+}
+
+func (v *logView) maxOffset() int {
+	return max(len(v.rows)-v.height, 0)
+}
+
+func (v *logView) clampOffset() {
+	v.yOffset = min(max(v.yOffset, 0), v.maxOffset())
+}
+
+// scroll moves the view n rows down, or up when negative
+func (v *logView) scroll(n int) {
+	v.yOffset += n
+	v.clampOffset()
+	v.follow = v.atBottom()
+}
+
+// scrollTo centers the row at position pos
+func (v *logView) scrollTo(pos int) {
+	v.yOffset = pos - v.dropped - v.height/2
+	v.clampOffset()
+	v.follow = v.atBottom()
+}
+
+func (v *logView) gotoBottom() {
+	v.yOffset = v.maxOffset()
+	v.follow = true
+}
+
+func (v *logView) atBottom() bool {
+	return v.yOffset >= v.maxOffset()
+}
+
+// bottom returns the position of the last visible row
+func (v *logView) bottom() int {
+	return v.dropped + min(v.yOffset+v.height, len(v.rows)) - 1
+}
+
+func (v *logView) scrollPercent() float64 {
+	if v.maxOffset() == 0 {
+		return 1
+	}
+	return float64(v.yOffset) / float64(v.maxOffset())
+}
+
+// handleKey scrolls the view for the navigation keys, returning if the key
+// was one of them
+func (v *logView) handleKey(key string) bool {
+	switch key {
+	case "up", "k":
+		v.scroll(-1)
+	case "down", "j":
+		v.scroll(1)
+	case "pgup", "b":
+		v.scroll(-v.height)
+	case "pgdown", " ":
+		v.scroll(v.height)
+	case "ctrl+u":
+		v.scroll(-v.height / 2)
+	case "ctrl+d":
+		v.scroll(v.height / 2)
+	case "home", "g":
+		v.scroll(-len(v.rows))
+	case "end", "G":
+		v.gotoBottom()
+	default:
+		return false
+	}
+	return true
+}
+
+// rowAt returns the row shown at the given line of the view
+func (v *logView) rowAt(y int) (row, bool) {
+	idx := v.yOffset + y
+	if y < 0 || y >= v.height || idx >= len(v.rows) {
+		return row{}, false
+	}
+	return v.rows[idx], true
+}
+
+// View renders the visible rows, filling the height with empty lines
+func (v *logView) View() string {
+	lines := make([]string, v.height)
+	for i := range lines {
+		if idx := v.yOffset + i; idx < len(v.rows) {
+			lines[i] = v.rows[idx].text
+		}
+	}
+	return strings.Join(lines, "\n")
+}
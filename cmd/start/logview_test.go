@@ -0,0 +1,214 @@
+package start
+
+import (
+	"fmt"
+	"testing"
+)
+
+// testRows creates a row for every seq, repeating a seq for a wrapped line
+func testRows(seqs ...uint64) []row {
+	rows := make([]row, len(seqs))
+	for i, seq := range seqs {
+		rows[i] = row{seq: seq, text: fmt.Sprintf("line %d", seq)}
+	}
+	return rows
+}
+
+func seqRange(from, to uint64) []uint64 {
+	var seqs []uint64
+	for seq := from; seq <= to; seq++ {
+		seqs = append(seqs, seq)
+	}
+	return seqs
+}
+
+// testView shows the rows scrolled to offset, or following the bottom when
+// offset is negative
+func testView(height int, seqs []uint64, offset int) *logView {
+	v := newLogView()
+	v.setSize(80, height)
+	v.append(testRows(seqs...)...)
+	if offset >= 0 {
+		v.scroll(offset - v.yOffset)
+	}
+	return v
+}
+
+// topSeq returns the line of the first visible row, zero when empty
+func topSeq(v *logView) uint64 {
+	r, ok := v.rowAt(0)
+	if !ok {
+		return 0
+	}
+	return r.seq
+}
+
+func TestLogViewDropBefore(t *testing.T) {
+	for name, tc := range map[string]struct {
+		seqs    []uint64
+		height  int
+		offset  int
+		before  uint64
+		top     uint64
+		follow  bool
+		dropped int
+	}{
+		"following the bottom": {
+			seqs:    seqRange(1, 10),
+			height:  3,
+			offset:  -1,
+			before:  4,
+			top:     8,
+			follow:  true,
+			dropped: 3,
+		},
+		"scrolled up keeps the top line": {
+			seqs:    seqRange(1, 10),
+			height:  3,
+			offset:  5,
+			before:  3,
+			top:     6,
+			dropped: 2,
+		},
+		"top line dropped": {
+			seqs:    seqRange(1, 10),
+			height:  3,
+			offset:  1,
+			before:  5,
+			top:     5,
+			dropped: 4,
+		},
+		"wrapped lines": {
+			seqs:    []uint64{1, 1, 2, 2, 2, 3, 4, 4, 5, 6},
+			height:  2,
+			offset:  5,
+			before:  3,
+			top:     3,
+			dropped: 5,
+		},
+		"nothing older": {
+			seqs:    seqRange(1, 10),
+			height:  3,
+			offset:  2,
+			before:  1,
+			top:     3,
+			dropped: 0,
+		},
+		"everything dropped": {
+			seqs:    seqRange(1, 10),
+			height:  3,
+			offset:  2,
+			before:  11,
+			top:     0,
+			follow:  false,
+			dropped: 10,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			v := testView(tc.height, tc.seqs, tc.offset)
+			v.dropBefore(tc.before)
+
+			if got := topSeq(v); got != tc.top {
+				t.Logf("expected line %d at the top, got %d", tc.top, got)
+				t.Fail()
+			}
+			if v.follow != tc.follow {
+				t.Logf("expected follow %v, got %v", tc.follow, v.follow)
+				t.Fail()
+			}
+			if v.dropped != tc.dropped {
+				t.Logf("expected %d dropped rows, got %d", tc.dropped, v.dropped)
+				t.Fail()
+			}
+			if v.yOffset < 0 || v.yOffset > v.maxOffset() {
+				t.Logf("offset %d out of range [0, %d]", v.yOffset, v.maxOffset())
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestLogViewSetRows(t *testing.T) {
+	for name, tc := range map[string]struct {
+		seqs    []uint64
+		offset  int
+		newSeqs []uint64
+		top     uint64
+		follow  bool
+	}{
+		"following the bottom": {
+			seqs:    seqRange(1, 10),
+			offset:  -1,
+			newSeqs: []uint64{1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10, 10},
+			top:     9,
+			follow:  true,
+		},
+		"scrolled keeps the top line when wrapped": {
+			seqs:    seqRange(1, 10),
+			offset:  2,
+			newSeqs: []uint64{1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10, 10},
+			top:     3,
+		},
+		"scrolled keeps the top line when unwrapped": {
+			seqs:    []uint64{1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6},
+			offset:  5,
+			newSeqs: seqRange(1, 6),
+			top:     3,
+		},
+		"top line filtered out": {
+			seqs:    seqRange(1, 10),
+			offset:  2,
+			newSeqs: []uint64{1, 2, 4, 5, 6, 7, 8, 9, 10},
+			top:     4,
+		},
+		"lines after the top filtered out": {
+			seqs:    seqRange(1, 10),
+			offset:  6,
+			newSeqs: []uint64{1, 2, 3, 4, 5, 6},
+			top:     4,
+		},
+		"everything filtered out": {
+			seqs:    seqRange(1, 10),
+			offset:  2,
+			newSeqs: nil,
+			top:     0,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			v := testView(3, tc.seqs, tc.offset)
+			dropped := v.dropped + len(v.rows)
+			v.setRows(testRows(tc.newSeqs...))
+
+			if got := topSeq(v); got != tc.top {
+				t.Logf("expected line %d at the top, got %d", tc.top, got)
+				t.Fail()
+			}
+			if v.follow != tc.follow {
+				t.Logf("expected follow %v, got %v", tc.follow, v.follow)
+				t.Fail()
+			}
+			// Positions of the new rows start after the replaced ones
+			if v.dropped != dropped {
+				t.Logf("expected %d dropped rows, got %d", dropped, v.dropped)
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestLogViewScrollToAfterDrop(t *testing.T) {
+	v := testView(3, seqRange(1, 10), -1)
+	// Line 7 is the row at position 6 before any drop
+	pos := v.dropped + 6
+	v.dropBefore(4)
+	v.scrollTo(pos)
+
+	if r, ok := v.rowAt(1); !ok || r.seq != 7 {
+		t.Logf("expected line 7 centered, got %+v", r)
+		t.Fail()
+	}
+	if v.bottom() != pos+1 {
+		t.Logf("expected the last visible position %d, got %d", pos+1, v.bottom())
+		t.Fail()
+	}
+}
@@ -0,0 +1,82 @@
+package start
+
+import (
+	"fmt"
+	"io"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jespino/mmdev/internal/config"
+	"github.com/jespino/mmdev/pkg/notify"
+	"github.com/jespino/mmdev/pkg/supervisor"
+)
+
+// notifyFailedMsg is sent when a notification couldn't be delivered
+type notifyFailedMsg struct {
+	err error
+}
+
+// newNotifier creates the notifier of the configuration. The terminal
+// notifications go to terminal when not nil, and the webhook and the
+// command are only used with external, so a detached mmdev start and the
+// TUIs attached to it don't both send them.
+func newNotifier(cfg config.NotifyConfig, terminal io.Writer, external bool) (*notify.Notifier, error) {
+	opts := notify.Options{
+		Bell:   !cfg.DisableBell,
+		OSC9:   cfg.OSC9,
+		Events: cfg.Events,
+	}
+	if external {
+		opts.Webhook = cfg.Webhook
+		opts.Command = cfg.Command
+	}
+	return notify.New(opts, terminal)
+}
+
+// eventNotification follows the health of the processes through their
+// events, returning a notification when it changes
+func eventNotification(tracker *notify.Tracker, event supervisor.Event) (notify.Notification, bool) {
+	switch event.Type {
+	case supervisor.BuildFailed:
+		return tracker.BuildFailed(event.Process, event.Err)
+	case supervisor.Ready:
+		return tracker.Ready(event.Process)
+	case supervisor.Exited:
+		switch {
+		case event.Stopped:
+			tracker.Reset(event.Process)
+		case event.Err != nil:
+			return tracker.Crashed(event.Process, event.Status)
+		}
+	}
+	return notify.Notification{}, false
+}
+
+// notify returns the command sending the notification of an event, if any.
+// The events before attaching only update the health of the processes.
+func (m *model) notify(event supervisor.Event) tea.Cmd {
+	n, ok := eventNotification(m.tracker, event)
+	if !ok || event.Time.Before(m.notifySince) || !m.notifier.Enabled(n.Event) {
+		return nil
+	}
+	notifier := m.notifier
+	return func() tea.Msg {
+		if err := notifier.Send(n); err != nil {
+			return notifyFailedMsg{err: err}
+		}
+		return nil
+	}
+}
+
+// notifyEvent sends the notification of an event of the detached mmdev
+// start in the background, logging the failures
+func notifyEvent(notifier *notify.Notifier, tracker *notify.Tracker, event supervisor.Event) {
+	n, ok := eventNotification(tracker, event)
+	if !ok || !notifier.Enabled(n.Event) {
+		return
+	}
+	go func() {
+		if err := notifier.Send(n); err != nil {
+			fmt.Printf("Failed to notify %q: %v\n", n.Message(), err)
+		}
+	}()
+}
@@ -0,0 +1,394 @@
+package start
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jespino/mmdev/internal/config"
+	"github.com/jespino/mmdev/pkg/editor"
+	"github.com/jespino/mmdev/pkg/mmlog"
+	"github.com/jespino/mmdev/pkg/procstat"
+	"github.com/jespino/mmdev/pkg/supervisor"
+)
+
+// defaultScrollback is the number of output lines kept per pane when not
+// configured
+const defaultScrollback = 10000
+
+// defaultPanes are used when no panes are configured in ~/.mmdev.toml
+var defaultPanes = []config.PaneConfig{
+	{Name: serverPane},
+	{Name: webappPane},
+	{Name: dockerPane},
+}
+
+var signals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
+
+// resolvePanes validates the configured panes, falling back to the default
+// ones, and keeps only the given names, in that order, when any
+func resolvePanes(configured []config.PaneConfig, only []string) ([]config.PaneConfig, error) {
+	if len(configured) == 0 {
+		configured = defaultPanes
+	}
+
+	byName := make(map[string]config.PaneConfig)
+	var names []string
+	for _, pane := range configured {
+		if pane.Name == "" {
+			return nil, fmt.Errorf("pane without name in the start configuration")
+		}
+		if _, ok := byName[pane.Name]; ok {
+			return nil, fmt.Errorf("duplicated pane %q", pane.Name)
+		}
+		if len(pane.Command) == 0 && !isBuiltin(pane.Name) {
+			return nil, fmt.Errorf("pane %q has no command, only the %s, %s and %s panes can omit it", pane.Name, serverPane, webappPane, dockerPane)
+		}
+		if _, err := parseSignal(pane.RestartSignal); err != nil {
+			return nil, fmt.Errorf("pane %q: %w", pane.Name, err)
+		}
+		if _, err := newLogFilter(pane.Filter, pane.Level); err != nil {
+			return nil, fmt.Errorf("pane %q: %w", pane.Name, err)
+		}
+		if _, _, err := compileReadiness(pane); err != nil {
+			return nil, fmt.Errorf("pane %q: %w", pane.Name, err)
+		}
+		byName[pane.Name] = pane
+		names = append(names, pane.Name)
+	}
+
+	if len(only) == 0 {
+		return configured, nil
+	}
+	var panes []config.PaneConfig
+	for _, name := range only {
+		pane, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown pane %q, available panes: %s", name, strings.Join(names, ", "))
+		}
+		panes = append(panes, pane)
+	}
+	return panes, nil
+}
+
+// parseSignal parses a signal name, with or without the SIG prefix
+func parseSignal(name string) (syscall.Signal, error) {
+	if name == "" {
+		return 0, nil
+	}
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	signal, ok := signals[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported signal %q", name)
+	}
+	return signal, nil
+}
+
+// pane is a process and the view with its output
+type pane struct {
+	config.PaneConfig
+
+	// lines are the last lines of output of the process, view shows them
+	// filtered, wrapped and highlighted
+	lines *ringBuffer
+	view  *logView
+	seq   uint64
+	// log receives all the output, nil when the session logs are disabled
+	log io.Writer
+
+	filter logFilter
+	search *regexp.Regexp
+	// matches are the positions in the view of the rows matching the
+	// search, match the current one
+	matches []int
+	match   int
+	// locations are the file locations found in the output, location the
+	// selected one. resolved caches the path of their files, empty when
+	// they don't exist.
+	locations []sourceRef
+	location  int
+	resolved  map[string]string
+
+	// The state of the process, from the supervisor events of its current
+	// run. pid is the one of its program, zero when not known.
+	alive      bool
+	run        int
+	pid        int
+	state      paneState
+	exitStatus string
+	startedAt  time.Time
+	restarts   int
+	// usage is the last resource usage of the process tree, cpu the CPU
+	// percentage since the previous one
+	usage     procstat.Usage
+	cpu       float64
+	hasUsage  bool
+	sampledAt time.Time
+}
+
+// logLine is a line of output of a process, or a divider marking a point in
+// time, added by the user or on restarts, whose text is an optional label
+type logLine struct {
+	seq     uint64
+	text    string
+	divider bool
+	time    time.Time
+}
+
+func newPane(cfg config.PaneConfig, scrollback int) (*pane, error) {
+	filter, err := newLogFilter(cfg.Filter, cfg.Level)
+	if err != nil {
+		return nil, fmt.Errorf("pane %q: %w", cfg.Name, err)
+	}
+	return &pane{
+		PaneConfig: cfg,
+		lines:      newRingBuffer(scrollback),
+		view:       newLogView(),
+		filter:     filter,
+		match:      -1,
+		location:   -1,
+		resolved:   make(map[string]string),
+	}, nil
+}
+
+func (p *pane) autostart() bool {
+	return autostart(p.PaneConfig)
+}
+
+// autostart checks if the process of a pane starts with the TUI
+func autostart(cfg config.PaneConfig) bool {
+	return cfg.Autostart == nil || *cfg.Autostart
+}
+
+// running checks if the process of the pane is alive
+func (p *pane) running() bool {
+	return p.alive
+}
+
+// handleEvent updates the pane with an event of its process
+func (p *pane) handleEvent(event supervisor.Event) {
+	if line, ok := eventLine(event); ok {
+		p.appendLine(line)
+	}
+	switch event.Type {
+	case supervisor.Starting:
+		// The runs before attaching to a detached mmdev start count too
+		if event.Run > 1 {
+			p.restarts = max(p.restarts+1, event.Run-1)
+		}
+		p.alive = true
+		p.run = event.Run
+		p.pid = 0
+		p.state = stateStarting
+		p.exitStatus = ""
+		p.startedAt = event.Time
+		p.hasUsage = false
+	case supervisor.Running:
+		p.pid = event.Pid
+	case supervisor.Building:
+		p.state = stateBuilding
+	case supervisor.BuildFailed:
+		p.state = stateBuildFailed
+	case supervisor.Ready:
+		p.state = stateRunning
+	case supervisor.Restarting:
+		p.restarts++
+		p.state = stateStarting
+		p.startedAt = event.Time
+	case supervisor.Exited:
+		p.alive = false
+		p.exited(event)
+	}
+}
+
+// eventLine returns the line added to the output for an event, false when
+// there is none
+func eventLine(event supervisor.Event) (string, bool) {
+	switch event.Type {
+	case supervisor.Output:
+		return event.Line, true
+	case supervisor.BuildFailed:
+		return fmt.Sprintf("Build failed: %v", event.Err), true
+	case supervisor.Exited:
+		if event.Err == nil {
+			return "Process exited", true
+		}
+		return fmt.Sprintf("Process exited: %v", event.Err), true
+	}
+	return "", false
+}
+
+// exited records how the process exited
+func (p *pane) exited(event supervisor.Event) {
+	switch {
+	case event.Stopped:
+		p.state = stateStopped
+	case p.state == stateBuildFailed:
+	case event.Err == nil:
+		p.state = stateExited
+	default:
+		p.state = stateCrashed
+		p.exitStatus = event.Status
+	}
+}
+
+// appendLine adds a line of output to the pane
+func (p *pane) appendLine(line string) {
+	p.appendLogLine(logLine{text: line})
+}
+
+// appendDivider adds a marker with the current time and an optional label
+func (p *pane) appendDivider(label string) {
+	p.appendLogLine(logLine{divider: true, text: label, time: time.Now()})
+}
+
+func (p *pane) appendLogLine(line logLine) {
+	p.seq++
+	line.seq = p.seq
+	var logErr error
+	if p.log != nil {
+		if logErr = writeLogLine(p.log, line); logErr != nil {
+			p.log = nil
+		}
+	}
+	if evicted, ok := p.lines.push(line); ok {
+		p.view.dropBefore(evicted.seq + 1)
+		p.dropMatches()
+	}
+
+	rendered := p.renderLine(line)
+	p.track(line.seq, p.view.dropped+len(p.view.rows), rendered)
+	p.view.append(rendered.rows...)
+
+	if logErr != nil {
+		p.appendLine(fmt.Sprintf("Error writing the session log, disabling it: %v", logErr))
+	}
+}
+
+func (p *pane) clear() {
+	p.lines.reset()
+	p.view.reset()
+	p.matches = nil
+	p.match = -1
+	p.locations = nil
+	p.location = -1
+}
+
+// renderedLine is an output line as shown in the view
+type renderedLine struct {
+	rows []row
+	// matched is set when the line matches the search
+	matched   bool
+	locations []editor.Match
+}
+
+// track records the search match and the locations of a line whose first
+// row is at position pos
+func (p *pane) track(seq uint64, pos int, rendered renderedLine) {
+	if rendered.matched {
+		p.matches = append(p.matches, pos)
+	}
+	for _, match := range rendered.locations {
+		p.locations = append(p.locations, sourceRef{seq: seq, pos: pos, loc: match.Location})
+	}
+}
+
+// renderLine renders a line when it passes the filter, wrapped to the view
+// width and with the search matches, or else the file locations,
+// highlighted
+func (p *pane) renderLine(line logLine) renderedLine {
+	width := p.view.width
+	if line.divider {
+		return renderedLine{rows: []row{{seq: line.seq, text: dividerStyle.Render(dividerText(line, width))}}}
+	}
+	if !p.filter.match(line.text) {
+		return renderedLine{}
+	}
+
+	var rendered renderedLine
+	plain := mmlog.StripANSI(line.text)
+	rendered.matched = p.search != nil && p.search.MatchString(plain)
+	rendered.locations = p.findLocations(plain)
+	if !rendered.matched && len(rendered.locations) == 0 {
+		for _, wrapped := range wrapLine(line.text, width) {
+			rendered.rows = append(rendered.rows, row{seq: line.seq, text: wrapped})
+		}
+		return rendered
+	}
+	for _, wrapped := range wrapLine(plain, width) {
+		text := underlineLocations(wrapped, rendered.locations)
+		if rendered.matched {
+			text = highlight(wrapped, p.search)
+		}
+		rendered.rows = append(rendered.rows, row{seq: line.seq, text: text})
+	}
+	return rendered
+}
+
+// rerender renders all the lines again, after the filter, the search or the
+// width change
+func (p *pane) rerender() {
+	// The view counts the replaced rows as dropped, so the new ones start
+	// at this position
+	base := p.view.dropped + len(p.view.rows)
+
+	p.matches = nil
+	p.locations = nil
+	var rows []row
+	for i := 0; i < p.lines.len(); i++ {
+		line := p.lines.at(i)
+		rendered := p.renderLine(line)
+		p.track(line.seq, base+len(rows), rendered)
+		rows = append(rows, rendered.rows...)
+	}
+	p.view.setRows(rows)
+	if p.match >= len(p.matches) {
+		p.match = len(p.matches) - 1
+	}
+	if p.location >= len(p.locations) {
+		p.location = len(p.locations) - 1
+	}
+}
+
+// dividerText renders a divider filling the width with its time and label
+func dividerText(line logLine, width int) string {
+	text := "=== " + line.time.Format("15:04:05")
+	if line.text != "" {
+		text += " " + line.text
+	}
+	text += " "
+	if len(text) >= width {
+		return strings.Repeat("=", width)
+	}
+	return text + strings.Repeat("=", width-len(text))
+}
+
+// dropMatches forgets the search matches and the locations in rows dropped
+// from the view
+func (p *pane) dropMatches() {
+	n := 0
+	for n < len(p.matches) && p.matches[n] < p.view.dropped {
+		n++
+	}
+	p.matches = p.matches[n:]
+	p.match = max(p.match-n, -1)
+
+	n = 0
+	for n < len(p.locations) && p.locations[n].pos < p.view.dropped {
+		n++
+	}
+	p.locations = p.locations[n:]
+	p.location = max(p.location-n, -1)
+}
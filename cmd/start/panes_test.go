@@ -0,0 +1,143 @@
+package start
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jespino/mmdev/internal/config"
+)
+
+func TestPaneMatchesAfterEviction(t *testing.T) {
+	for name, tc := range map[string]struct {
+		lines []string
+		// selectAt selects the last match and location once that many lines
+		// were added
+		selectAt  int
+		search    string
+		width     int
+		matches   []string
+		match     string
+		locations []string
+		location  string
+	}{
+		"no eviction": {
+			lines:     []string{"match a", "main.go:1 x", "match b"},
+			selectAt:  3,
+			matches:   []string{"match a", "match b"},
+			match:     "match b",
+			locations: []string{"main.go:1 x"},
+			location:  "main.go:1 x",
+		},
+		"evicted matches and locations": {
+			lines:     []string{"match a main.go:1", "x", "match b", "main.go:2 y", "match c", "z"},
+			selectAt:  5,
+			matches:   []string{"match b", "match c"},
+			match:     "match c",
+			locations: []string{"main.go:2 y"},
+			location:  "main.go:2 y",
+		},
+		"selected match evicted": {
+			lines:     []string{"match a main.go:1", "x", "y", "z", "match b"},
+			selectAt:  1,
+			matches:   []string{"match b"},
+			match:     "",
+			locations: nil,
+			location:  "",
+		},
+		"wrapped lines": {
+			lines:     []string{"match a long enough to wrap", "main.go:3 also long enough to wrap", "x", "match b long enough to wrap", "main.go:4 y"},
+			selectAt:  4,
+			width:     10,
+			matches:   []string{"match b long enough to wrap"},
+			match:     "match b long enough to wrap",
+			locations: []string{"main.go:3 also long enough to wrap", "main.go:4 y"},
+			location:  "main.go:3 also long enough to wrap",
+		},
+		"rerendered after eviction": {
+			lines:     []string{"match a", "x main.go:5", "match b", "y", "match c"},
+			selectAt:  5,
+			search:    "match b|y",
+			matches:   []string{"match b", "y"},
+			match:     "y",
+			locations: []string{"x main.go:5"},
+			location:  "x main.go:5",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, err := newPane(config.PaneConfig{Name: "test", Command: []string{"true"}}, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.resolved["main.go"] = "main.go"
+			width := tc.width
+			if width == 0 {
+				width = 80
+			}
+			p.view.setSize(width, 3)
+			p.setSearch(regexp.MustCompile("match"))
+
+			for i, line := range tc.lines {
+				p.appendLine(line)
+				if i+1 == tc.selectAt {
+					p.match = len(p.matches) - 1
+					p.location = len(p.locations) - 1
+				}
+			}
+			if tc.search != "" {
+				p.setSearch(regexp.MustCompile(tc.search))
+				p.match = len(p.matches) - 1
+			}
+
+			// The line whose first row is at a position of the view
+			lineAt := func(pos int) string {
+				idx := pos - p.view.dropped
+				if idx < 0 || idx >= len(p.view.rows) {
+					t.Fatalf("position %d out of the view", pos)
+				}
+				seq := p.view.rows[idx].seq
+				if idx > 0 && p.view.rows[idx-1].seq == seq {
+					t.Fatalf("position %d is not the first row of line %d", pos, seq)
+				}
+				for i := 0; i < p.lines.len(); i++ {
+					if line := p.lines.at(i); line.seq == seq {
+						return line.text
+					}
+				}
+				t.Fatalf("line %d of position %d not kept", seq, pos)
+				return ""
+			}
+
+			var matches []string
+			for _, pos := range p.matches {
+				matches = append(matches, lineAt(pos))
+			}
+			var locations []string
+			for _, ref := range p.locations {
+				text := lineAt(ref.pos)
+				if !strings.Contains(text, ref.loc.File) {
+					t.Logf("location %v found in line %q", ref.loc, text)
+					t.Fail()
+				}
+				locations = append(locations, text)
+			}
+			match, location := "", ""
+			if p.match >= 0 {
+				match = matches[p.match]
+			}
+			if p.location >= 0 {
+				location = locations[p.location]
+			}
+
+			if !reflect.DeepEqual(matches, tc.matches) || match != tc.match {
+				t.Logf("expected matches %q with %q selected, got %q with %q", tc.matches, tc.match, matches, match)
+				t.Fail()
+			}
+			if !reflect.DeepEqual(locations, tc.locations) || location != tc.location {
+				t.Logf("expected locations %q with %q selected, got %q with %q", tc.locations, tc.location, locations, location)
+				t.Fail()
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package start
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/jespino/mmdev/internal/config"
+	"github.com/jespino/mmdev/pkg/docker"
+	"github.com/jespino/mmdev/pkg/mmlog"
+	"github.com/jespino/mmdev/pkg/server"
+	"github.com/jespino/mmdev/pkg/supervisor"
+	"github.com/jespino/mmdev/pkg/webapp"
+)
+
+// Panes without command named like these run the docker services, the
+// server or the webapp in process
+const (
+	dockerPane = "docker"
+	serverPane = "server"
+	webappPane = "webapp"
+)
+
+// webpackPatterns tell the state of the webapp from the webpack output
+var webpackPatterns = supervisor.Patterns{
+	Building:    regexp.MustCompile(`\[webpack\.Progress\]`),
+	Ready:       regexp.MustCompile(`compiled (successfully|with \d+ warnings?\b)`),
+	BuildFailed: regexp.MustCompile(`compiled with \d+ errors?`),
+}
+
+func isBuiltin(name string) bool {
+	return name == dockerPane || name == serverPane || name == webappPane
+}
+
+// newSupervisor returns the supervisor running the processes of the panes.
+// It stops them in the reverse order they were added, so the docker services
+// the others depend on are added first.
+func newSupervisor(panes []config.PaneConfig) (*supervisor.Supervisor, error) {
+	var ordered []config.PaneConfig
+	for _, pane := range panes {
+		if pane.Name == dockerPane && len(pane.Command) == 0 {
+			ordered = append([]config.PaneConfig{pane}, ordered...)
+		} else {
+			ordered = append(ordered, pane)
+		}
+	}
+
+	s := supervisor.New()
+	for _, pane := range ordered {
+		process, err := newProcess(pane, s)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Add(pane.Name, process); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// newProcess returns the process run by a pane: the command when there is
+// one, otherwise the docker services, the server or the webapp
+func newProcess(cfg config.PaneConfig, s *supervisor.Supervisor) (supervisor.Process, error) {
+	if len(cfg.Command) == 0 {
+		switch cfg.Name {
+		case dockerPane:
+			return &dockerProcess{}, nil
+		case serverPane:
+			return &serverProcess{supervisor: s}, nil
+		case webappPane:
+			return &webappProcess{}, nil
+		}
+		return nil, fmt.Errorf("pane %q has no command", cfg.Name)
+	}
+
+	signal, err := parseSignal(cfg.RestartSignal)
+	if err != nil {
+		return nil, fmt.Errorf("pane %q: %w", cfg.Name, err)
+	}
+	building, ready, err := compileReadiness(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("pane %q: %w", cfg.Name, err)
+	}
+	var env []string
+	for key, value := range cfg.Env {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+	return &supervisor.Command{
+		Args:            cfg.Command,
+		Dir:             cfg.Dir,
+		Env:             env,
+		RestartSignal:   signal,
+		BuildingPattern: building,
+		ReadyPattern:    ready,
+		ReadyURL:        cfg.ReadyURL,
+	}, nil
+}
+
+// dockerProcess starts the default docker services and stops them when
+// stopped
+type dockerProcess struct{}
+
+func (d *dockerProcess) Run(ctx context.Context, r *supervisor.Reporter) error {
+	manager, err := docker.NewManager()
+	if err != nil {
+		return fmt.Errorf("failed to create docker manager: %w", err)
+	}
+	manager.SetOutput(r.Output())
+	manager.SetupDefaultServices()
+
+	startErr := manager.Start()
+	if startErr == nil && ctx.Err() == nil {
+		r.Ready()
+		<-ctx.Done()
+	}
+	if err := manager.Stop(); err != nil {
+		r.Printf("Warning: failed to stop docker services: %v", err)
+	}
+	return startErr
+}
+
+// serverProcess builds and runs the server, once the docker services are
+// ready when they run in another pane
+type serverProcess struct {
+	supervisor *supervisor.Supervisor
+}
+
+func (sp *serverProcess) Run(ctx context.Context, r *supervisor.Reporter) error {
+	manager := server.NewManager("./server")
+	manager.SetOutput(r.Output())
+	manager.SetLogOutput(mmlog.NewRenderer(r.Output(), mmlog.Options{}))
+
+	r.Building()
+	if err := manager.Build(server.BinaryPath); err != nil {
+		r.BuildFailed(err)
+		return err
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	if sp.supervisor.Has(dockerPane) {
+		r.Printf("Waiting for the docker services...")
+		if err := sp.supervisor.WaitReady(ctx, dockerPane); err != nil {
+			return nil
+		}
+	}
+
+	cmd, err := manager.Run()
+	if err != nil {
+		return err
+	}
+	r.Running(cmd.Process.Pid)
+	go r.PollReady(ctx, manager.URL()+"/api/v4/system/ping")
+	err = supervisor.Wait(ctx, cmd)
+	manager.FlushLogs()
+	return err
+}
+
+// webappProcess installs the webapp dependencies and builds it watching for
+// changes
+type webappProcess struct{}
+
+func (w *webappProcess) Run(ctx context.Context, r *supervisor.Reporter) error {
+	manager := webapp.NewManager("./webapp")
+	manager.SetOutput(r.Output())
+	r.Watch(webpackPatterns)
+
+	cmd, err := manager.Run(true)
+	if err != nil {
+		return err
+	}
+	r.Running(cmd.Process.Pid)
+	return supervisor.Wait(ctx, cmd)
+}
@@ -0,0 +1,41 @@
+package start
+
+// ringBuffer keeps the last lines of output of a process, dropping the
+// oldest ones once full
+type ringBuffer struct {
+	lines []logLine
+	start int
+	size  int
+}
+
+func newRingBuffer(capacity int) *ringBuffer {
+	return &ringBuffer{lines: make([]logLine, capacity)}
+}
+
+// push adds a line, returning the line it evicted if the buffer was full
+func (r *ringBuffer) push(line logLine) (logLine, bool) {
+	if r.size < len(r.lines) {
+		r.lines[(r.start+r.size)%len(r.lines)] = line
+		r.size++
+		return logLine{}, false
+	}
+	evicted := r.lines[r.start]
+	r.lines[r.start] = line
+	r.start = (r.start + 1) % len(r.lines)
+	return evicted, true
+}
+
+// at returns the i-th line, from the oldest
+func (r *ringBuffer) at(i int) logLine {
+	return r.lines[(r.start+i)%len(r.lines)]
+}
+
+func (r *ringBuffer) len() int {
+	return r.size
+}
+
+func (r *ringBuffer) reset() {
+	clear(r.lines)
+	r.start = 0
+	r.size = 0
+}
@@ -0,0 +1,87 @@
+package start
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRingBuffer(t *testing.T) {
+	for name, tc := range map[string]struct {
+		capacity int
+		pushes   int
+		// reset empties the buffer after the pushes, before pushing again
+		reset    bool
+		again    int
+		evicted  []uint64
+		expected []uint64
+	}{
+		"not full": {
+			capacity: 3,
+			pushes:   2,
+			expected: []uint64{1, 2},
+		},
+		"exactly full": {
+			capacity: 3,
+			pushes:   3,
+			expected: []uint64{1, 2, 3},
+		},
+		"evicts the oldest": {
+			capacity: 3,
+			pushes:   5,
+			evicted:  []uint64{1, 2},
+			expected: []uint64{3, 4, 5},
+		},
+		"wraps around several times": {
+			capacity: 3,
+			pushes:   10,
+			evicted:  []uint64{1, 2, 3, 4, 5, 6, 7},
+			expected: []uint64{8, 9, 10},
+		},
+		"single line": {
+			capacity: 1,
+			pushes:   3,
+			evicted:  []uint64{1, 2},
+			expected: []uint64{3},
+		},
+		"reset": {
+			capacity: 3,
+			pushes:   4,
+			reset:    true,
+			again:    2,
+			evicted:  []uint64{1},
+			expected: []uint64{5, 6},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := newRingBuffer(tc.capacity)
+			var evicted []uint64
+			seq := uint64(0)
+			push := func(n int) {
+				for i := 0; i < n; i++ {
+					seq++
+					if line, ok := r.push(logLine{seq: seq}); ok {
+						evicted = append(evicted, line.seq)
+					}
+				}
+			}
+			push(tc.pushes)
+			if tc.reset {
+				r.reset()
+			}
+			push(tc.again)
+
+			var lines []uint64
+			for i := 0; i < r.len(); i++ {
+				lines = append(lines, r.at(i).seq)
+			}
+			if !reflect.DeepEqual(evicted, tc.evicted) {
+				t.Logf("expected evicted %v, got %v", tc.evicted, evicted)
+				t.Fail()
+			}
+			if !reflect.DeepEqual(lines, tc.expected) {
+				t.Logf("expected lines %v, got %v", tc.expected, lines)
+				t.Fail()
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package start
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jespino/mmdev/pkg/mmlog"
+)
+
+var matchStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("#000000")).
+	Background(lipgloss.Color("#FFD700"))
+
+// levelFilters are the minimum levels the level key cycles through
+var levelFilters = []string{"", "debug", "info", "warn", "error"}
+
+// logFilter hides the lines of a pane not matching a pattern or below a
+// log level
+type logFilter struct {
+	pattern *regexp.Regexp
+	level   string
+}
+
+func newLogFilter(pattern, level string) (logFilter, error) {
+	var filter logFilter
+	if err := filter.setPattern(pattern); err != nil {
+		return filter, err
+	}
+	if err := filter.setLevel(level); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// setPattern sets the regular expression lines must match, an empty pattern
+// disables it
+func (f *logFilter) setPattern(pattern string) error {
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid filter: %w", err)
+	}
+	f.pattern = re
+	return nil
+}
+
+// setLevel sets the minimum log level, empty or all disables it
+func (f *logFilter) setLevel(level string) error {
+	level = strings.ToLower(level)
+	if level == "all" {
+		level = ""
+	}
+	if level != "" && !isLevel(level) {
+		return fmt.Errorf("unknown log level %q, expected one of %s", level, strings.Join(mmlog.Levels, ", "))
+	}
+	f.level = level
+	return nil
+}
+
+// match checks if a line passes the filter. Lines without level, like
+// stack traces, are only subject to the pattern.
+func (f logFilter) match(line string) bool {
+	if f.pattern != nil && !f.pattern.MatchString(mmlog.StripANSI(line)) {
+		return false
+	}
+	return f.level == "" || mmlog.MatchLevel(mmlog.LineLevel(line), f.level)
+}
+
+func (f logFilter) String() string {
+	var parts []string
+	if f.pattern != nil {
+		parts = append(parts, "filter /"+displayPattern(f.pattern)+"/")
+	}
+	if f.level != "" {
+		parts = append(parts, f.level+"+")
+	}
+	return strings.Join(parts, " ")
+}
+
+func isLevel(level string) bool {
+	for _, l := range mmlog.Levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// compilePattern compiles a search or filter pattern, ignoring case when it
+// is all lowercase. An empty pattern returns nil.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil || strings.ToLower(pattern) != pattern {
+		return re, err
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// displayPattern returns a pattern as typed, without the flag added by
+// compilePattern
+func displayPattern(re *regexp.Regexp) string {
+	return strings.TrimPrefix(re.String(), "(?i)")
+}
+
+// highlight renders the matches of re in a line without colors
+func highlight(line string, re *regexp.Regexp) string {
+	return re.ReplaceAllStringFunc(line, func(match string) string {
+		return matchStyle.Render(match)
+	})
+}
+
+// setSearch highlights the lines matching re and moves to the last match
+// visible or above, nil clears the search
+func (p *pane) setSearch(re *regexp.Regexp) {
+	p.search = re
+	p.match = -1
+	p.rerender()
+	if len(p.matches) == 0 {
+		return
+	}
+
+	bottom := p.view.bottom()
+	p.match = 0
+	for i, line := range p.matches {
+		if line <= bottom {
+			p.match = i
+		}
+	}
+	p.view.scrollTo(p.matches[p.match])
+}
+
+// nextMatch moves to the following match when forward, or the previous
+// one, wrapping around
+func (p *pane) nextMatch(forward bool) {
+	if len(p.matches) == 0 {
+		return
+	}
+	switch {
+	case p.match < 0:
+		p.match = len(p.matches) - 1
+	case forward:
+		p.match = (p.match + 1) % len(p.matches)
+	default:
+		p.match = (p.match + len(p.matches) - 1) % len(p.matches)
+	}
+	p.view.scrollTo(p.matches[p.match])
+}
+
+// searchStatus describes the search for the pane title
+func (p *pane) searchStatus() string {
+	if p.search == nil {
+		return ""
+	}
+	if len(p.matches) == 0 {
+		return fmt.Sprintf("/%s/ no matches", displayPattern(p.search))
+	}
+	return fmt.Sprintf("/%s/ %d/%d", displayPattern(p.search), p.match+1, len(p.matches))
+}
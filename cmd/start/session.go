@@ -0,0 +1,178 @@
+package start
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/jespino/mmdev/internal/config"
+	"github.com/jespino/mmdev/pkg/rotatelog"
+)
+
+// sessionNameFormat names the session directories after their start time
+const sessionNameFormat = "20060102-150405"
+
+// Defaults of the session logs when not configured
+const (
+	defaultKeepSessions = 10
+	defaultLogMaxSize   = 10 // MB
+	defaultLogMaxFiles  = 3
+)
+
+// session is the directory where the output of every pane is logged while
+// the TUI runs, and where the saved logs go by default
+type session struct {
+	dir  string
+	logs map[string]*rotatelog.Writer
+}
+
+// defaultSessionsDir returns where the sessions are kept when not configured
+func defaultSessionsDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "mmdev", "sessions"), nil
+}
+
+// newSession creates the directory of a new session, removing the oldest
+// ones, and opens the log of every pane unless the logs are disabled
+func newSession(cfg config.StartConfig, panes []config.PaneConfig) (*session, error) {
+	baseDir := cfg.SessionDir
+	if baseDir == "" {
+		var err error
+		if baseDir, err = defaultSessionsDir(); err != nil {
+			return nil, err
+		}
+	}
+	s := &session{
+		dir:  filepath.Join(baseDir, time.Now().Format(sessionNameFormat)),
+		logs: make(map[string]*rotatelog.Writer),
+	}
+	if cfg.DisableLogs {
+		return s, nil
+	}
+
+	keep := cfg.KeepSessions
+	if keep <= 0 {
+		keep = defaultKeepSessions
+	}
+	if err := pruneSessions(baseDir, keep-1); err != nil {
+		return nil, err
+	}
+
+	maxSize := cfg.LogMaxSize
+	if maxSize <= 0 {
+		maxSize = defaultLogMaxSize
+	}
+	maxFiles := cfg.LogMaxFiles
+	if maxFiles <= 0 {
+		maxFiles = defaultLogMaxFiles
+	}
+	for _, pane := range panes {
+		log, err := rotatelog.Open(filepath.Join(s.dir, pane.Name+".log"), int64(maxSize)*1024*1024, maxFiles)
+		if err != nil {
+			s.close()
+			return nil, err
+		}
+		s.logs[pane.Name] = log
+	}
+	return s, nil
+}
+
+// pruneSessions removes the oldest session directories, keeping the given
+// number of them
+func pruneSessions(baseDir string, keep int) error {
+	entries, err := os.ReadDir(baseDir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	// Only consider the directories named like a session, in case the
+	// directory is shared
+	var sessions []string
+	for _, entry := range entries {
+		if _, err := time.Parse(sessionNameFormat, entry.Name()); err == nil && entry.IsDir() {
+			sessions = append(sessions, entry.Name())
+		}
+	}
+	// Session names are timestamps, so they sort by age
+	sort.Strings(sessions)
+	for len(sessions) > keep {
+		if err := os.RemoveAll(filepath.Join(baseDir, sessions[0])); err != nil {
+			return fmt.Errorf("failed to remove old session: %w", err)
+		}
+		sessions = sessions[1:]
+	}
+	return nil
+}
+
+// log returns the writer of the session log of a pane, nil when disabled
+func (s *session) log(pane string) io.Writer {
+	if log, ok := s.logs[pane]; ok {
+		return log
+	}
+	return nil
+}
+
+func (s *session) logging() bool {
+	return len(s.logs) > 0
+}
+
+func (s *session) close() {
+	for _, log := range s.logs {
+		log.Close()
+	}
+}
+
+// savePath returns the path where to save the logs of a pane when no file is
+// given
+func (s *session) savePath(pane string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s-%s.log", pane, time.Now().Format("150405")))
+}
+
+// writeLogLine writes a line as plain text, dividers as a marker with their
+// time
+func writeLogLine(w io.Writer, line logLine) error {
+	var err error
+	if line.divider {
+		marker := line.time.Format("2006-01-02 15:04:05")
+		if line.text != "" {
+			marker += " " + line.text
+		}
+		_, err = fmt.Fprintf(w, "===== %s =====\n", marker)
+	} else {
+		_, err = io.WriteString(w, ansi.Strip(line.text)+"\n")
+	}
+	return err
+}
+
+// saveLogs writes the lines kept by a pane to a file
+func saveLogs(path string, lines *ringBuffer) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for i := 0; i < lines.len(); i++ {
+		if err := writeLogLine(w, lines.at(i)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -1,6 +1,11 @@
 package start
 
 import (
+	"fmt"
+	"os"
+
+	"github.com/jespino/mmdev/internal/config"
+	"github.com/jespino/mmdev/pkg/control"
 	"github.com/spf13/cobra"
 )
 
@@ -8,12 +13,93 @@ func StartCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "start",
 		Short: "Start the development environment",
+		Long: `Start the development environment in a terminal UI with a pane per process.
+
+By default it runs the docker services, the server and the webapp. Other
+processes, like docker logs or a plugin watch, can be added as panes in the
+[start] section of ~/.mmdev.toml.
+
+With --detach the processes run in the background, surviving the terminal.
+Use mmdev attach to show them and mmdev ctl to control them.`,
 		Annotations: map[string]string{
 			"requiresMMRepo": "true",
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return StartTUI()
+			cfg, err := startConfig(cmd)
+			if err != nil {
+				return err
+			}
+			startCfg := cfg.Start
+			if noLogs, _ := cmd.Flags().GetBool("no-session-logs"); noLogs {
+				startCfg.DisableLogs = true
+			}
+
+			only, _ := cmd.Flags().GetStringSlice("panes")
+			panes, err := resolvePanes(startCfg.Panes, only)
+			if err != nil {
+				return err
+			}
+
+			socket, err := socketPath(cmd)
+			if err != nil {
+				return err
+			}
+			if daemon, _ := cmd.Flags().GetBool("daemon"); daemon {
+				// Attached TUIs notify their terminals
+				notifier, err := newNotifier(cfg.Notify, nil, true)
+				if err != nil {
+					return err
+				}
+				return runDaemon(startCfg, panes, socket, notifier)
+			}
+			if control.Running(socket) {
+				return fmt.Errorf("mmdev start is already running in the background for this repository, attach with mmdev attach")
+			}
+			if detach, _ := cmd.Flags().GetBool("detach"); detach {
+				return startDetached(socket)
+			}
+			notifier, err := newNotifier(cfg.Notify, os.Stdout, true)
+			if err != nil {
+				return err
+			}
+			return StartTUI(startCfg, panes, notifier)
 		},
 	}
+	cmd.Flags().String("layout", "", "Arrangement of the panes: rows, columns or grid (default: rows)")
+	cmd.Flags().Int("scrollback", 0, "Number of lines of output kept per pane (default: 10000)")
+	cmd.Flags().StringSlice("panes", nil, "Comma separated names of the panes to run (default: all)")
+	cmd.Flags().Bool("no-session-logs", false, "Don't write the output of the panes to the session log files")
+	cmd.Flags().Bool("detach", false, "Run the processes in the background, see mmdev attach and mmdev ctl")
+	cmd.Flags().String("socket", "", "Control socket of the processes running in the background (default: one per repository)")
+	// Set when running in the background, started by --detach
+	cmd.Flags().Bool("daemon", false, "")
+	cmd.Flags().MarkHidden("daemon")
 	return cmd
 }
+
+// startConfig loads the configuration, with the start section overridden by
+// the flags
+func startConfig(cmd *cobra.Command) (*config.Config, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	startCfg := &cfg.Start
+	if layout, _ := cmd.Flags().GetString("layout"); layout != "" {
+		startCfg.Layout = layout
+	}
+	if startCfg.Layout == "" {
+		startCfg.Layout = layouts[0]
+	}
+	if err := validateLayout(startCfg.Layout); err != nil {
+		return nil, err
+	}
+	if scrollback, _ := cmd.Flags().GetInt("scrollback"); scrollback > 0 {
+		startCfg.Scrollback = scrollback
+	}
+	if startCfg.Scrollback <= 0 {
+		startCfg.Scrollback = defaultScrollback
+	}
+	return cfg, nil
+}
@@ -0,0 +1,157 @@
+package start
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jespino/mmdev/internal/config"
+	"github.com/jespino/mmdev/pkg/procstat"
+)
+
+// statsInterval is how often the resource usage of the processes is checked
+const statsInterval = 2 * time.Second
+
+var (
+	statusStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("241")).
+			Padding(0, 1)
+
+	stateColors = map[paneState]lipgloss.Color{
+		stateStopped:     lipgloss.Color("241"),
+		stateStarting:    lipgloss.Color("#FFD700"),
+		stateBuilding:    lipgloss.Color("#FFD700"),
+		stateBuildFailed: lipgloss.Color("#FF4040"),
+		stateRunning:     lipgloss.Color("#32CD32"),
+		stateExited:      lipgloss.Color("241"),
+		stateCrashed:     lipgloss.Color("#FF4040"),
+	}
+)
+
+type paneState int
+
+const (
+	stateStopped paneState = iota
+	stateStarting
+	stateBuilding
+	stateBuildFailed
+	stateRunning
+	stateExited
+	stateCrashed
+)
+
+func (s paneState) String() string {
+	switch s {
+	case stateStarting:
+		return "starting"
+	case stateBuilding:
+		return "building"
+	case stateBuildFailed:
+		return "build failed"
+	case stateRunning:
+		return "running"
+	case stateExited:
+		return "exited"
+	case stateCrashed:
+		return "crashed"
+	default:
+		return "stopped"
+	}
+}
+
+// statsTickMsg triggers a new check of the processes
+type statsTickMsg time.Time
+
+// paneStatsMsg is the resource usage of the process of a pane
+type paneStatsMsg struct {
+	Pane string
+	// run identifies the run of the process checked
+	run      int
+	at       time.Time
+	usage    procstat.Usage
+	usageErr error
+}
+
+func statsTick() tea.Cmd {
+	return tea.Tick(statsInterval, func(t time.Time) tea.Msg {
+		return statsTickMsg(t)
+	})
+}
+
+// compileReadiness compiles the patterns marking a pane as building and
+// ready
+func compileReadiness(cfg config.PaneConfig) (building, ready *regexp.Regexp, err error) {
+	if cfg.BuildingPattern != "" {
+		if building, err = regexp.Compile(cfg.BuildingPattern); err != nil {
+			return nil, nil, fmt.Errorf("invalid building pattern: %w", err)
+		}
+	}
+	if cfg.ReadyPattern != "" {
+		if ready, err = regexp.Compile(cfg.ReadyPattern); err != nil {
+			return nil, nil, fmt.Errorf("invalid ready pattern: %w", err)
+		}
+	}
+	return building, ready, nil
+}
+
+// checkStats returns the command getting the resource usage of the process
+// of the pane, nil when it has no program running
+func (p *pane) checkStats() tea.Cmd {
+	if !p.running() || p.pid == 0 {
+		return nil
+	}
+	name, pid, run := p.Name, p.pid, p.run
+	return func() tea.Msg {
+		msg := paneStatsMsg{Pane: name, run: run, at: time.Now()}
+		msg.usage, msg.usageErr = procstat.Tree(pid)
+		return msg
+	}
+}
+
+// updateStats records the resource usage, ignoring the one of a previous
+// run
+func (p *pane) updateStats(msg paneStatsMsg) {
+	if msg.run != p.run || msg.usageErr != nil {
+		return
+	}
+	if p.hasUsage {
+		if elapsed := msg.at.Sub(p.sampledAt); elapsed > 0 {
+			// Processes exiting take their CPU time with them
+			p.cpu = max(float64(msg.usage.CPU-p.usage.CPU)/float64(elapsed)*100, 0)
+		}
+	}
+	p.usage = msg.usage
+	p.sampledAt = msg.at
+	p.hasUsage = true
+}
+
+// statusLine describes the state, uptime, restarts and resource usage of
+// the process
+func (p *pane) statusLine() string {
+	state := p.state
+	label := state.String()
+	if state == stateCrashed && p.exitStatus != "" {
+		label += " (" + p.exitStatus + ")"
+	}
+	parts := []string{lipgloss.NewStyle().Foreground(stateColors[state]).Render("● " + label)}
+
+	if p.running() {
+		parts = append(parts, "up "+time.Since(p.startedAt).Round(time.Second).String())
+	}
+	if p.restarts > 0 {
+		parts = append(parts, fmt.Sprintf("restarts %d", p.restarts))
+	}
+	if p.running() && p.hasUsage {
+		parts = append(parts,
+			fmt.Sprintf("cpu %.0f%%", p.cpu),
+			fmt.Sprintf("mem %.0f MB", float64(p.usage.RSS)/(1024*1024)),
+		)
+		if p.usage.Processes > 1 {
+			parts = append(parts, fmt.Sprintf("%d processes", p.usage.Processes))
+		}
+	}
+	return strings.Join(parts, " · ")
+}
@@ -1,20 +1,21 @@
 package start
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"io"
-	"log"
-	"os"
-	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
-	"sync"
-	"syscall"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
-	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/jespino/mmdev/internal/config"
+	"github.com/jespino/mmdev/pkg/generator"
+	"github.com/jespino/mmdev/pkg/notify"
+	"github.com/jespino/mmdev/pkg/supervisor"
 )
 
 var (
@@ -22,8 +23,7 @@ var (
 			Bold(true).
 			Foreground(lipgloss.Color("#FFFFFF")).
 			Background(lipgloss.Color("#383838")).
-			Padding(0, 1).
-			MarginBottom(1)
+			Padding(0, 1)
 
 	dividerStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#FF69B4")).
@@ -32,577 +32,540 @@ var (
 	suggestionStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#666666"))
 
-	commands = []string{
-		"quit",
-		"client-restart",
-		"server-restart",
-	}
+	paletteStyle = lipgloss.NewStyle().
+			Background(lipgloss.Color("#262626"))
+
+	paletteSelectedStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#FFFFFF")).
+				Background(lipgloss.Color("#5F5F87"))
 
 	titleSelectedStyle = lipgloss.NewStyle().
 				Bold(true).
 				Foreground(lipgloss.Color("#FFFFFF")).
 				Background(lipgloss.Color("#FF69B4")).
-				Padding(0, 1).
-				MarginBottom(1)
+				Padding(0, 1)
 
 	helpStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("241"))
 )
 
-var viewportChan = make(chan NewViewportLine)
+// titleHeight is the space taken by the title and the status line of a pane
+const titleHeight = 2
 
-type NewViewportLine struct {
-	Viewport string
-	Line     string
-	Quit     bool
+// Input modes of the prompt at the bottom of the screen
+const (
+	inputCommand = "command"
+	inputSearch  = "search"
+	inputFilter  = "filter"
+)
+
+// controller runs the processes of the panes: a supervisor in this process,
+// or the one of a detached mmdev start
+type controller interface {
+	Events() <-chan supervisor.Event
+	Start(name string) error
+	Stop(name string) error
+	Restart(name string) error
+	// Shutdown stops all the processes and waits for them
+	Shutdown()
 }
 
-type model struct {
-	serverViewport viewport.Model
-	clientViewport viewport.Model
-	commandInput   textinput.Model
-	ready          bool
-	selectedPane   string
-	commandMode    bool
-	serverAtBottom bool
-	clientAtBottom bool
-	splitVertical  bool
-	suggestion     string
-
-	serverCmd         *exec.Cmd
-	clientCmd         *exec.Cmd
-	serverWriter      io.Writer
-	clientWriter      io.Writer
-	quitting          bool
-	serverLogs        strings.Builder
-	clientLogs        strings.Builder
-	serverViewContent strings.Builder
-	clientViewContent strings.Builder
-	shutdownWg        sync.WaitGroup
-	windowWidth       int
-	windowHeight      int
+// killer is implemented by the controllers able to stop the processes
+// without giving them time to exit, like the supervisor
+type killer interface {
+	Kill()
 }
 
-func initialModel() model {
-	commandInput := textinput.New()
-	commandInput.Prompt = ": "
+// disconnectedMsg is sent when the detached mmdev start the TUI is attached
+// to goes away
+type disconnectedMsg struct{}
 
-	m := model{
-		selectedPane:   "server",
-		commandMode:    false,
-		commandInput:   commandInput,
-		serverAtBottom: true,
-		clientAtBottom: true,
-		splitVertical:  false,
-	}
+type model struct {
+	panes      []*pane
+	supervisor controller
+	// attached is set when the processes run in a detached mmdev start,
+	// which keeps running when the TUI exits, disconnected once it's gone
+	attached     bool
+	disconnected bool
+	session      *session
+	selected     int
+	layout       string
+	// editor is the command template opening the file locations
+	editor       string
+	commands     []command
+	commandInput textinput.Model
+	// inputMode is the mode of the prompt, empty when it's not shown
+	inputMode string
+	// palette are the completions of the command input, paletteIndex the
+	// selected one
+	palette      []completion
+	paletteIndex int
+	// tasks are the names of the tasks running in the background, which
+	// send their output to taskMsgs and stop when tasksCtx is cancelled
+	tasks       map[string]bool
+	taskMsgs    chan taskMsg
+	tasksCtx    context.Context
+	cancelTasks context.CancelFunc
+	// generators are the server code generators, discovered when first
+	// completed
+	generators []generator.Target
+	// notifier tells about the failures and recoveries of the processes
+	// tracked by tracker, for the events since notifySince
+	notifier     *notify.Notifier
+	tracker      *notify.Tracker
+	notifySince  time.Time
+	message      string
+	ready        bool
+	quitting     bool
+	windowWidth  int
+	windowHeight int
+}
 
-	// Start server process
-	m.serverCmd = exec.Command("mmdev", "server", "start")
-	serverOutR, serverOutW, err := os.Pipe()
+func initialModel(cfg config.StartConfig, panes []config.PaneConfig, session *session, processes controller) (*model, error) {
+	commands, err := newCommands(cfg.Commands)
 	if err != nil {
-		log.Printf("Error creating server pipe: %v", err)
+		return nil, err
 	}
-	m.serverCmd.Stdout = serverOutW
-	m.serverCmd.Stderr = serverOutW
-
-	// Start client process
-	m.clientCmd = exec.Command("mmdev", "webapp", "start", "--watch")
-	clientOutR, clientOutW, err := os.Pipe()
-	if err != nil {
-		log.Printf("Error creating client pipe: %v", err)
+	tasksCtx, cancelTasks := context.WithCancel(context.Background())
+	m := &model{
+		supervisor:   processes,
+		session:      session,
+		layout:       cfg.Layout,
+		editor:       cfg.Editor,
+		commands:     commands,
+		commandInput: textinput.New(),
+		tasks:        make(map[string]bool),
+		taskMsgs:     make(chan taskMsg, 256),
+		tasksCtx:     tasksCtx,
+		cancelTasks:  cancelTasks,
+		tracker:      notify.NewTracker(),
 	}
-	m.clientCmd.Stdout = clientOutW
-	m.clientCmd.Stderr = clientOutW
-
-	// Start processes
-	if err := m.serverCmd.Start(); err != nil {
-		fmt.Printf("Error starting server: %v\n", err)
+	if session.logging() {
+		m.message = "Logging the session to " + session.dir
 	}
-
-	if err := m.clientCmd.Start(); err != nil {
-		fmt.Printf("Error starting client: %v\n", err)
-	}
-
-	// Handle output streams
-	go handleOutput(serverOutR, &m, "server")
-	go func() {
-		if err := m.serverCmd.Wait(); err != nil {
-			log.Printf("Server process ended with error: %v", err)
-		}
-		serverOutW.Close()
-	}()
-
-	go handleOutput(clientOutR, &m, "client")
-	go func() {
-		if err := m.clientCmd.Wait(); err != nil {
-			log.Printf("Client process ended with error: %v", err)
+	for _, paneCfg := range panes {
+		p, err := newPane(paneCfg, cfg.Scrollback)
+		if err != nil {
+			return nil, err
 		}
-		clientOutW.Close()
-	}()
-
-	return m
+		p.log = session.log(p.Name)
+		m.panes = append(m.panes, p)
+	}
+	return m, nil
 }
 
+// wrapLine splits a line in rows of at most width columns, breaking at
+// spaces when possible and keeping the ANSI colors out of the count
 func wrapLine(text string, width int) []string {
 	if width <= 0 {
 		return []string{text}
 	}
-	
-	var lines []string
-	remaining := text
-	
-	for len(remaining) > width {
-		idx := width
-		// Try to break at last space before width
-		for i := idx; i >= 0; i-- {
-			if remaining[i] == ' ' {
-				idx = i
-				break
-			}
+	return strings.Split(ansi.Wrap(text, width, ""), "\n")
+}
+
+// listen returns the command waiting for the next event of the processes
+func (m *model) listen() tea.Cmd {
+	events := m.supervisor.Events()
+	return func() tea.Msg {
+		event, ok := <-events
+		if !ok {
+			return disconnectedMsg{}
 		}
-		if idx == width {
-			// No space found, force break at width
-			lines = append(lines, remaining[:width])
-			remaining = remaining[width:]
+		return event
+	}
+}
+
+func (m *model) Init() tea.Cmd {
+	// The detached mmdev start already started them
+	if m.attached {
+		return tea.Batch(m.listen(), m.listenTasks(), statsTick())
+	}
+	for _, p := range m.panes {
+		if p.autostart() {
+			m.start(p)
 		} else {
-			lines = append(lines, remaining[:idx])
-			remaining = remaining[idx+1:] // Skip the space
+			p.appendLine(fmt.Sprintf("Not started, use :start %s to start it", p.Name))
 		}
 	}
-	if remaining != "" {
-		lines = append(lines, remaining)
-	}
-	return lines
+	return tea.Batch(m.listen(), m.listenTasks(), statsTick())
 }
 
-func handleOutput(reader io.Reader, m *model, viewport string) {
-	scanner := bufio.NewScanner(reader)
-	for scanner.Scan() {
-		text := scanner.Text()
-		width := m.windowWidth
-		if m.splitVertical {
-			width = m.windowWidth / 2
-		}
-		
-		// Wrap the line
-		wrappedLines := wrapLine(text, width-2) // -2 for padding
-		for _, line := range wrappedLines {
-			if viewport == "server" {
-				m.serverLogs.WriteString(line + "\n")
-			} else {
-				m.clientLogs.WriteString(line + "\n")
-			}
-			viewportChan <- NewViewportLine{Viewport: viewport, Line: line + "\n"}
-		}
+// pane returns the pane with the given name, or the selected one when the
+// name is empty
+func (m *model) pane(name string) (*pane, error) {
+	if name == "" {
+		return m.panes[m.selected], nil
 	}
-	if err := scanner.Err(); err != nil {
-		log.Printf("[%s] Scanner error: %v", viewport, err)
+	for _, p := range m.panes {
+		if p.Name == name {
+			return p, nil
+		}
 	}
+	return nil, fmt.Errorf("unknown pane %q", name)
 }
 
-func listenForUpdates() tea.Msg {
-	return <-viewportChan
+// start starts the process of a pane
+func (m *model) start(p *pane) {
+	if err := m.supervisor.Start(p.Name); err != nil {
+		m.message = err.Error()
+	}
 }
 
-func (m model) Init() tea.Cmd {
-	return listenForUpdates
+// stop asks the process of a pane to stop
+func (m *model) stop(p *pane) {
+	if err := m.supervisor.Stop(p.Name); err != nil {
+		m.message = err.Error()
+	}
 }
 
-func (m *model) restartServer() {
-	// Clear viewport content
-	m.serverLogs.Reset()
-	m.serverViewContent.Reset()
-	m.serverViewport.SetContent("")
-
-	// Check if process exists and is running
-	if m.serverCmd != nil && m.serverCmd.Process != nil {
-		// Try to send signal 0 to check if process is running
-		if err := m.serverCmd.Process.Signal(syscall.Signal(0)); err == nil {
-			// Process exists and we have permission to signal it
-			if err := m.serverCmd.Process.Signal(syscall.SIGUSR1); err != nil {
-				fmt.Printf("Error sending SIGUSR1 to server: %v\n", err)
-			}
-			return
-		}
-		// Process is not running or we don't have permission
-		m.serverCmd = nil
+// restart clears the pane and restarts its process, in place when it
+// supports it
+func (m *model) restart(p *pane) {
+	p.clear()
+	p.appendDivider("restart")
+	if err := m.supervisor.Restart(p.Name); err != nil {
+		m.message = err.Error()
 	}
+}
 
-	// Server is not running, start it
-	m.serverCmd = exec.Command("mmdev", "server", "start")
-	serverOutR, serverOutW, err := os.Pipe()
-	if err != nil {
-		fmt.Printf("Error creating server pipe: %v\n", err)
-		return
+// quit exits, stopping all the processes unless they run in a detached
+// mmdev start
+func (m *model) quit() tea.Cmd {
+	if m.attached {
+		m.cancelTasks()
+		return tea.Quit
 	}
-	m.serverCmd.Stdout = serverOutW
-	m.serverCmd.Stderr = serverOutW
+	return m.shutdown()
+}
 
-	if err := m.serverCmd.Start(); err != nil {
-		fmt.Printf("Error starting server: %v\n", err)
-		return
+// shutdown stops all the processes and exits once they are done
+func (m *model) shutdown() tea.Cmd {
+	if m.quitting {
+		return nil
 	}
-
-	// Handle output streams
-	go handleOutput(serverOutR, m, "server")
-	go func() {
-		if err := m.serverCmd.Wait(); err != nil {
-			fmt.Printf("Server process ended with error: %v\n", err)
+	m.quitting = true
+	m.cancelTasks()
+	for _, p := range m.panes {
+		if p.running() {
+			p.appendLine("Stopping...")
 		}
-		serverOutW.Close()
-	}()
+	}
+	return func() tea.Msg {
+		m.supervisor.Shutdown()
+		return tea.QuitMsg{}
+	}
 }
 
-func (m *model) runCommand(cmd string) (tea.Model, tea.Cmd) {
-	// Handle command execution here
-	switch cmd {
-	case "q", "quit":
-		m.quitting = true
-
-		// Add to wait group for server process
-		if m.serverCmd != nil && m.serverCmd.Process != nil {
-			m.shutdownWg.Add(1)
+// kill stops all the processes without waiting for them to exit
+// gracefully, also hurrying a shutdown in progress, and exits once they are
+// gone
+func (m *model) kill() tea.Cmd {
+	k, ok := m.supervisor.(killer)
+	if !ok {
+		return m.shutdown()
+	}
+	m.quitting = true
+	m.cancelTasks()
+	for _, p := range m.panes {
+		if p.running() {
+			p.appendLine("Killing...")
 		}
+	}
+	return func() tea.Msg {
+		k.Kill()
+		return tea.QuitMsg{}
+	}
+}
 
-		// Send SIGTERM to both processes
-		if m.clientCmd != nil && m.clientCmd.Process != nil {
-			log.Printf("Sending SIGTERM to client process (PID %d)", m.clientCmd.Process.Pid)
-			if err := m.clientCmd.Process.Signal(syscall.SIGTERM); err != nil {
-				log.Printf("Error sending SIGTERM to client: %v", err)
-			}
+// save writes the output kept by a pane to a file. The arguments are an
+// optional pane name, the selected one by default, and an optional file, in
+// the session directory by default.
+func (m *model) save(args []string) {
+	p := m.panes[m.selected]
+	if len(args) > 0 {
+		if named, err := m.pane(args[0]); err == nil {
+			p = named
+			args = args[1:]
 		}
+	}
 
-		if m.serverCmd != nil && m.serverCmd.Process != nil {
-			log.Printf("Sending SIGTERM to server process (PID %d)", m.serverCmd.Process.Pid)
-			if err := m.serverCmd.Process.Signal(syscall.SIGTERM); err != nil {
-				log.Printf("Error sending SIGTERM to server: %v", err)
-			}
-		}
+	path := m.session.savePath(p.Name)
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if err := saveLogs(path, p.lines); err != nil {
+		m.message = err.Error()
+		return
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	m.message = fmt.Sprintf("Saved %d lines of %s to %s", p.lines.len(), p.Name, path)
+}
 
-		// Wait for processes to finish in a goroutine
-		go func() {
-			if m.clientCmd != nil {
-				if err := m.clientCmd.Wait(); err != nil {
-					log.Printf("Client process wait error: %v", err)
-				}
-				log.Printf("Client process terminated")
-			}
+// openLocation opens the selected file location of the selected pane in the
+// editor
+func (m *model) openLocation() tea.Cmd {
+	cmd, err := m.panes[m.selected].openLocation(m.editor)
+	if err != nil {
+		m.message = err.Error()
+	}
+	return cmd
+}
 
-			if m.serverCmd != nil {
-				if err := m.serverCmd.Wait(); err != nil {
-					log.Printf("Server process wait error: %v", err)
-				}
-				log.Printf("Server process terminated")
-				m.shutdownWg.Done()
-			}
+// click selects the pane under the mouse and opens the file location of the
+// output line clicked, if any
+func (m *model) click(x, y int) tea.Cmd {
+	for i, r := range m.rects() {
+		if !r.contains(x, y) {
+			continue
+		}
+		m.selected = i
+		if m.panes[i].selectLocationAt(y - r.y - titleHeight) {
+			return m.openLocation()
+		}
+	}
+	return nil
+}
 
-			// Send quit message through the viewport channel
-			viewportChan <- NewViewportLine{Viewport: "server", Line: "Server stopped\n"}
-			viewportChan <- NewViewportLine{Viewport: "client", Line: "Client stopped\n"}
-		}()
+// setFilter only shows the lines of the selected pane matching a pattern,
+// an empty one shows them all
+func (m *model) setFilter(pattern string) {
+	p := m.panes[m.selected]
+	if err := p.filter.setPattern(pattern); err != nil {
+		m.message = err.Error()
+		return
+	}
+	p.rerender()
+}
 
-		// Wait for server to finish before quitting
-		go func() {
-			m.shutdownWg.Wait()
-			viewportChan <- NewViewportLine{Viewport: "server", Line: "Shutdown complete\n"}
-			viewportChan <- NewViewportLine{Viewport: "server", Line: "Exiting...\n"}
-			// Send final quit message through the viewport channel
-			viewportChan <- NewViewportLine{Quit: true}
-		}()
+// setSearch highlights the lines of the selected pane matching a pattern,
+// an empty one clears the search
+func (m *model) setSearch(pattern string) {
+	re, err := compilePattern(pattern)
+	if err != nil {
+		m.message = fmt.Sprintf("invalid search: %v", err)
+		return
+	}
+	m.panes[m.selected].setSearch(re)
+}
 
-		return m, nil
-	case "server-restart":
-		m.restartServer()
-		return m, nil
-	case "client-restart":
-		if m.clientCmd != nil && m.clientCmd.Process != nil {
-			log.Printf("Terminating client process (PID %d)", m.clientCmd.Process.Pid)
-			if err := m.clientCmd.Process.Signal(syscall.SIGTERM); err != nil {
-				log.Printf("Error sending SIGTERM to client: %v", err)
-			}
-			if err := m.clientCmd.Wait(); err != nil {
-				log.Printf("Error waiting for client to terminate: %v", err)
-			}
+// cycleLevel changes the minimum log level shown in the selected pane
+func (m *model) cycleLevel() {
+	p := m.panes[m.selected]
+	next := levelFilters[0]
+	for i, level := range levelFilters {
+		if level == p.filter.level {
+			next = levelFilters[(i+1)%len(levelFilters)]
 		}
+	}
+	p.filter.setLevel(next)
+	p.rerender()
+}
 
-		// Clear client viewport and content
-		m.clientLogs.Reset()
-		m.clientViewContent.Reset()
-		m.clientViewport.SetContent("")
-
-		// Start new client process
-		m.clientCmd = exec.Command("mmdev", "webapp", "start", "--watch")
-		clientOutR, clientOutW, err := os.Pipe()
-		if err != nil {
-			log.Printf("Error creating client pipe: %v", err)
-			return m, nil
-		}
-		m.clientCmd.Stdout = clientOutW
-		m.clientCmd.Stderr = clientOutW
+// openInput shows the prompt at the bottom of the screen in the given mode
+func (m *model) openInput(mode, prompt, value string) {
+	m.inputMode = mode
+	m.commandInput.Prompt = prompt
+	m.commandInput.SetValue(value)
+	m.commandInput.CursorEnd()
+	m.commandInput.Focus()
+	m.updatePalette()
+}
 
-		log.Printf("Starting new client process with command: %v", m.clientCmd.Args)
-		if err := m.clientCmd.Start(); err != nil {
-			log.Printf("Error starting client: %v", err)
-			return m, nil
-		}
-		log.Printf("New client process started successfully with PID %d", m.clientCmd.Process.Pid)
+func (m *model) closeInput() {
+	m.inputMode = ""
+	m.commandInput.SetValue("")
+	m.commandInput.Blur()
+	m.palette = nil
+}
 
-		// Handle output streams
-		go handleOutput(clientOutR, m, "client")
-		go func() {
-			if err := m.clientCmd.Wait(); err != nil {
-				log.Printf("Client process ended with error: %v", err)
-			}
-			clientOutW.Close()
-		}()
+func (m *model) setLayout(layout string) {
+	m.layout = layout
+	m.resize()
+	for _, p := range m.panes {
+		p.view.gotoBottom()
+	}
+}
 
-		return m, nil
+// resize sets the size of the views for the window size and layout, leaving
+// the last line for the help or the command input. The lines are wrapped
+// again when the width changes.
+func (m *model) resize() {
+	for i, r := range m.rects() {
+		p := m.panes[i]
+		reflow := p.view.width != r.width
+		p.view.setSize(r.width, max(r.height-titleHeight, 1))
+		if reflow {
+			p.rerender()
+		}
 	}
-	return m, nil
 }
 
-func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var (
-		serverCmd tea.Cmd
-		clientCmd tea.Cmd
-		cmdCmd    tea.Cmd
-	)
+func (m *model) rects() []rect {
+	return layoutPanes(m.layout, len(m.panes), m.windowWidth, m.windowHeight-1)
+}
 
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
-	case NewViewportLine:
-		if msg.Viewport == "server" {
-			m.serverViewContent.WriteString(msg.Line)
-			m.serverViewport.SetContent(m.serverViewContent.String())
-			if m.serverAtBottom {
-				m.serverViewport.GotoBottom()
-			}
-		} else {
-			m.clientViewContent.WriteString(msg.Line)
-			m.clientViewport.SetContent(m.clientViewContent.String())
-			if m.clientAtBottom {
-				m.clientViewport.GotoBottom()
-			}
+	case supervisor.Event:
+		if p, err := m.pane(msg.Process); err == nil {
+			p.handleEvent(msg)
+		}
+		return m, tea.Batch(m.listen(), m.notify(msg))
+	case statsTickMsg:
+		cmds := []tea.Cmd{statsTick()}
+		for _, p := range m.panes {
+			cmds = append(cmds, p.checkStats())
+		}
+		return m, tea.Batch(cmds...)
+	case paneStatsMsg:
+		if p, err := m.pane(msg.Pane); err == nil {
+			p.updateStats(msg)
 		}
-		if msg.Quit {
-			return m, tea.Quit
+		return m, nil
+	case notifyFailedMsg:
+		m.message = fmt.Sprintf("notification failed: %v", msg.err)
+		return m, nil
+	case disconnectedMsg:
+		m.disconnected = true
+		return m, tea.Quit
+	case taskMsg:
+		m.handleTask(msg)
+		return m, m.listenTasks()
+	case editorDoneMsg:
+		if msg.err != nil {
+			m.message = fmt.Sprintf("editor failed: %v", msg.err)
 		}
-		return m, listenForUpdates
+		return m, nil
 	case tea.MouseMsg:
+		if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft {
+			return m, m.click(msg.X, msg.Y)
+		}
 		if msg.Action == tea.MouseActionMotion {
-			// Calculate viewport positions
-			serverHeight := (m.windowHeight / 2) - 3
-			if m.splitVertical {
-				serverHeight = m.windowHeight - 4
-			}
-
-			if m.splitVertical {
-				// Vertical split - check if mouse is in left or right half
-				if msg.X < m.windowWidth/2 {
-					m.selectedPane = "server"
-				} else {
-					m.selectedPane = "client"
-				}
-			} else {
-				// Horizontal split - check if mouse is in top or bottom half
-				if msg.Y < serverHeight+2 { // +2 for title and padding
-					m.selectedPane = "server"
-				} else {
-					m.selectedPane = "client"
+			for i, r := range m.rects() {
+				if r.contains(msg.X, msg.Y) {
+					m.selected = i
 				}
 			}
 			return m, nil
 		}
 		switch msg.Button {
 		case tea.MouseButtonWheelUp:
-			if m.selectedPane == "server" {
-				m.serverViewport.LineUp(3)
-				m.serverAtBottom = m.serverViewport.AtBottom()
-			} else {
-				m.clientViewport.LineUp(3)
-				m.clientAtBottom = m.clientViewport.AtBottom()
-			}
+			m.panes[m.selected].view.scroll(-3)
 		case tea.MouseButtonWheelDown:
-			if m.selectedPane == "server" {
-				m.serverViewport.LineDown(3)
-				m.serverAtBottom = m.serverViewport.AtBottom()
-			} else {
-				m.clientViewport.LineDown(3)
-				m.clientAtBottom = m.clientViewport.AtBottom()
-			}
+			m.panes[m.selected].view.scroll(3)
 		}
 		return m, nil
 	case tea.KeyMsg:
-		if m.commandMode {
+		m.message = ""
+		if m.inputMode != "" {
 			switch msg.String() {
-			case "ctrl+c":
-				m.commandMode = false
-				m.commandInput.SetValue("")
-				m.suggestion = ""
+			case "ctrl+c", "esc":
+				m.closeInput()
 				return m, nil
 			case "enter":
-				m.commandMode = false
-				value := m.commandInput.Value()
-				m.commandInput.SetValue("")
-				m.suggestion = ""
-				return m.runCommand(value)
-			case "tab":
-				if m.suggestion != "" {
-					m.commandInput.SetValue(m.suggestion)
-					m.commandInput.CursorEnd()
-					m.suggestion = ""
-				}
-				return m, nil
-			case "esc":
-				m.commandMode = false
-				m.commandInput.SetValue("")
-				return m, nil
-			default:
-				m.commandInput, cmdCmd = m.commandInput.Update(msg)
-				// Find suggestion
-				input := m.commandInput.Value()
-				m.suggestion = ""
-				if input != "" {
-					for _, cmd := range commands {
-						if strings.HasPrefix(cmd, input) && cmd != input {
-							m.suggestion = cmd
-							break
-						}
-					}
+				mode, value := m.inputMode, m.commandInput.Value()
+				if mode == inputCommand {
+					value = m.accept(value)
 				}
-				return m, cmdCmd
-			}
-		} else {
-			switch msg.String() {
-			case "q":
-				m.quitting = true
-				log.Printf("Quit requested, gracefully stopping processes...")
-
-				// Add to wait group for server process
-				if m.serverCmd != nil && m.serverCmd.Process != nil {
-					m.shutdownWg.Add(1)
-				}
-
-				// Send SIGTERM to both processes
-				if m.clientCmd != nil && m.clientCmd.Process != nil {
-					log.Printf("Sending SIGTERM to client process (PID %d)", m.clientCmd.Process.Pid)
-					if err := m.clientCmd.Process.Signal(syscall.SIGTERM); err != nil {
-						log.Printf("Error sending SIGTERM to client: %v", err)
-					}
+				m.closeInput()
+				switch mode {
+				case inputSearch:
+					m.setSearch(value)
+					return m, nil
+				case inputFilter:
+					m.setFilter(value)
+					return m, nil
 				}
-
-				if m.serverCmd != nil && m.serverCmd.Process != nil {
-					log.Printf("Sending SIGTERM to server process (PID %d)", m.serverCmd.Process.Pid)
-					if err := m.serverCmd.Process.Signal(syscall.SIGTERM); err != nil {
-						log.Printf("Error sending SIGTERM to server: %v", err)
-					}
-				}
-
-				// Wait for processes to finish in a goroutine
-				go func() {
-					if m.clientCmd != nil {
-						if err := m.clientCmd.Wait(); err != nil {
-							log.Printf("Client process wait error: %v", err)
-						}
-						log.Printf("Client process terminated")
-					}
-
-					if m.serverCmd != nil {
-						if err := m.serverCmd.Wait(); err != nil {
-							log.Printf("Server process wait error: %v", err)
-						}
-						log.Printf("Server process terminated")
-						m.shutdownWg.Done()
-					}
-
-					// Send quit message through the viewport channel
-					viewportChan <- NewViewportLine{Viewport: "server", Line: "Server stopped\n"}
-					viewportChan <- NewViewportLine{Viewport: "client", Line: "Client stopped\n"}
-				}()
-
-				// Wait for server to finish before quitting
-				go func() {
-					m.shutdownWg.Wait()
-					viewportChan <- NewViewportLine{Viewport: "server", Line: "Shutdown complete\n"}
-					viewportChan <- NewViewportLine{Viewport: "server", Line: "Exiting...\n"}
-					// Send final quit message through the viewport channel
-					viewportChan <- NewViewportLine{Quit: true}
-				}()
-
-				return m, nil
-			case "ctrl+c":
-				return m, tea.Quit
-			case ":":
-				m.commandMode = true
-				m.commandInput.SetValue("")
-				m.commandInput.Focus()
-				return m, nil
+				return m.runCommand(value)
 			case "tab":
-				if m.selectedPane == "server" {
-					m.selectedPane = "client"
-				} else {
-					m.selectedPane = "server"
-				}
-				return m, nil
-			case "r":
-				if m.selectedPane == "server" {
-					m.restartServer()
-				}
+				m.complete()
 				return m, nil
-			case "d":
-				width := m.windowWidth
-				if m.splitVertical {
-					width = m.windowWidth / 2
-				}
-				divider := dividerStyle.Render(strings.Repeat("=", width-2)) + "\n"
-				
-				// Add divider to both viewports
-				m.serverViewContent.WriteString(divider)
-				m.serverViewport.SetContent(m.serverViewContent.String())
-				if m.serverAtBottom {
-					m.serverViewport.GotoBottom()
-				}
-				
-				m.clientViewContent.WriteString(divider)
-				m.clientViewport.SetContent(m.clientViewContent.String())
-				if m.clientAtBottom {
-					m.clientViewport.GotoBottom()
-				}
+			case "up", "ctrl+p":
+				m.movePalette(false)
 				return m, nil
-			case "s":
-				m.splitVertical = !m.splitVertical
-				m.serverViewport.GotoBottom()
-				m.clientViewport.GotoBottom()
+			case "down", "ctrl+n":
+				m.movePalette(true)
 				return m, nil
+			default:
+				var cmd tea.Cmd
+				m.commandInput, cmd = m.commandInput.Update(msg)
+				m.updatePalette()
+				return m, cmd
 			}
 		}
 
-	case tea.WindowSizeMsg:
-		if !m.ready {
-			m.windowWidth = msg.Width
-			m.windowHeight = msg.Height
-			log.Printf("Initializing viewports with width=%d height=%d", msg.Width/2, msg.Height-4)
-
-			m.serverViewport = viewport.New(msg.Width/2, msg.Height-4)
-			m.clientViewport = viewport.New(msg.Width/2, msg.Height-4)
-			m.ready = true
-			log.Printf("Viewports initialized successfully")
-		} else {
-			log.Printf("Window size changed to width=%d height=%d", msg.Width, msg.Height)
+		switch msg.String() {
+		case "q":
+			return m, m.quit()
+		case "ctrl+c":
+			if m.attached {
+				return m, m.quit()
+			}
+			return m, m.kill()
+		case ":":
+			m.openInput(inputCommand, ": ", "")
+			return m, nil
+		case "/":
+			m.openInput(inputSearch, "/", "")
+			return m, nil
+		case "f":
+			value := ""
+			if pattern := m.panes[m.selected].filter.pattern; pattern != nil {
+				value = displayPattern(pattern)
+			}
+			m.openInput(inputFilter, "filter: ", value)
+			return m, nil
+		case "n", "N":
+			m.panes[m.selected].nextMatch(msg.String() == "n")
+			return m, nil
+		case "esc":
+			m.panes[m.selected].setSearch(nil)
+			return m, nil
+		case "l":
+			m.cycleLevel()
+			return m, nil
+		case "tab":
+			m.selected = (m.selected + 1) % len(m.panes)
+			return m, nil
+		case "shift+tab":
+			m.selected = (m.selected + len(m.panes) - 1) % len(m.panes)
+			return m, nil
+		case "r":
+			m.restart(m.panes[m.selected])
+			return m, nil
+		case "e", "E":
+			m.panes[m.selected].nextLocation(msg.String() == "e")
+			return m, nil
+		case "o":
+			return m, m.openLocation()
+		case "d":
+			for _, p := range m.panes {
+				p.appendDivider("")
+			}
+			return m, nil
+		case "s":
+			m.setLayout(nextLayout(m.layout))
+			return m, nil
+		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+			if i, _ := strconv.Atoi(msg.String()); i <= len(m.panes) {
+				m.selected = i - 1
+			}
+			return m, nil
+		default:
+			m.panes[m.selected].view.handleKey(msg.String())
+			return m, nil
 		}
-	}
 
-	// Only process viewport updates if we're not in command mode
-	if !m.commandMode {
-		if m.selectedPane == "server" {
-			m.serverViewport, serverCmd = m.serverViewport.Update(msg)
-			m.serverAtBottom = m.serverViewport.AtBottom()
-		} else {
-			m.clientViewport, clientCmd = m.clientViewport.Update(msg)
-			m.clientAtBottom = m.clientViewport.AtBottom()
-		}
+	case tea.WindowSizeMsg:
+		m.windowWidth = msg.Width
+		m.windowHeight = msg.Height
+		m.ready = true
+		m.resize()
+		return m, nil
 	}
-
-	return m, tea.Batch(serverCmd, clientCmd, cmdCmd)
+	return m, nil
 }
 
 func (m *model) View() string {
@@ -611,74 +574,114 @@ func (m *model) View() string {
 	}
 
 	var commandArea string
-	if m.commandMode {
-		if m.suggestion != "" {
-			commandArea = m.commandInput.View() + suggestionStyle.Render(m.suggestion[len(m.commandInput.Value()):])
-		} else {
-			commandArea = m.commandInput.View()
+	switch {
+	case m.inputMode != "" && m.suggestion() != "":
+		commandArea = m.commandInput.View() + suggestionStyle.Render(strings.TrimPrefix(m.suggestion(), m.commandInput.Value()))
+	case m.inputMode != "":
+		commandArea = m.commandInput.View()
+	case m.message != "":
+		commandArea = helpStyle.Render(m.message)
+	default:
+		commandArea = helpStyle.Render("↑/↓: scroll • /: search • n/N: next/prev match • e/E: next/prev file location • o: open in editor • f: filter • l: level • q: quit • r: restart pane • s: switch layout • tab: next pane • d: divider • :: command")
+	}
+	commandArea = lipgloss.NewStyle().MaxWidth(m.windowWidth).Render(commandArea)
+
+	// Join the panes of each row side by side, then the rows one below the
+	// other
+	var rows []string
+	var row []string
+	rects := m.rects()
+	for i, r := range rects {
+		row = append(row, m.paneView(i, r))
+		if i == len(rects)-1 || rects[i+1].y != r.y {
+			rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, row...))
+			row = nil
 		}
-	} else {
-		commandArea = helpStyle.Render("↑/↓: scroll • q: quit • r: restart server • s: toggle split • tab: switch • d: divider • :: command")
-	}
-
-	serverScrollPct := fmt.Sprintf("%d%%", int(m.serverViewport.ScrollPercent()*100))
-	clientScrollPct := fmt.Sprintf("%d%%", int(m.clientViewport.ScrollPercent()*100))
-
-	titleServer := titleStyle.Render(fmt.Sprintf("Server [%s]", serverScrollPct))
-	titleClient := titleStyle.Render(fmt.Sprintf("Client [%s]", clientScrollPct))
-	if m.selectedPane == "server" {
-		titleServer = titleSelectedStyle.Render(fmt.Sprintf("Server [%s]", serverScrollPct))
-	} else {
-		titleClient = titleSelectedStyle.Render(fmt.Sprintf("Client [%s]", clientScrollPct))
-	}
-
-	var content string
-	if m.splitVertical {
-		m.serverViewport.Height = m.windowHeight - 4
-		m.serverViewport.Width = m.windowWidth / 2
-		m.clientViewport.Height = m.windowHeight - 4
-		m.clientViewport.Width = m.windowWidth / 2
-		content = lipgloss.JoinHorizontal(lipgloss.Top,
-			lipgloss.JoinVertical(lipgloss.Left,
-				titleServer,
-				m.serverViewport.View(),
-			),
-			lipgloss.JoinVertical(lipgloss.Left,
-				titleClient,
-				m.clientViewport.View(),
-			),
-		)
-	} else {
-		m.serverViewport.Height = (m.windowHeight / 2) - 3
-		m.serverViewport.Width = m.windowWidth
-		m.clientViewport.Height = (m.windowHeight / 2) - 3
-		m.clientViewport.Width = m.windowWidth
-		content = lipgloss.JoinVertical(lipgloss.Left,
-			lipgloss.JoinVertical(lipgloss.Left,
-				titleServer,
-				m.serverViewport.View(),
-			),
-			lipgloss.JoinVertical(lipgloss.Left,
-				titleClient,
-				m.clientViewport.View(),
-			),
-		)
 	}
 
+	// The palette covers the bottom of the panes
+	lines := strings.Split(lipgloss.JoinVertical(lipgloss.Left, rows...), "\n")
+	palette := m.paletteView()
+	if len(palette) > len(lines) {
+		palette = palette[len(palette)-len(lines):]
+	}
+	copy(lines[len(lines)-len(palette):], palette)
+
 	return lipgloss.JoinVertical(lipgloss.Left,
-		content,
+		strings.Join(lines, "\n"),
 		commandArea,
 	)
 }
 
-func StartTUI() error {
-	initial := initialModel()
+// suggestion is the selected completion when it continues the command
+// input, shown after it
+func (m *model) suggestion() string {
+	if len(m.palette) == 0 {
+		return ""
+	}
+	value := m.palette[m.paletteIndex].value
+	if !strings.HasPrefix(value, m.commandInput.Value()) {
+		return ""
+	}
+	return value
+}
+
+func (m *model) paneView(i int, r rect) string {
+	p := m.panes[i]
+
+	title := fmt.Sprintf("%s [%d%%]", p.Name, int(p.view.scrollPercent()*100))
+	if filter := p.filter.String(); filter != "" {
+		title += " " + filter
+	}
+	if location := p.locationStatus(); location != "" {
+		title += " " + location
+	}
+	if search := p.searchStatus(); search != "" {
+		title += " " + search
+	}
+	style := titleStyle
+	if i == m.selected {
+		style = titleSelectedStyle
+	}
+
+	return lipgloss.NewStyle().
+		Width(r.width).MaxWidth(r.width).
+		Height(r.height).MaxHeight(r.height).
+		Render(lipgloss.JoinVertical(lipgloss.Left,
+			// Truncated so it never wraps, taking the padding into account
+			style.Render(ansi.Truncate(title, r.width-2, "…")),
+			statusStyle.Render(ansi.Truncate(p.statusLine(), r.width-2, "…")),
+			p.view.View(),
+		))
+}
+
+func StartTUI(cfg config.StartConfig, panes []config.PaneConfig, notifier *notify.Notifier) error {
+	session, err := newSession(cfg, panes)
+	if err != nil {
+		return fmt.Errorf("failed to create the session: %w", err)
+	}
+	defer session.close()
+
+	s, err := newSupervisor(panes)
+	if err != nil {
+		return err
+	}
+	m, err := initialModel(cfg, panes, session, s)
+	if err != nil {
+		return err
+	}
+	m.notifier = notifier
 	p := tea.NewProgram(
-		&initial,
+		m,
 		tea.WithAltScreen(),
 		tea.WithMouseAllMotion(),
 	)
 
-	_, err := p.Run()
-	return err
+	if _, err := p.Run(); err != nil {
+		return err
+	}
+	if session.logging() {
+		fmt.Printf("Session logs saved in %s\n", session.dir)
+	}
+	return nil
 }
@@ -0,0 +1,157 @@
+package webapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jespino/mmdev/pkg/bundlereport"
+	"github.com/jespino/mmdev/pkg/gitchanges"
+	"github.com/spf13/cobra"
+)
+
+func BundleReportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle-report",
+		Short: "Report the webapp bundle size and compare it with the main branch",
+		Long: `Report the raw and gzip size of every chunk of the webapp production build.
+
+Builds on the main branch (or with --save-baseline) are saved as the baseline.
+On other branches the sizes are compared with the baseline, failing when the
+total or any chunk grows more than --threshold percent.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manager, err := newWebappManager(cmd)
+			if err != nil {
+				return err
+			}
+
+			build, _ := cmd.Flags().GetBool("build")
+			baselinePath, _ := cmd.Flags().GetString("baseline")
+			saveBaseline, _ := cmd.Flags().GetBool("save-baseline")
+			threshold, _ := cmd.Flags().GetFloat64("threshold")
+			warnOnly, _ := cmd.Flags().GetBool("warn-only")
+			format, _ := cmd.Flags().GetString("format")
+			top, _ := cmd.Flags().GetInt("top")
+			if format != "text" && format != "json" {
+				return fmt.Errorf("unknown output format %q, expected text or json", format)
+			}
+
+			if build {
+				if err := manager.Build(); err != nil {
+					return fmt.Errorf("failed to build webapp: %w", err)
+				}
+			}
+
+			report, err := bundlereport.Analyze(manager.DistDir())
+			if err != nil {
+				return fmt.Errorf("%w (build the webapp first or use --build)", err)
+			}
+			report.Branch, _ = gitchanges.CurrentBranch(webappDir)
+			report.Commit, _ = gitchanges.Head(webappDir)
+
+			if baselinePath == "" {
+				baselinePath, err = bundlereport.DefaultBaselinePath(manager.DistDir())
+				if err != nil {
+					return err
+				}
+			}
+
+			if saveBaseline || gitchanges.IsMainBranch(report.Branch) {
+				if err := report.Save(baselinePath); err != nil {
+					return err
+				}
+				if format == "json" {
+					return writeBundleJSON(report, nil, nil, nil)
+				}
+				if err := report.WriteText(os.Stdout, top); err != nil {
+					return err
+				}
+				fmt.Printf("\nSaved as the baseline in %s\n", baselinePath)
+				return nil
+			}
+
+			baseline, err := bundlereport.Load(baselinePath)
+			if os.IsNotExist(err) {
+				if format == "json" {
+					return writeBundleJSON(report, nil, nil, nil)
+				}
+				if err := report.WriteText(os.Stdout, top); err != nil {
+					return err
+				}
+				fmt.Println("\nNo baseline to compare with, run the report on the main branch or use --save-baseline to record one")
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			total, diffs := bundlereport.Compare(baseline, report, threshold)
+			if format == "json" {
+				if err := writeBundleJSON(report, baseline, &total, diffs); err != nil {
+					return err
+				}
+			} else {
+				if err := report.WriteText(os.Stdout, top); err != nil {
+					return err
+				}
+				fmt.Printf("\nCompared with the baseline from %s (%s):\n", describeBaseline(baseline), baseline.CreatedAt.Format("2006-01-02 15:04"))
+				if err := bundlereport.WriteDiffText(os.Stdout, total, diffs); err != nil {
+					return err
+				}
+			}
+
+			var regressions []string
+			for _, diff := range append(diffs, total) {
+				if diff.Regression {
+					regressions = append(regressions, diff.Name)
+				}
+			}
+			if len(regressions) == 0 {
+				return nil
+			}
+
+			message := fmt.Sprintf("bundle size grew more than %.1f%%: %s", threshold, strings.Join(regressions, ", "))
+			if warnOnly {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", message)
+				return nil
+			}
+			fmt.Fprintf(os.Stderr, "Error: %s\n", message)
+			os.Exit(1)
+			return nil
+		},
+	}
+	cmd.Flags().Bool("build", false, "Run a production build before the report")
+	cmd.Flags().String("baseline", "", "Baseline file to compare with or save to (default: in the mmdev cache directory)")
+	cmd.Flags().Bool("save-baseline", false, "Save this build as the baseline, even when not on the main branch")
+	cmd.Flags().Float64("threshold", 5, "Growth, in percent of the gzip size, over which the check fails")
+	cmd.Flags().Bool("warn-only", false, "Only warn when the size grows over the threshold")
+	cmd.Flags().String("format", "text", "Output format: text or json")
+	cmd.Flags().Int("top", 20, "Number of largest chunks to list in the text output, 0 for all")
+	return cmd
+}
+
+func describeBaseline(baseline *bundlereport.Report) string {
+	commit := baseline.Commit
+	if len(commit) > 10 {
+		commit = commit[:10]
+	}
+	switch {
+	case baseline.Branch != "" && commit != "":
+		return baseline.Branch + "@" + commit
+	case commit != "":
+		return commit
+	default:
+		return "an unknown commit"
+	}
+}
+
+func writeBundleJSON(report, baseline *bundlereport.Report, total *bundlereport.Diff, diffs []bundlereport.Diff) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Report   *bundlereport.Report `json:"report"`
+		Baseline *bundlereport.Report `json:"baseline,omitempty"`
+		Total    *bundlereport.Diff   `json:"total,omitempty"`
+		Diffs    []bundlereport.Diff  `json:"diffs,omitempty"`
+	}{report, baseline, total, diffs})
+}
@@ -4,10 +4,16 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/jespino/mmdev/pkg/gitchanges"
+	"github.com/jespino/mmdev/pkg/lintreport"
+	"github.com/jespino/mmdev/pkg/server"
 	"github.com/jespino/mmdev/pkg/webapp"
 	"github.com/spf13/cobra"
 )
 
+// webappDir is the webapp directory relative to the Mattermost repository
+const webappDir = "./webapp"
+
 func WebappCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "webapp",
@@ -17,10 +23,16 @@ func WebappCmd() *cobra.Command {
 		},
 	}
 
+	cmd.PersistentFlags().Bool("force-install", false, "Run npm install even if the dependencies didn't change")
+	cmd.PersistentFlags().String("workspace", "", "Only target this npm workspace (e.g. channels or platform/client)")
+
 	cmd.AddCommand(
 		StartCmd(),
+		BuildCmd(),
 		LintCmd(),
 		FixCmd(),
+		TestCmd(),
+		BundleReportCmd(),
 	)
 	return cmd
 }
@@ -30,12 +42,10 @@ func FixCmd() *cobra.Command {
 		Use:   "fix",
 		Short: "Run auto-fix on the webapp code",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			webappDir := "./webapp"
-			if _, err := os.Stat(webappDir); os.IsNotExist(err) {
-				return fmt.Errorf("webapp directory not found at %s", webappDir)
+			manager, err := newWebappManager(cmd)
+			if err != nil {
+				return err
 			}
-
-			manager := webapp.NewManager(webappDir)
 			if err := manager.Fix(); err != nil {
 				fmt.Printf("Fix found issues: %v\n", err)
 				os.Exit(1)
@@ -51,14 +61,71 @@ func LintCmd() *cobra.Command {
 		Use:   "lint",
 		Short: "Run linting on the webapp code",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			webappDir := "./webapp"
-			if _, err := os.Stat(webappDir); os.IsNotExist(err) {
-				return fmt.Errorf("webapp directory not found at %s", webappDir)
+			manager, err := newWebappManager(cmd)
+			if err != nil {
+				return err
+			}
+
+			changed, _ := cmd.Flags().GetBool("changed")
+			format, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+			if err := lintreport.ValidateFormat(format); err != nil {
+				return err
+			}
+
+			var files []string
+			if changed {
+				changedFiles, err := gitchanges.ChangedFilesSinceMergeBase(webappDir)
+				if err != nil {
+					return err
+				}
+				files = inWorkspace(manager, gitchanges.WithExtensions(webappDir, changedFiles, ".ts", ".tsx", ".js", ".jsx"))
+				if len(files) == 0 {
+					fmt.Fprintln(os.Stderr, "No changed TypeScript or JavaScript files to lint")
+					if format == lintreport.FormatText {
+						return nil
+					}
+					return lintreport.WriteFile(output, format, "eslint", nil)
+				}
+			}
+
+			if format == lintreport.FormatText {
+				if err := manager.LintFiles(files); err != nil {
+					return fmt.Errorf("linting found issues: %v", err)
+				}
+				return nil
+			}
+
+			issues, err := manager.LintReport(files)
+			if err != nil {
+				return err
+			}
+			if err := lintreport.WriteFile(output, format, "eslint", issues); err != nil {
+				return err
 			}
+			if len(issues) > 0 {
+				os.Exit(1)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Bool("changed", false, "Only lint the files changed since the merge base with the main branch")
+	cmd.Flags().String("format", lintreport.FormatText, "Output format: text, json or sarif")
+	cmd.Flags().StringP("output", "o", "", "Write the json or sarif report to this file instead of stdout")
+	return cmd
+}
 
-			manager := webapp.NewManager(webappDir)
-			if err := manager.Lint(); err != nil {
-				return fmt.Errorf("linting found issues: %v", err)
+func BuildCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "build",
+		Short: "Build the webapp",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manager, err := newWebappManager(cmd)
+			if err != nil {
+				return err
+			}
+			if err := manager.Build(); err != nil {
+				return fmt.Errorf("failed to build webapp: %w", err)
 			}
 			return nil
 		},
@@ -66,18 +133,72 @@ func LintCmd() *cobra.Command {
 	return cmd
 }
 
+func TestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "test [paths...]",
+		Short: "Run the webapp jest tests",
+		Long: `Run the jest tests of every workspace, of the workspace selected with
+--workspace, or of the given paths relative to the webapp directory
+(e.g. channels/src/components/post). With --changed, only the tests related to
+the files changed since the merge base with the main branch are run.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manager, err := newWebappManager(cmd)
+			if err != nil {
+				return err
+			}
+
+			changed, _ := cmd.Flags().GetBool("changed")
+			watch, _ := cmd.Flags().GetBool("watch")
+			namePattern, _ := cmd.Flags().GetString("test-name-pattern")
+			opts := webapp.TestOptions{
+				Paths:       args,
+				NamePattern: namePattern,
+				Watch:       watch,
+			}
+
+			if changed {
+				changedFiles, err := gitchanges.ChangedFilesSinceMergeBase(webappDir)
+				if err != nil {
+					return err
+				}
+				opts.Related = inWorkspace(manager, gitchanges.WithExtensions(webappDir, changedFiles, ".ts", ".tsx", ".js", ".jsx"))
+				if len(opts.Related) == 0 {
+					fmt.Println("No changed TypeScript or JavaScript files to test")
+					return nil
+				}
+			}
+
+			return manager.Test(opts)
+		},
+	}
+	cmd.Flags().Bool("changed", false, "Only run the tests related to the files changed since the merge base with the main branch")
+	cmd.Flags().BoolP("watch", "w", false, "Re-run the tests when files change")
+	cmd.Flags().StringP("test-name-pattern", "t", "", "Only run the tests with a name matching this regular expression")
+	return cmd
+}
+
 func StartCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "start",
 		Short: "Start the webapp",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			webappDir := "./webapp"
-			if _, err := os.Stat(webappDir); os.IsNotExist(err) {
-				return fmt.Errorf("webapp directory not found at %s", webappDir)
+			manager, err := newWebappManager(cmd)
+			if err != nil {
+				return err
+			}
+
+			if proxy, _ := cmd.Flags().GetBool("proxy"); proxy {
+				port, _ := cmd.Flags().GetInt("port")
+				devServerPort, _ := cmd.Flags().GetInt("dev-server-port")
+				serverPort, _ := cmd.Flags().GetInt("server-port")
+				return manager.DevServer(webapp.DevServerOptions{
+					Port:          port,
+					DevServerPort: devServerPort,
+					ServerURL:     fmt.Sprintf("http://localhost:%d", serverPort),
+				})
 			}
 
 			watch, _ := cmd.Flags().GetBool("watch")
-			manager := webapp.NewManager(webappDir)
 			if err := manager.Start(watch); err != nil {
 				return fmt.Errorf("failed to run webapp: %w", err)
 			}
@@ -85,6 +206,40 @@ func StartCmd() *cobra.Command {
 			return nil
 		},
 	}
-	cmd.Flags().Bool("watch", false, "Watch for changes and rebuild")
+	cmd.Flags().BoolP("watch", "w", false, "Watch for changes and rebuild")
+	cmd.Flags().Bool("proxy", false, "Run the webpack dev server with hot reload behind a proxy forwarding the API to the server")
+	cmd.Flags().Int("port", 9005, "With --proxy, port for the proxy to listen on")
+	cmd.Flags().Int("dev-server-port", 9006, "With --proxy, port for the webpack dev server to listen on")
+	cmd.Flags().Int("server-port", server.DefaultPort, "With --proxy, port the server listens on")
 	return cmd
 }
+
+func newWebappManager(cmd *cobra.Command) (*webapp.Manager, error) {
+	if _, err := os.Stat(webappDir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("webapp directory not found at %s", webappDir)
+	}
+
+	manager := webapp.NewManager(webappDir)
+	forceInstall, _ := cmd.Flags().GetBool("force-install")
+	manager.SetForceInstall(forceInstall)
+	workspace, _ := cmd.Flags().GetString("workspace")
+	if err := manager.SetWorkspace(workspace); err != nil {
+		return nil, err
+	}
+	return manager, nil
+}
+
+// inWorkspace filters the files, relative to the webapp directory, to the
+// ones in the selected workspace
+func inWorkspace(manager *webapp.Manager, files []string) []string {
+	if manager.Workspace() == "" {
+		return files
+	}
+	var result []string
+	for _, file := range files {
+		if workspace, ok := manager.WorkspaceOf(file); ok && workspace == manager.Workspace() {
+			result = append(result, file)
+		}
+	}
+	return result
+}
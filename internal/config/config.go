@@ -9,9 +9,90 @@ import (
 )
 
 type Config struct {
-	Jira       JiraConfig       `toml:"jira"`
-	Sentry     SentryConfig     `toml:"sentry"`
-	Weblate    WeblateConfig    `toml:"weblate"`
+	Jira    JiraConfig    `toml:"jira"`
+	Sentry  SentryConfig  `toml:"sentry"`
+	Weblate WeblateConfig `toml:"weblate"`
+	Start   StartConfig   `toml:"start,omitempty"`
+	Notify  NotifyConfig  `toml:"notify,omitempty"`
+}
+
+// NotifyConfig configures the notifications of build failures, crashes and
+// recoveries in mmdev start and mmdev server start --watch
+type NotifyConfig struct {
+	// DisableBell stops ringing the terminal bell
+	DisableBell bool `toml:"disable_bell,omitempty"`
+	// OSC9 shows desktop notifications through terminals supporting it
+	OSC9 bool `toml:"osc9,omitempty"`
+	// Webhook receives {"text": message} posts, like a Mattermost incoming
+	// webhook
+	Webhook string `toml:"webhook,omitempty"`
+	// Command is run with sh -c per notification, with MMDEV_EVENT,
+	// MMDEV_PROCESS, MMDEV_DETAIL and MMDEV_MESSAGE set
+	Command string `toml:"command,omitempty"`
+	// Events limits the notifications to build_failed, crashed or recovered
+	Events []string `toml:"events,omitempty"`
+}
+
+// StartConfig configures the panes of the mmdev start TUI
+type StartConfig struct {
+	// Layout is how the panes are arranged: rows, columns or grid
+	Layout string `toml:"layout,omitempty"`
+	// Scrollback is the number of lines of output kept per pane
+	Scrollback int `toml:"scrollback,omitempty"`
+	// SessionDir is where the logs of every session are written, by default
+	// in the mmdev cache directory
+	SessionDir string `toml:"session_dir,omitempty"`
+	// KeepSessions is the number of sessions whose logs are kept
+	KeepSessions int `toml:"keep_sessions,omitempty"`
+	// LogMaxSize is the size, in MB, at which the log of a pane is rotated
+	LogMaxSize int `toml:"log_max_size,omitempty"`
+	// LogMaxFiles is the number of rotated logs kept per pane
+	LogMaxFiles int  `toml:"log_max_files,omitempty"`
+	DisableLogs bool `toml:"disable_logs,omitempty"`
+	// Editor is the command opening the file locations of the output, with
+	// {file}, {line} and {col} placeholders. $VISUAL or $EDITOR by default.
+	Editor string       `toml:"editor,omitempty"`
+	Panes  []PaneConfig `toml:"panes,omitempty"`
+	// Commands are shell commands added to the command mode
+	Commands []CommandConfig `toml:"commands,omitempty"`
+}
+
+// CommandConfig is a shell command run from the command mode of the mmdev
+// start TUI
+type CommandConfig struct {
+	Name string `toml:"name"`
+	// Run is the command line, run with sh -c. The arguments given in the
+	// command mode are available as $1, $2...
+	Run         string `toml:"run"`
+	Dir         string `toml:"dir,omitempty"`
+	Description string `toml:"description,omitempty"`
+	// Pane shows the output of the command, the selected one by default
+	Pane string `toml:"pane,omitempty"`
+}
+
+// PaneConfig is a process shown in its own pane of the mmdev start TUI
+type PaneConfig struct {
+	Name    string            `toml:"name"`
+	Command []string          `toml:"command"`
+	Dir     string            `toml:"dir,omitempty"`
+	Env     map[string]string `toml:"env,omitempty"`
+	// Autostart starts the process with the TUI, true when not set
+	Autostart *bool `toml:"autostart,omitempty"`
+	// RestartSignal, like SIGUSR1, is sent to restart the process in place
+	// instead of stopping and starting it again
+	RestartSignal string `toml:"restart_signal,omitempty"`
+	// Filter only shows the output lines matching this regular expression
+	Filter string `toml:"filter,omitempty"`
+	// Level hides the log lines below this level
+	Level string `toml:"level,omitempty"`
+	// ReadyURL is polled to know when the process is ready, any 2xx response
+	// meaning it is
+	ReadyURL string `toml:"ready_url,omitempty"`
+	// ReadyPattern marks the process as ready when an output line matches it
+	ReadyPattern string `toml:"ready_pattern,omitempty"`
+	// BuildingPattern marks the process as building when an output line
+	// matches it, until it is ready
+	BuildingPattern string `toml:"building_pattern,omitempty"`
 }
 
 type WeblateConfig struct {
@@ -29,7 +110,6 @@ type JiraConfig struct {
 	Token    string `toml:"token"`
 }
 
-
 func LoadConfig() (*Config, error) {
 	config := &Config{}
 
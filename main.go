@@ -48,6 +48,8 @@ func main() {
 		webapp.WebappCmd(),
 		docker.DockerCmd(),
 		start.StartCmd(),
+		start.AttachCmd(),
+		start.CtlCmd(),
 		e2e.E2ECmd(),
 		aider.AiderCmd(),
 		config.ConfigCmd(),
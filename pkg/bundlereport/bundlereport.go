@@ -0,0 +1,309 @@
+package bundlereport
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// minChunkDelta is the growth, in gzip bytes, below which a chunk is never
+// considered a regression, to ignore the noise of small chunks
+const minChunkDelta = 1024
+
+// hashRegexp matches the content hash webpack adds to file names, like the
+// 3f2a1b9c in main.3f2a1b9c.js
+var hashRegexp = regexp.MustCompile(`\.[0-9a-f]{8,32}(\.[A-Za-z0-9.]+)$`)
+
+// analyzedExtensions are the files of the build counted in the report
+var analyzedExtensions = []string{".js", ".css", ".wasm"}
+
+// Chunk is the size of the files of a build with the same name once the
+// content hash is removed
+type Chunk struct {
+	Name  string `json:"name"`
+	Files int    `json:"files"`
+	Size  int64  `json:"size"`
+	Gzip  int64  `json:"gzip"`
+}
+
+// Report is the size of a build
+type Report struct {
+	Commit    string    `json:"commit,omitempty"`
+	Branch    string    `json:"branch,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+	Gzip      int64     `json:"gzip"`
+	Chunks    []Chunk   `json:"chunks"`
+}
+
+// Diff is the change of a chunk, or the total, between two reports
+type Diff struct {
+	Name   string `json:"name"`
+	Before int64  `json:"before"`
+	After  int64  `json:"after"`
+	// Regression is set when the growth is over the threshold
+	Regression bool `json:"regression"`
+}
+
+// Delta is the growth in gzip bytes
+func (d Diff) Delta() int64 {
+	return d.After - d.Before
+}
+
+// Percent is the growth relative to the size before
+func (d Diff) Percent() float64 {
+	if d.Before == 0 {
+		if d.After == 0 {
+			return 0
+		}
+		return 100
+	}
+	return float64(d.Delta()) * 100 / float64(d.Before)
+}
+
+// Analyze computes the raw and gzip sizes of the build in distDir
+func Analyze(distDir string) (*Report, error) {
+	chunks := make(map[string]*Chunk)
+	report := &Report{CreatedAt: time.Now()}
+
+	err := filepath.Walk(distDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isAnalyzed(path) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(distDir, path)
+		if err != nil {
+			return err
+		}
+		gz, err := gzipSize(path)
+		if err != nil {
+			return err
+		}
+
+		name := ChunkName(filepath.ToSlash(rel))
+		chunk, ok := chunks[name]
+		if !ok {
+			chunk = &Chunk{Name: name}
+			chunks[name] = chunk
+		}
+		chunk.Files++
+		chunk.Size += info.Size()
+		chunk.Gzip += gz
+		report.Size += info.Size()
+		report.Gzip += gz
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze %s: %w", distDir, err)
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no build output found in %s", distDir)
+	}
+
+	for _, chunk := range chunks {
+		report.Chunks = append(report.Chunks, *chunk)
+	}
+	sort.Slice(report.Chunks, func(i, j int) bool {
+		if report.Chunks[i].Gzip != report.Chunks[j].Gzip {
+			return report.Chunks[i].Gzip > report.Chunks[j].Gzip
+		}
+		return report.Chunks[i].Name < report.Chunks[j].Name
+	})
+	return report, nil
+}
+
+// ChunkName removes the content hash from a file name, so the same chunk can
+// be matched across builds
+func ChunkName(file string) string {
+	return hashRegexp.ReplaceAllString(file, "$1")
+}
+
+// Compare compares the gzip sizes of the total and every chunk of a report
+// against a baseline. A chunk is a regression when it grows more than
+// threshold percent, and more than a small noise floor.
+func Compare(baseline, current *Report, threshold float64) (Diff, []Diff) {
+	total := Diff{Name: "total", Before: baseline.Gzip, After: current.Gzip}
+	total.Regression = total.Delta() > 0 && total.Percent() > threshold
+
+	before := make(map[string]int64)
+	for _, chunk := range baseline.Chunks {
+		before[chunk.Name] = chunk.Gzip
+	}
+
+	var diffs []Diff
+	seen := make(map[string]bool)
+	for _, chunk := range current.Chunks {
+		seen[chunk.Name] = true
+		diff := Diff{Name: chunk.Name, Before: before[chunk.Name], After: chunk.Gzip}
+		diff.Regression = diff.Delta() > minChunkDelta && diff.Percent() > threshold
+		diffs = append(diffs, diff)
+	}
+	for _, chunk := range baseline.Chunks {
+		if !seen[chunk.Name] {
+			diffs = append(diffs, Diff{Name: chunk.Name, Before: chunk.Gzip})
+		}
+	}
+
+	sort.SliceStable(diffs, func(i, j int) bool {
+		return abs(diffs[i].Delta()) > abs(diffs[j].Delta())
+	})
+	return total, diffs
+}
+
+// DefaultBaselinePath returns where the baseline of a build directory is
+// kept, in the mmdev directory of the user cache
+func DefaultBaselinePath(distDir string) (string, error) {
+	abs, err := filepath.Abs(distDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get cache directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(cacheDir, "mmdev", "bundle-baselines", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// Load reads a report saved with Save
+func Load(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &report, nil
+}
+
+// Save writes the report as JSON to path
+func (r *Report) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteText writes the chunk sizes as a table, limited to the largest ones
+// when limit is positive
+func (r *Report) WriteText(w io.Writer, limit int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "SIZE\tGZIP\t  CHUNK")
+	for i, chunk := range r.Chunks {
+		if limit > 0 && i == limit {
+			fmt.Fprintf(tw, "\t\t  %d more chunks\n", len(r.Chunks)-limit)
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%s\t  %s\n", FormatBytes(chunk.Size), FormatBytes(chunk.Gzip), chunk.Name)
+	}
+	fmt.Fprintf(tw, "%s\t%s\t  %s\n", FormatBytes(r.Size), FormatBytes(r.Gzip), "total")
+	return tw.Flush()
+}
+
+// WriteDiffText writes the changes against a baseline as a table, skipping
+// the unchanged chunks
+func WriteDiffText(w io.Writer, total Diff, diffs []Diff) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "BEFORE\tAFTER\tDELTA\t\t  CHUNK")
+	rows := append(append([]Diff{}, diffs...), total)
+	for _, diff := range rows {
+		if diff.Delta() == 0 && diff.Name != total.Name {
+			continue
+		}
+		mark := ""
+		if diff.Regression {
+			mark = " !"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%+.1f%%\t  %s%s\n",
+			FormatBytes(diff.Before), FormatBytes(diff.After), formatDelta(diff.Delta()), diff.Percent(), diff.Name, mark)
+	}
+	return tw.Flush()
+}
+
+// FormatBytes formats a size in a human readable way
+func FormatBytes(size int64) string {
+	switch {
+	case abs(size) >= 1024*1024:
+		return fmt.Sprintf("%.2f MiB", float64(size)/(1024*1024))
+	case abs(size) >= 1024:
+		return fmt.Sprintf("%.1f KiB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+func formatDelta(delta int64) string {
+	if delta > 0 {
+		return "+" + FormatBytes(delta)
+	}
+	return FormatBytes(delta)
+}
+
+func isAnalyzed(path string) bool {
+	for _, ext := range analyzedExtensions {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipSize returns the size of a file once compressed with gzip
+func gzipSize(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	counter := &countingWriter{}
+	gz, err := gzip.NewWriterLevel(counter, gzip.BestCompression)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(gz, f); err != nil {
+		return 0, fmt.Errorf("failed to compress %s: %w", path, err)
+	}
+	if err := gz.Close(); err != nil {
+		return 0, err
+	}
+	return counter.n, nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,63 @@
+package bundlereport
+
+import "testing"
+
+func TestChunkName(t *testing.T) {
+	for file, expected := range map[string]string{
+		"main.3f2a1b9c.js":                 "main.js",
+		"main.js":                          "main.js",
+		"8734.2b7d4e5f6a7b8c9d.chunk.js":   "8734.chunk.js",
+		"files/emoji.0123456789abcdef.css": "files/emoji.css",
+		"vendors.beef.js":                  "vendors.beef.js",
+	} {
+		if got := ChunkName(file); got != expected {
+			t.Logf("%s: expected %v, got %v", file, expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	baseline := &Report{
+		Gzip: 200000,
+		Chunks: []Chunk{
+			{Name: "main.js", Gzip: 100000},
+			{Name: "small.js", Gzip: 1000},
+			{Name: "removed.js", Gzip: 99000},
+		},
+	}
+	current := &Report{
+		Gzip: 220000,
+		Chunks: []Chunk{
+			{Name: "main.js", Gzip: 120000},
+			{Name: "small.js", Gzip: 1500},
+			{Name: "added.js", Gzip: 98500},
+		},
+	}
+
+	total, diffs := Compare(baseline, current, 5)
+	if !total.Regression {
+		t.Logf("expected the 10%% total growth to be a regression")
+		t.Fail()
+	}
+
+	expected := map[string]bool{
+		"main.js":    true,
+		"small.js":   false, // under the noise floor
+		"added.js":   true,
+		"removed.js": false,
+	}
+	if len(diffs) != len(expected) {
+		t.Fatalf("expected %d diffs, got %d", len(expected), len(diffs))
+	}
+	for _, diff := range diffs {
+		if diff.Regression != expected[diff.Name] {
+			t.Logf("%s: expected regression %v, got %v", diff.Name, expected[diff.Name], diff.Regression)
+			t.Fail()
+		}
+	}
+	if diffs[0].Name != "removed.js" {
+		t.Logf("expected the largest change first, got %s", diffs[0].Name)
+		t.Fail()
+	}
+}
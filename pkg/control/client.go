@@ -0,0 +1,157 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jespino/mmdev/pkg/supervisor"
+)
+
+// requestTimeout limits the requests other than attaching and shutting down
+const requestTimeout = 10 * time.Second
+
+// ErrNotRunning is returned when no server listens on the socket
+var ErrNotRunning = errors.New("not running")
+
+// Client controls the processes served on a unix socket
+type Client struct {
+	path string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	events chan supervisor.Event
+}
+
+// Dial checks that a server listens on the socket and returns a client for
+// it
+func Dial(path string) (*Client, error) {
+	if !Running(path) {
+		return nil, ErrNotRunning
+	}
+	return &Client{path: path, events: make(chan supervisor.Event, clientBuffer)}, nil
+}
+
+// send opens a connection sending a request, and reads its response
+func (c *Client) send(req request, timeout time.Duration) (net.Conn, *json.Decoder, response, error) {
+	conn, err := net.Dial("unix", c.path)
+	if err != nil {
+		return nil, nil, response{}, fmt.Errorf("failed to connect to %s: %w", c.path, err)
+	}
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	var resp response
+	dec := json.NewDecoder(conn)
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		conn.Close()
+		return nil, nil, resp, fmt.Errorf("failed to send the request: %w", err)
+	}
+	if err := dec.Decode(&resp); err != nil {
+		conn.Close()
+		return nil, nil, resp, fmt.Errorf("failed to read the response: %w", err)
+	}
+	if resp.Error != "" {
+		conn.Close()
+		return nil, nil, resp, errors.New(resp.Error)
+	}
+	return conn, dec, resp, nil
+}
+
+func (c *Client) do(action, process string) error {
+	conn, _, _, err := c.send(request{Action: action, Process: process}, requestTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Start starts a run of a process
+func (c *Client) Start(name string) error {
+	return c.do(actionStart, name)
+}
+
+// Stop asks a process to stop
+func (c *Client) Stop(name string) error {
+	return c.do(actionStop, name)
+}
+
+// Restart restarts a process
+func (c *Client) Restart(name string) error {
+	return c.do(actionRestart, name)
+}
+
+// Status returns the state of the processes, in the order they were added
+func (c *Client) Status() ([]Status, error) {
+	conn, _, resp, err := c.send(request{Action: actionStatus}, requestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+	return resp.Status, nil
+}
+
+// Shutdown asks the server to stop all the processes and waits for it to
+// close
+func (c *Client) Shutdown() error {
+	conn, _, _, err := c.send(request{Action: actionShutdown}, 0)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// The server closes the connection when done
+	var buf [1]byte
+	if _, err := conn.Read(buf[:]); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to wait for the shutdown: %w", err)
+	}
+	return nil
+}
+
+// Attach decodes the info of the server into info and starts receiving the
+// events of the processes, the ones in its history first. The events
+// channel is closed when the server goes away.
+func (c *Client) Attach(info any) error {
+	conn, dec, resp, err := c.send(request{Action: actionAttach}, requestTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+	if err := json.Unmarshal(resp.Info, info); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to decode the server info: %w", err)
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+	go func() {
+		defer close(c.events)
+		for {
+			var resp response
+			if err := dec.Decode(&resp); err != nil || resp.Event == nil {
+				return
+			}
+			c.events <- resp.Event.event()
+		}
+	}()
+	return nil
+}
+
+// Events returns the channel receiving the events once attached
+func (c *Client) Events() <-chan supervisor.Event {
+	return c.events
+}
+
+// Close detaches the client, leaving the processes running
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
@@ -0,0 +1,196 @@
+package control
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jespino/mmdev/pkg/supervisor"
+)
+
+func TestHistory(t *testing.T) {
+	h := NewHistory(2)
+	for _, event := range []supervisor.Event{
+		{Process: "old", Type: supervisor.Starting, Run: 1},
+		{Process: "old", Type: supervisor.Exited, Err: errors.New("exit status 3"), Status: "exit 3"},
+		{Process: "server", Type: supervisor.Starting, Run: 1},
+		{Process: "server", Type: supervisor.Output, Line: "one"},
+		{Process: "server", Type: supervisor.Exited},
+		{Process: "server", Type: supervisor.Starting, Run: 2},
+		{Process: "server", Type: supervisor.Running, Pid: 42},
+		{Process: "server", Type: supervisor.Output, Line: "two"},
+		{Process: "server", Type: supervisor.Output, Line: "three"},
+		{Process: "server", Type: supervisor.Ready},
+	} {
+		h.Add(event)
+	}
+
+	var replayed []string
+	for _, event := range h.Events() {
+		if event.Process != "server" {
+			continue
+		}
+		replayed = append(replayed, event.Type.String()+":"+event.Line)
+	}
+	expected := []string{"starting:", "running:", "output:two", "output:three", "ready:"}
+	if !reflect.DeepEqual(replayed, expected) {
+		t.Logf("expected %q, got %q", expected, replayed)
+		t.Fail()
+	}
+
+	for name, tc := range map[string]struct {
+		process  string
+		expected Status
+	}{
+		"ready": {
+			process:  "server",
+			expected: Status{Process: "server", State: "ready", Run: 2, Pid: 42},
+		},
+		"crashed": {
+			process:  "old",
+			expected: Status{Process: "old", State: "exited (exit 3)", Run: 1},
+		},
+		"never started": {
+			process:  "webapp",
+			expected: Status{Process: "webapp", State: "stopped"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			status := h.Status(tc.process)
+			if !reflect.DeepEqual(status, tc.expected) {
+				t.Logf("expected %+v, got %+v", tc.expected, status)
+				t.Fail()
+			}
+		})
+	}
+}
+
+// waitEvent returns the first event of the client matching the condition
+func waitEvent(t *testing.T, c *Client, match func(supervisor.Event) bool) supervisor.Event {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-c.Events():
+			if !ok {
+				t.Fatal("disconnected")
+			}
+			if match(event) {
+				return event
+			}
+		case <-timeout:
+			t.Fatal("timeout waiting for an event")
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	if _, err := Dial(path); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("expected no server, got %v", err)
+	}
+
+	s := supervisor.New()
+	s.Add("echo", &supervisor.Command{Args: []string{"sh", "-c", "echo hello; exec sleep 10"}})
+	srv, err := Listen(path, s, 100, map[string]string{"session": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stopped := make(chan struct{})
+		go func() {
+			<-srv.ShutdownRequested()
+			s.Shutdown()
+			close(stopped)
+		}()
+		for {
+			select {
+			case event := <-s.Events():
+				srv.Publish(event)
+			case <-stopped:
+				for len(s.Events()) > 0 {
+					srv.Publish(<-s.Events())
+				}
+				srv.Close()
+				return
+			}
+		}
+	}()
+	if err := s.Start("echo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen(path, s, 100, nil); err == nil {
+		t.Fatal("expected a second server on the same socket to fail")
+	}
+
+	c, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info map[string]string
+	if err := c.Attach(&info); err != nil {
+		t.Fatal(err)
+	}
+	if info["session"] != "test" {
+		t.Logf("expected the server info, got %v", info)
+		t.Fail()
+	}
+	waitEvent(t, c, func(event supervisor.Event) bool {
+		return event.Type == supervisor.Output && event.Line == "hello"
+	})
+
+	if err := c.Restart("echo"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, c, func(event supervisor.Event) bool {
+		return event.Type == supervisor.Ready && event.Run == 2
+	})
+	statuses, err := c.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].State != "ready" || statuses[0].Run != 2 || statuses[0].Pid == 0 {
+		t.Logf("expected the echo process ready in its second run, got %+v", statuses)
+		t.Fail()
+	}
+	if err := c.Start("missing"); err == nil {
+		t.Log("expected an error starting an unknown process")
+		t.Fail()
+	}
+
+	if err := c.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, c, func(event supervisor.Event) bool {
+		return event.Type == supervisor.Exited && event.Stopped
+	})
+	<-done
+	if Running(path) {
+		t.Log("expected the server to be closed")
+		t.Fail()
+	}
+}
+
+func TestShutdownGone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	srv, err := Listen(path, supervisor.New(), 100, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve()
+	c, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server goes away between the dial and the request
+	srv.Close()
+	if err := c.Shutdown(); err == nil {
+		t.Log("expected an error shutting down a server that went away")
+		t.Fail()
+	}
+}
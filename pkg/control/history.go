@@ -0,0 +1,132 @@
+package control
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jespino/mmdev/pkg/supervisor"
+)
+
+// History keeps the recent events of the processes, to replay them to the
+// clients attaching later: the last output lines of every process and the
+// state changes of their current run
+type History struct {
+	size int
+
+	mu        sync.Mutex
+	seq       uint64
+	processes map[string]*processHistory
+}
+
+type entry struct {
+	seq   uint64
+	event supervisor.Event
+}
+
+type processHistory struct {
+	output []entry
+	// state are the events of the current run other than the output
+	state []entry
+}
+
+// NewHistory creates a history keeping up to size output lines per process
+func NewHistory(size int) *History {
+	return &History{
+		size:      size,
+		processes: make(map[string]*processHistory),
+	}
+}
+
+// Add records an event
+func (h *History) Add(event supervisor.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	p, ok := h.processes[event.Process]
+	if !ok {
+		p = &processHistory{}
+		h.processes[event.Process] = p
+	}
+
+	e := entry{seq: h.seq, event: event}
+	switch event.Type {
+	case supervisor.Output:
+		p.output = append(p.output, e)
+		if len(p.output) > h.size {
+			p.output = p.output[len(p.output)-h.size:]
+		}
+	case supervisor.Starting:
+		p.state = []entry{e}
+	default:
+		p.state = append(p.state, e)
+	}
+}
+
+// Events returns the events kept, in the order they happened
+func (h *History) Events() []supervisor.Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var entries []entry
+	for _, p := range h.processes {
+		entries = append(entries, p.output...)
+		entries = append(entries, p.state...)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seq < entries[j].seq
+	})
+
+	events := make([]supervisor.Event, len(entries))
+	for i, e := range entries {
+		events[i] = e.event
+	}
+	return events
+}
+
+// Status is the state of a process
+type Status struct {
+	Process string `json:"process"`
+	// State is the last state change of the current run, like "ready" or
+	// "building", or "stopped"
+	State string `json:"state"`
+	Run   int    `json:"run,omitempty"`
+	Pid   int    `json:"pid,omitempty"`
+	// Since is when the current run started
+	Since time.Time `json:"since,omitempty"`
+}
+
+// Status returns the state of a process from its events
+func (h *History) Status(process string) Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	status := Status{Process: process, State: "stopped"}
+	p, ok := h.processes[process]
+	if !ok {
+		return status
+	}
+
+	for _, e := range p.state {
+		event := e.event
+		switch event.Type {
+		case supervisor.Starting:
+			status.Run = event.Run
+			status.Since = event.Time
+		case supervisor.Running:
+			// Not a state, the process is starting until ready
+			status.Pid = event.Pid
+			continue
+		case supervisor.Exited:
+			status.Pid = 0
+		}
+		status.State = event.Type.String()
+		if event.Type == supervisor.Exited {
+			switch {
+			case event.Stopped:
+				status.State = "stopped"
+			case event.Status != "":
+				status.State += " (" + event.Status + ")"
+			}
+		}
+	}
+	return status
+}
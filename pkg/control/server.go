@@ -0,0 +1,328 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jespino/mmdev/pkg/supervisor"
+)
+
+// clientBuffer is the number of events waiting to be sent to an attached
+// client before it's dropped for being too slow
+const clientBuffer = 4096
+
+// Actions of the requests
+const (
+	actionAttach   = "attach"
+	actionStart    = "start"
+	actionStop     = "stop"
+	actionRestart  = "restart"
+	actionStatus   = "status"
+	actionShutdown = "shutdown"
+)
+
+// request is the first and only message of a client connection, encoded as
+// JSON like the responses
+type request struct {
+	Action  string `json:"action"`
+	Process string `json:"process,omitempty"`
+}
+
+// response answers a request. Attaching clients get the info of the server
+// and then one response per event.
+type response struct {
+	Error  string          `json:"error,omitempty"`
+	Info   json.RawMessage `json:"info,omitempty"`
+	Status []Status        `json:"status,omitempty"`
+	Event  *wireEvent      `json:"event,omitempty"`
+}
+
+// wireEvent is a supervisor event as sent to the clients, with the error as
+// a message
+type wireEvent struct {
+	Process string               `json:"process"`
+	Type    supervisor.EventType `json:"type"`
+	Time    time.Time            `json:"time"`
+	Run     int                  `json:"run,omitempty"`
+	Line    string               `json:"line,omitempty"`
+	Pid     int                  `json:"pid,omitempty"`
+	Err     string               `json:"err,omitempty"`
+	Stopped bool                 `json:"stopped,omitempty"`
+	Status  string               `json:"status,omitempty"`
+}
+
+func toWire(event supervisor.Event) *wireEvent {
+	w := &wireEvent{
+		Process: event.Process,
+		Type:    event.Type,
+		Time:    event.Time,
+		Run:     event.Run,
+		Line:    event.Line,
+		Pid:     event.Pid,
+		Stopped: event.Stopped,
+		Status:  event.Status,
+	}
+	if event.Err != nil {
+		w.Err = event.Err.Error()
+	}
+	return w
+}
+
+func (w *wireEvent) event() supervisor.Event {
+	event := supervisor.Event{
+		Process: w.Process,
+		Type:    w.Type,
+		Time:    w.Time,
+		Run:     w.Run,
+		Line:    w.Line,
+		Pid:     w.Pid,
+		Stopped: w.Stopped,
+		Status:  w.Status,
+	}
+	if w.Err != "" {
+		event.Err = errors.New(w.Err)
+	}
+	return event
+}
+
+// Server exposes the processes of a supervisor on a unix socket, so they
+// survive the terminal that started them. The events of the supervisor must
+// be published to it.
+type Server struct {
+	supervisor *supervisor.Supervisor
+	info       json.RawMessage
+	path       string
+	listener   net.Listener
+	conns      sync.WaitGroup
+
+	mu      sync.Mutex
+	history *History
+	clients map[*client]bool
+
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+	closed       chan struct{}
+	closeOnce    sync.Once
+}
+
+// client is an attached client, receiving the events
+type client struct {
+	events chan supervisor.Event
+	// dropped is closed when the client falls behind
+	dropped chan struct{}
+}
+
+// Listen creates the socket serving the processes of a supervisor, keeping
+// up to history output lines per process for the clients attaching. The
+// info, encoded as JSON, is sent to the clients when they attach.
+func Listen(path string, s *supervisor.Supervisor, history int, info any) (*Server, error) {
+	encoded, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the server info: %w", err)
+	}
+	if Running(path) {
+		return nil, fmt.Errorf("already running on %s", path)
+	}
+	// A socket left behind by a server that didn't close
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict access to %s: %w", path, err)
+	}
+
+	return &Server{
+		supervisor: s,
+		info:       encoded,
+		path:       path,
+		listener:   listener,
+		history:    NewHistory(history),
+		clients:    make(map[*client]bool),
+		shutdown:   make(chan struct{}),
+		closed:     make(chan struct{}),
+	}, nil
+}
+
+// Serve accepts the clients until the server is closed
+func (srv *Server) Serve() error {
+	for {
+		conn, err := srv.listener.Accept()
+		if err != nil {
+			select {
+			case <-srv.closed:
+				return nil
+			default:
+				return fmt.Errorf("failed to accept connection: %w", err)
+			}
+		}
+		srv.conns.Add(1)
+		go func() {
+			defer srv.conns.Done()
+			defer conn.Close()
+			srv.handle(conn)
+		}()
+	}
+}
+
+// Publish records an event and sends it to the attached clients
+func (srv *Server) Publish(event supervisor.Event) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.history.Add(event)
+	for c := range srv.clients {
+		select {
+		case c.events <- event:
+		default:
+			close(c.dropped)
+			delete(srv.clients, c)
+		}
+	}
+}
+
+// ShutdownRequested is closed when a client asks to stop the processes. The
+// server has to be closed once they are stopped.
+func (srv *Server) ShutdownRequested() <-chan struct{} {
+	return srv.shutdown
+}
+
+// Close stops serving, disconnecting the clients once they got the events
+// published, and removes the socket
+func (srv *Server) Close() error {
+	var err error
+	srv.closeOnce.Do(func() {
+		close(srv.closed)
+		err = srv.listener.Close()
+		srv.conns.Wait()
+		os.Remove(srv.path)
+	})
+	return err
+}
+
+func (srv *Server) handle(conn net.Conn) {
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+	enc := json.NewEncoder(conn)
+
+	var err error
+	switch req.Action {
+	case actionAttach:
+		srv.attach(conn, enc)
+		return
+	case actionStart:
+		err = srv.supervisor.Start(req.Process)
+	case actionStop:
+		err = srv.supervisor.Stop(req.Process)
+	case actionRestart:
+		err = srv.supervisor.Restart(req.Process)
+	case actionStatus:
+		var statuses []Status
+		for _, name := range srv.supervisor.Names() {
+			statuses = append(statuses, srv.history.Status(name))
+		}
+		enc.Encode(response{Status: statuses})
+		return
+	case actionShutdown:
+		enc.Encode(response{})
+		srv.shutdownOnce.Do(func() {
+			close(srv.shutdown)
+		})
+		// The client waits for the connection to close
+		<-srv.closed
+		return
+	default:
+		err = fmt.Errorf("unknown action %q", req.Action)
+	}
+
+	resp := response{}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	enc.Encode(resp)
+}
+
+// attach sends the info, the history and then the events as they are
+// published, until the client leaves or the server is closed
+func (srv *Server) attach(conn net.Conn, enc *json.Encoder) {
+	c := &client{
+		events:  make(chan supervisor.Event, clientBuffer),
+		dropped: make(chan struct{}),
+	}
+	srv.mu.Lock()
+	history := srv.history.Events()
+	srv.clients[c] = true
+	srv.mu.Unlock()
+	defer func() {
+		srv.mu.Lock()
+		delete(srv.clients, c)
+		srv.mu.Unlock()
+	}()
+
+	if err := enc.Encode(response{Info: srv.info}); err != nil {
+		return
+	}
+	for _, event := range history {
+		if err := enc.Encode(response{Event: toWire(event)}); err != nil {
+			return
+		}
+	}
+
+	// Clients don't send anything else, reading only tells when they leave
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, conn)
+		close(gone)
+	}()
+	for {
+		select {
+		case event := <-c.events:
+			if err := enc.Encode(response{Event: toWire(event)}); err != nil {
+				return
+			}
+		case <-c.dropped:
+			enc.Encode(response{Error: "disconnected for falling behind"})
+			return
+		case <-gone:
+			return
+		case <-srv.closed:
+			// Send the last events, like the processes exiting
+			conn.SetWriteDeadline(time.Now().Add(time.Second))
+			for {
+				select {
+				case event := <-c.events:
+					if err := enc.Encode(response{Event: toWire(event)}); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Running checks if a server is listening on the socket
+func Running(path string) bool {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -1,11 +1,15 @@
 package docker
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"os"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -14,6 +18,7 @@ import (
 	containerTypes "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
 )
 
@@ -31,6 +36,15 @@ const (
 	Playwright    Service = "playwright"
 )
 
+// ParseService returns the service with the given name
+func ParseService(name string) (Service, error) {
+	service := Service(name)
+	if _, ok := serviceConfigs[service]; !ok {
+		return "", fmt.Errorf("unknown docker service %q", name)
+	}
+	return service, nil
+}
+
 // ServiceConfig holds configuration for a Docker service
 type ServiceConfig struct {
 	Image        string
@@ -157,6 +171,9 @@ type Manager struct {
 	ctx       context.Context
 	services  []Service
 	networkID string
+	output    io.Writer
+	// started are the services whose containers weren't running before Start
+	started []Service
 }
 
 // EnsureImage ensures a Docker image is available locally, pulling it if needed
@@ -175,7 +192,23 @@ func (m *Manager) EnsureImage(image string) error {
 	defer reader.Close()
 
 	decoder := json.NewDecoder(reader)
-	fmt.Printf("Pulling image %s\n", image)
+	fmt.Fprintf(m.output, "Pulling image %s\n", image)
+	if m.output != os.Stdout {
+		// Without a terminal to redraw the layers on, wait for the pull
+		for decoder.More() {
+			var pullStatus struct {
+				Error string `json:"error"`
+			}
+			if err := decoder.Decode(&pullStatus); err != nil {
+				return fmt.Errorf("failed to decode pull status: %w", err)
+			}
+			if pullStatus.Error != "" {
+				return fmt.Errorf("failed to pull image %s: %s", image, pullStatus.Error)
+			}
+		}
+		fmt.Fprintf(m.output, "Pulled image %s\n", image)
+		return nil
+	}
 
 	layerStatus := make(map[string]string)
 	for decoder.More() {
@@ -244,22 +277,29 @@ func NewManager() (*Manager, error) {
 		client:   cli,
 		ctx:      context.Background(),
 		services: make([]Service, 0),
+		output:   os.Stdout,
 	}, nil
 }
 
+// SetOutput sets where the progress messages are written, stdout by
+// default. The image pull progress is only redrawn in place on stdout.
+func (m *Manager) SetOutput(w io.Writer) {
+	m.output = w
+}
+
 // EnableService adds a service to be started
 func (m *Manager) EnableService(service Service) {
 	m.services = append(m.services, service)
 }
 
+// DefaultServices are the services started for development
+var DefaultServices = []Service{Minio, OpenLDAP, Elasticsearch, Postgres, Inbucket, Redis}
+
 // SetupDefaultServices configures the manager with the default set of services
 func (m *Manager) SetupDefaultServices() {
-	m.EnableService(Minio)
-	m.EnableService(OpenLDAP)
-	m.EnableService(Elasticsearch)
-	m.EnableService(Postgres)
-	m.EnableService(Inbucket)
-	m.EnableService(Redis)
+	for _, service := range DefaultServices {
+		m.EnableService(service)
+	}
 }
 
 // Start starts the Docker services
@@ -347,15 +387,17 @@ func (m *Manager) Start() error {
 			}
 
 			if inspect.State.Running {
-				fmt.Printf("Container %s is already running\n", service)
+				fmt.Fprintf(m.output, "Container %s is already running\n", service)
 			} else {
-				fmt.Printf("Starting existing %s container\n", service)
+				fmt.Fprintf(m.output, "Starting existing %s container\n", service)
 				if err := m.client.ContainerStart(m.ctx, containerID, types.ContainerStartOptions{}); err != nil {
 					// If we can't start it, remove and recreate
 					if err := m.client.ContainerRemove(m.ctx, containerID, types.ContainerRemoveOptions{Force: true}); err != nil {
 						return fmt.Errorf("failed to remove container %s: %w", containerName, err)
 					}
 					existingContainer = nil
+				} else {
+					m.started = append(m.started, service)
 				}
 			}
 		}
@@ -391,12 +433,13 @@ func (m *Manager) Start() error {
 			if err := m.client.ContainerStart(m.ctx, containerID, types.ContainerStartOptions{}); err != nil {
 				return fmt.Errorf("failed to start container %s: %w", containerName, err)
 			}
-			fmt.Printf("Created and started new %s container\n", service)
+			m.started = append(m.started, service)
+			fmt.Fprintf(m.output, "Created and started new %s container\n", service)
 		}
 	}
 
 	// Wait for containers to be ready
-	fmt.Println("Waiting for services to be ready...")
+	fmt.Fprintln(m.output, "Waiting for services to be ready...")
 	for _, service := range m.services {
 		config, ok := serviceConfigs[service]
 		if !ok {
@@ -412,7 +455,7 @@ func (m *Manager) Start() error {
 					return fmt.Errorf("service %s failed to become ready: %w", service, err)
 				}
 			}
-			fmt.Printf("Service %s ports are ready\n", service)
+			fmt.Fprintf(m.output, "Service %s ports are ready\n", service)
 		}
 
 		// Additional service-specific health checks
@@ -421,16 +464,16 @@ func (m *Manager) Start() error {
 			if err := m.waitForElasticsearch(); err != nil {
 				return fmt.Errorf("elasticsearch failed health check: %w", err)
 			}
-			fmt.Println("Elasticsearch is fully ready")
+			fmt.Fprintln(m.output, "Elasticsearch is fully ready")
 		case Minio:
 			if err := m.waitForMinio(); err != nil {
 				return fmt.Errorf("minio failed health check: %w", err)
 			}
-			fmt.Println("MinIO is fully ready")
+			fmt.Fprintln(m.output, "MinIO is fully ready")
 		}
 	}
 
-	fmt.Println("All services are ready!")
+	fmt.Fprintln(m.output, "All services are ready!")
 	return nil
 }
 
@@ -445,10 +488,10 @@ func (m *Manager) Stop() error {
 		// Check for our container name prefix
 		for _, name := range container.Names {
 			if strings.HasPrefix(name, "/mmdev-") {
-				fmt.Printf("Stopping container %s\n", name)
+				fmt.Fprintf(m.output, "Stopping container %s\n", name)
 				if err := m.client.ContainerStop(m.ctx, container.ID, containerTypes.StopOptions{Timeout: new(int)}); err != nil {
 					// Only log stop errors since container might already be stopped
-					fmt.Printf("Warning: failed to stop container %s: %v\n", name, err)
+					fmt.Fprintf(m.output, "Warning: failed to stop container %s: %v\n", name, err)
 				}
 				break
 			}
@@ -458,6 +501,88 @@ func (m *Manager) Stop() error {
 	return nil
 }
 
+// StopStarted stops the containers started by Start, leaving running the
+// ones that were already running
+func (m *Manager) StopStarted() error {
+	for _, service := range m.started {
+		containerName := fmt.Sprintf("mmdev-%s", service)
+		fmt.Fprintf(m.output, "Stopping container %s\n", containerName)
+		if err := m.client.ContainerStop(m.ctx, containerName, containerTypes.StopOptions{Timeout: new(int)}); err != nil {
+			return fmt.Errorf("failed to stop container %s: %w", containerName, err)
+		}
+	}
+	m.started = nil
+	return nil
+}
+
+// CreateDatabase creates a database in the postgres service unless it
+// already exists
+func (m *Manager) CreateDatabase(name string) error {
+	if !databaseName.MatchString(name) {
+		return fmt.Errorf("invalid database name %q", name)
+	}
+	script := fmt.Sprintf("psql -U mmuser -d postgres -tAc \"SELECT 1 FROM pg_database WHERE datname = '%s'\" | grep -q 1 || createdb -U mmuser %s", name, name)
+	if out, err := m.exec(Postgres, "sh", "-c", script); err != nil {
+		return fmt.Errorf("failed to create database %s: %w: %s", name, err, strings.TrimSpace(out))
+	}
+	return nil
+}
+
+var databaseName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// exec runs a command in the container of a service, returning its output
+func (m *Manager) exec(service Service, cmd ...string) (string, error) {
+	containerName := fmt.Sprintf("mmdev-%s", service)
+	created, err := m.client.ContainerExecCreate(m.ctx, containerName, types.ExecConfig{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	resp, err := m.client.ContainerExecAttach(m.ctx, created.ID, types.ExecStartCheck{})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Close()
+
+	var out bytes.Buffer
+	if _, err := stdcopy.StdCopy(&out, &out, resp.Reader); err != nil {
+		return out.String(), err
+	}
+	inspect, err := m.client.ContainerExecInspect(m.ctx, created.ID)
+	if err != nil {
+		return out.String(), err
+	}
+	if inspect.ExitCode != 0 {
+		return out.String(), fmt.Errorf("exit status %d", inspect.ExitCode)
+	}
+	return out.String(), nil
+}
+
+// Restart restarts the container of a service and waits for its ports to
+// accept connections
+func (m *Manager) Restart(service Service) error {
+	config, ok := serviceConfigs[service]
+	if !ok {
+		return fmt.Errorf("no configuration found for service %s", service)
+	}
+
+	containerName := fmt.Sprintf("mmdev-%s", service)
+	fmt.Fprintf(m.output, "Restarting container %s\n", containerName)
+	if err := m.client.ContainerRestart(m.ctx, containerName, containerTypes.StopOptions{}); err != nil {
+		return fmt.Errorf("failed to restart container %s: %w", containerName, err)
+	}
+	for hostPort := range config.ExposedPorts {
+		if err := m.waitForPort(containerName, hostPort); err != nil {
+			return fmt.Errorf("service %s failed to become ready: %w", service, err)
+		}
+	}
+	fmt.Fprintf(m.output, "Service %s is ready\n", service)
+	return nil
+}
+
 // Clean removes all Docker containers and volumes
 // EnsurePlaywrightImage ensures the Playwright Docker image is available
 func (m *Manager) EnsurePlaywrightImage() error {
@@ -478,10 +603,10 @@ func (m *Manager) Clean() error {
 		// Check for our container name prefix
 		for _, name := range container.Names {
 			if strings.HasPrefix(name, "/mmdev-") {
-				fmt.Printf("Stopping container %s\n", name)
+				fmt.Fprintf(m.output, "Stopping container %s\n", name)
 				if err := m.client.ContainerStop(m.ctx, container.ID, containerTypes.StopOptions{Timeout: new(int)}); err != nil {
 					// Only log stop errors since container might already be stopped
-					fmt.Printf("Warning: failed to stop container %s: %v\n", name, err)
+					fmt.Fprintf(m.output, "Warning: failed to stop container %s: %v\n", name, err)
 				}
 				if err := m.client.ContainerRemove(m.ctx, container.ID, types.ContainerRemoveOptions{}); err != nil {
 					return fmt.Errorf("failed to remove container %s: %w", name, err)
@@ -494,7 +619,7 @@ func (m *Manager) Clean() error {
 	if m.networkID != "" {
 		// Try to remove network, but don't fail if it's in use
 		if err := m.client.NetworkRemove(m.ctx, m.networkID); err != nil {
-			fmt.Printf("Warning: failed to remove network: %v\n", err)
+			fmt.Fprintf(m.output, "Warning: failed to remove network: %v\n", err)
 		}
 	}
 
@@ -7,6 +7,9 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/docker/docker/api/types"
@@ -15,10 +18,93 @@ import (
 	"github.com/docker/docker/client"
 )
 
+// playwrightDir is where the Playwright tests are, relative to the
+// Mattermost repository
+const playwrightDir = "e2e-tests/playwright"
+
+var (
+	workersRegexp = regexp.MustCompile(`^[1-9]\d*%?$`)
+	shardRegexp   = regexp.MustCompile(`^(\d+)/(\d+)$`)
+)
+
+// PlaywrightOptions are passed to playwright test when running the tests
+type PlaywrightOptions struct {
+	// Specs are the spec files or directories to run, all by default. They
+	// can be given from the repository root or the Playwright directory.
+	Specs []string
+	// Grep only runs the tests whose title matches this regular expression
+	Grep     string
+	Projects []string
+	// Workers is a number of workers or a percentage of the CPU cores
+	Workers string
+	// Shard is the part of the tests to run, like 1/3
+	Shard string
+	// Retries overrides the retries of the Playwright configuration
+	Retries         *int
+	UpdateSnapshots bool
+	// Headed runs the browsers headed, on a virtual display of the container
+	Headed bool
+}
+
+// Validate checks the workers, shard and retries
+func (o PlaywrightOptions) Validate() error {
+	if o.Workers != "" && !workersRegexp.MatchString(o.Workers) {
+		return fmt.Errorf("invalid workers %q, expected a number or a percentage like 50%%", o.Workers)
+	}
+	if o.Shard != "" {
+		m := shardRegexp.FindStringSubmatch(o.Shard)
+		if m == nil {
+			return fmt.Errorf("invalid shard %q, expected current/total like 1/3", o.Shard)
+		}
+		current, _ := strconv.Atoi(m[1])
+		total, _ := strconv.Atoi(m[2])
+		if current < 1 || current > total {
+			return fmt.Errorf("invalid shard %q, the current shard must be between 1 and %d", o.Shard, total)
+		}
+	}
+	if o.Retries != nil && *o.Retries < 0 {
+		return fmt.Errorf("invalid retries %d, expected zero or more", *o.Retries)
+	}
+	return nil
+}
+
+// Args returns the arguments of playwright test
+func (o PlaywrightOptions) Args() []string {
+	var args []string
+	for _, spec := range o.Specs {
+		// Playwright matches them against the paths of the spec files
+		spec = strings.TrimPrefix(filepath.ToSlash(spec), "./")
+		args = append(args, strings.TrimPrefix(spec, playwrightDir+"/"))
+	}
+	if o.Grep != "" {
+		args = append(args, "--grep", o.Grep)
+	}
+	for _, project := range o.Projects {
+		args = append(args, "--project", project)
+	}
+	if o.Workers != "" {
+		args = append(args, "--workers", o.Workers)
+	}
+	if o.Shard != "" {
+		args = append(args, "--shard", o.Shard)
+	}
+	if o.Retries != nil {
+		args = append(args, "--retries", strconv.Itoa(*o.Retries))
+	}
+	if o.UpdateSnapshots {
+		args = append(args, "--update-snapshots")
+	}
+	if o.Headed {
+		args = append(args, "--headed")
+	}
+	return args
+}
+
 type PlaywrightRunner struct {
 	client      *client.Client
 	baseDir     string
 	action      string
+	options     PlaywrightOptions
 	containerID string
 }
 
@@ -35,6 +121,11 @@ func NewPlaywrightRunner(baseDir string, action string) (*PlaywrightRunner, erro
 	}, nil
 }
 
+// SetOptions sets the options of playwright test for the run action
+func (r *PlaywrightRunner) SetOptions(opts PlaywrightOptions) {
+	r.options = opts
+}
+
 func (r *PlaywrightRunner) RunTests() error {
 	ctx := context.Background()
 
@@ -60,22 +151,31 @@ func (r *PlaywrightRunner) RunTests() error {
 	}
 
 	// Determine command based on action
-	var cmd string
+	var cmd []string
 	switch r.action {
 	case "report":
-		cmd = "npm install && npm run show-report results/reporter"
+		cmd = []string{"sh", "-c", "npm install && npm run show-report results/reporter"}
 	default: // "run" is the default action
-		cmd = "npm install && npm run test"
+		if err := r.options.Validate(); err != nil {
+			return err
+		}
+		test := "npm run test"
+		if r.options.Headed {
+			// There is no display in the container
+			test = "xvfb-run " + test
+		}
+		// The arguments are given to sh, not to parse them as shell code
+		cmd = append([]string{"sh", "-c", "npm install && " + test + ` -- "$@"`, "sh"}, r.specArgs(absBaseDir)...)
 	}
 
 	// Create container config
 	config := &container.Config{
 		Image:        "mcr.microsoft.com/playwright:v1.49.0-noble",
-		Cmd:          []string{"sh", "-c", cmd},
+		Cmd:          cmd,
 		Tty:          true,
 		AttachStdout: true,
 		AttachStderr: true,
-		WorkingDir:   "/mattermost/e2e-tests/playwright",
+		WorkingDir:   "/mattermost/" + playwrightDir,
 	}
 
 	// Create host config with volume mount
@@ -174,3 +274,20 @@ func (r *PlaywrightRunner) RunTests() error {
 
 	return nil
 }
+
+// specArgs returns the arguments of playwright test, with the absolute paths
+// of the specs made relative to the Playwright directory, as they are
+// mounted elsewhere in the container
+func (r *PlaywrightRunner) specArgs(absBaseDir string) []string {
+	opts := r.options
+	opts.Specs = nil
+	for _, spec := range r.options.Specs {
+		if filepath.IsAbs(spec) {
+			if rel, err := filepath.Rel(filepath.Join(absBaseDir, playwrightDir), spec); err == nil && !strings.HasPrefix(rel, "..") {
+				spec = rel
+			}
+		}
+		opts.Specs = append(opts.Specs, spec)
+	}
+	return opts.Args()
+}
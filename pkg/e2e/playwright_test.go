@@ -0,0 +1,97 @@
+package e2e
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlaywrightOptions(t *testing.T) {
+	two, zero, negative := 2, 0, -1
+	for name, tc := range map[string]struct {
+		opts     PlaywrightOptions
+		expected []string
+		err      bool
+	}{
+		"defaults": {},
+		"all options": {
+			opts: PlaywrightOptions{
+				Specs:           []string{"specs/functional/channels", "login.spec.ts"},
+				Grep:            "@smoke",
+				Projects:        []string{"chrome", "firefox"},
+				Workers:         "50%",
+				Shard:           "2/3",
+				Retries:         &two,
+				UpdateSnapshots: true,
+				Headed:          true,
+			},
+			expected: []string{
+				"specs/functional/channels", "login.spec.ts",
+				"--grep", "@smoke",
+				"--project", "chrome", "--project", "firefox",
+				"--workers", "50%",
+				"--shard", "2/3",
+				"--retries", "2",
+				"--update-snapshots",
+				"--headed",
+			},
+		},
+		"specs from the repository root": {
+			opts:     PlaywrightOptions{Specs: []string{"./e2e-tests/playwright/specs/visual", "e2e-tests/playwright/specs/a.spec.ts"}},
+			expected: []string{"specs/visual", "specs/a.spec.ts"},
+		},
+		"no retries": {
+			opts:     PlaywrightOptions{Retries: &zero},
+			expected: []string{"--retries", "0"},
+		},
+		"negative retries": {
+			opts: PlaywrightOptions{Retries: &negative},
+			err:  true,
+		},
+		"invalid workers": {
+			opts: PlaywrightOptions{Workers: "many"},
+			err:  true,
+		},
+		"zero workers": {
+			opts: PlaywrightOptions{Workers: "0"},
+			err:  true,
+		},
+		"invalid shard": {
+			opts: PlaywrightOptions{Shard: "2"},
+			err:  true,
+		},
+		"shard out of range": {
+			opts: PlaywrightOptions{Shard: "4/3"},
+			err:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := tc.opts.Validate()
+			if tc.err {
+				if err == nil {
+					t.Log("expected an error")
+					t.Fail()
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			args := tc.opts.Args()
+			if !reflect.DeepEqual(args, tc.expected) {
+				t.Logf("expected %q, got %q", tc.expected, args)
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestPlaywrightSpecArgs(t *testing.T) {
+	r := &PlaywrightRunner{options: PlaywrightOptions{
+		Specs: []string{"/repo/e2e-tests/playwright/specs/a.spec.ts", "/elsewhere/b.spec.ts", "specs/c"},
+	}}
+	expected := []string{"specs/a.spec.ts", "/elsewhere/b.spec.ts", "specs/c"}
+	if args := r.specArgs("/repo"); !reflect.DeepEqual(args, expected) {
+		t.Logf("expected %q, got %q", expected, args)
+		t.Fail()
+	}
+}
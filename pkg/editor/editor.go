@@ -0,0 +1,146 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Location is a position in a source file
+type Location struct {
+	File string
+	Line int
+	// Col is zero when unknown
+	Col int
+}
+
+func (l Location) String() string {
+	if l.Col == 0 {
+		return fmt.Sprintf("%s:%d", l.File, l.Line)
+	}
+	return fmt.Sprintf("%s:%d:%d", l.File, l.Line, l.Col)
+}
+
+// Match is a location found in a text
+type Match struct {
+	Location
+	// Text is how the location appears in the text
+	Text string
+}
+
+// locationRegexp matches file:line[:col], the format of the Go compiler and
+// most tools, and the "file line:col" of webpack. Files need an extension.
+var locationRegexp = regexp.MustCompile(`((?:[A-Za-z]:)?[\w./@+-]*\w\.[A-Za-z]{1,5})(?::(\d+)(?::(\d+))?|\s+(\d+):(\d+)(?:-\d+)?)\b`)
+
+// Find returns the file locations in a text without colors. Locations
+// assigned to a key, like the caller of the server logs, are skipped as they
+// aren't errors.
+func Find(text string) []Match {
+	var matches []Match
+	for _, idx := range locationRegexp.FindAllStringSubmatchIndex(text, -1) {
+		if idx[0] > 0 && text[idx[0]-1] == '=' {
+			continue
+		}
+		group := func(n int) string {
+			if idx[2*n] < 0 {
+				return ""
+			}
+			return text[idx[2*n]:idx[2*n+1]]
+		}
+
+		line, col := group(2), group(3)
+		if line == "" {
+			line, col = group(4), group(5)
+		}
+		match := Match{Text: text[idx[0]:idx[1]]}
+		match.File = group(1)
+		match.Line, _ = strconv.Atoi(line)
+		match.Col, _ = strconv.Atoi(col)
+		if match.Line == 0 {
+			continue
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+// Resolve finds the file of a location, trying relative paths in each of
+// the directories in order. It returns false when the file doesn't exist.
+func Resolve(loc Location, dirs []string) (Location, bool) {
+	if filepath.IsAbs(loc.File) {
+		return loc, isFile(loc.File)
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, loc.File)
+		if isFile(path) {
+			loc.File = path
+			return loc, true
+		}
+	}
+	return loc, false
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// Command returns the command opening a location in an editor. The template
+// is a command line where {file}, {line} and {col} are replaced. When empty,
+// the editor is $VISUAL or $EDITOR, vi by default, with the arguments it
+// expects to go to a line.
+func Command(template string, loc Location) (*exec.Cmd, error) {
+	if loc.Col == 0 {
+		loc.Col = 1
+	}
+
+	args := strings.Fields(template)
+	if len(args) == 0 {
+		args = defaultCommand()
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no editor configured")
+	}
+
+	replacer := strings.NewReplacer(
+		"{file}", loc.File,
+		"{line}", strconv.Itoa(loc.Line),
+		"{col}", strconv.Itoa(loc.Col),
+	)
+	for i, arg := range args {
+		args[i] = replacer.Replace(arg)
+	}
+	return exec.Command(args[0], args[1:]...), nil
+}
+
+// defaultCommand returns the command template of the editor of the
+// environment
+func defaultCommand() []string {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := strings.Fields(editor)
+	if len(args) == 0 {
+		return nil
+	}
+
+	switch filepath.Base(args[0]) {
+	case "code", "code-insiders", "codium", "cursor", "windsurf":
+		return append(args, "--goto", "{file}:{line}:{col}")
+	case "subl", "zed", "hx", "helix":
+		return append(args, "{file}:{line}:{col}")
+	case "idea", "goland", "webstorm":
+		return append(args, "--line", "{line}", "--column", "{col}", "{file}")
+	default:
+		// vi, vim, nvim, nano, emacs, micro...
+		return append(args, "+{line}", "{file}")
+	}
+}
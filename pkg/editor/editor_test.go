@@ -0,0 +1,126 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	for name, tc := range map[string]struct {
+		text     string
+		expected []Location
+	}{
+		"go compile error": {
+			text:     "app/server.go:123:45: undefined: foo",
+			expected: []Location{{File: "app/server.go", Line: 123, Col: 45}},
+		},
+		"relative path without column": {
+			text:     "./channels/store.go:7 something",
+			expected: []Location{{File: "./channels/store.go", Line: 7}},
+		},
+		"webpack error": {
+			text:     "ERROR in ./src/components/post/post.tsx 12:5-18",
+			expected: []Location{{File: "./src/components/post/post.tsx", Line: 12, Col: 5}},
+		},
+		"typescript error": {
+			text:     "ERROR in src/actions/views.ts:88:3",
+			expected: []Location{{File: "src/actions/views.ts", Line: 88, Col: 3}},
+		},
+		"several": {
+			text: "a.go:1:2 b/c.tsx:3:4",
+			expected: []Location{
+				{File: "a.go", Line: 1, Col: 2},
+				{File: "b/c.tsx", Line: 3, Col: 4},
+			},
+		},
+		"log caller": {
+			text:     "12:00:00.000 INFO Server started caller=app/server.go:1234",
+			expected: nil,
+		},
+		"url": {
+			text:     "Listening on http://localhost:8065",
+			expected: nil,
+		},
+		"time": {
+			text:     "Started at 12:30:45",
+			expected: nil,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var locations []Location
+			for _, match := range Find(tc.text) {
+				locations = append(locations, match.Location)
+				if !strings.Contains(tc.text, match.Text) {
+					t.Logf("match text %q not in %q", match.Text, tc.text)
+					t.Fail()
+				}
+			}
+			if !reflect.DeepEqual(locations, tc.expected) {
+				t.Logf("expected %v, got %v", tc.expected, locations)
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "server", "app"), 0755)
+	os.WriteFile(filepath.Join(dir, "server", "app", "server.go"), nil, 0644)
+
+	dirs := []string{dir, filepath.Join(dir, "server")}
+	loc, ok := Resolve(Location{File: "app/server.go", Line: 3}, dirs)
+	if !ok || loc.File != filepath.Join(dir, "server", "app", "server.go") || loc.Line != 3 {
+		t.Logf("expected the file in the server directory, got %v (%v)", loc, ok)
+		t.Fail()
+	}
+	if _, ok := Resolve(Location{File: "app/missing.go", Line: 3}, dirs); ok {
+		t.Log("expected a missing file not to resolve")
+		t.Fail()
+	}
+	if _, ok := Resolve(Location{File: "app", Line: 3}, []string{filepath.Join(dir, "server")}); ok {
+		t.Log("expected a directory not to resolve")
+		t.Fail()
+	}
+}
+
+func TestCommand(t *testing.T) {
+	loc := Location{File: "app/server.go", Line: 12, Col: 5}
+	for name, tc := range map[string]struct {
+		template string
+		editor   string
+		expected []string
+	}{
+		"template": {
+			template: "myeditor -l {line} -c {col} {file}",
+			expected: []string{"myeditor", "-l", "12", "-c", "5", "app/server.go"},
+		},
+		"vim": {
+			editor:   "nvim",
+			expected: []string{"nvim", "+12", "app/server.go"},
+		},
+		"vscode with flags": {
+			editor:   "code --wait",
+			expected: []string{"code", "--wait", "--goto", "app/server.go:12:5"},
+		},
+		"default": {
+			expected: []string{"vi", "+12", "app/server.go"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("VISUAL", "")
+			t.Setenv("EDITOR", tc.editor)
+			cmd, err := Command(tc.template, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cmd.Args, tc.expected) {
+				t.Logf("expected %q, got %q", tc.expected, cmd.Args)
+				t.Fail()
+			}
+		})
+	}
+}
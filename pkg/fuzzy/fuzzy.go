@@ -0,0 +1,74 @@
+package fuzzy
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Score tells how well a pattern matches a candidate, false when the
+// characters of the pattern don't appear in order in the candidate. The
+// comparison ignores case. Consecutive characters and characters starting a
+// word score higher, and shorter candidates win ties.
+func Score(pattern, candidate string) (int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	c := []rune(strings.ToLower(candidate))
+	if len(p) == 0 {
+		return 0, true
+	}
+
+	score := 0
+	pi := 0
+	last := -1
+	for ci := 0; ci < len(c) && pi < len(p); ci++ {
+		if c[ci] != p[pi] {
+			continue
+		}
+		score++
+		switch {
+		case ci == 0:
+			score += 8
+		case isSeparator(c[ci-1]):
+			score += 6
+		}
+		if last >= 0 && last == ci-1 {
+			score += 4
+		} else if last >= 0 {
+			score -= min(ci-last-1, 3)
+		}
+		last = ci
+		pi++
+	}
+	if pi < len(p) {
+		return 0, false
+	}
+	return score*100 - len(c), true
+}
+
+func isSeparator(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("-_/.:", r)
+}
+
+// Filter returns the candidates matching the pattern, the best matches
+// first, keeping the given order between equal ones
+func Filter(pattern string, candidates []string) []string {
+	type scored struct {
+		candidate string
+		score     int
+	}
+	var matches []scored
+	for _, candidate := range candidates {
+		if score, ok := Score(pattern, candidate); ok {
+			matches = append(matches, scored{candidate, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	filtered := make([]string, len(matches))
+	for i, match := range matches {
+		filtered[i] = match.candidate
+	}
+	return filtered
+}
@@ -0,0 +1,60 @@
+package fuzzy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	candidates := []string{"restart", "docker-restart", "start", "stop", "search", "save", "level"}
+	for name, tc := range map[string]struct {
+		pattern  string
+		expected []string
+	}{
+		"empty keeps the order": {
+			pattern:  "",
+			expected: candidates,
+		},
+		"prefix first, shorter first": {
+			pattern:  "st",
+			expected: []string{"stop", "start", "restart", "docker-restart"},
+		},
+		"word start": {
+			pattern:  "dr",
+			expected: []string{"docker-restart"},
+		},
+		"ignores case": {
+			pattern:  "LEV",
+			expected: []string{"level"},
+		},
+		"subsequence": {
+			pattern:  "rst",
+			expected: []string{"restart", "docker-restart"},
+		},
+		"no match": {
+			pattern:  "xyz",
+			expected: []string{},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			filtered := Filter(tc.pattern, candidates)
+			if !reflect.DeepEqual(filtered, tc.expected) {
+				t.Logf("expected %q, got %q", tc.expected, filtered)
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestScore(t *testing.T) {
+	consecutive, _ := Score("rest", "restart")
+	scattered, _ := Score("rest", "reset-stats")
+	if consecutive <= scattered {
+		t.Logf("expected consecutive characters to score higher, got %d and %d", consecutive, scattered)
+		t.Fail()
+	}
+	if _, ok := Score("abc", "cba"); ok {
+		t.Log("expected characters out of order not to match")
+		t.Fail()
+	}
+}
@@ -0,0 +1,341 @@
+package generator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jespino/mmdev/pkg/tools"
+	"gopkg.in/yaml.v2"
+)
+
+// skipDirs are never scanned for generators
+var skipDirs = map[string]bool{
+	".git":                 true,
+	"node_modules":         true,
+	"vendor":               true,
+	"testdata":             true,
+	"bin":                  true,
+	"data":                 true,
+	"plugins":              true,
+	"prepackaged_plugins":  true,
+	"layer_generators":     true,
+	"interface_generator":  true,
+	"mattermost-webapp":    true,
+	"mattermost-plugin-ai": true,
+}
+
+// mockTools are the commands recognized in go:generate directives and
+// Makefile recipes as mock generators
+var mockTools = []tools.Tool{tools.Mockery, tools.Mockgen}
+
+// layerOutputs maps the packages written by the layer generators to their
+// target, so mocks of those packages run after them
+var layerOutputs = map[string]string{
+	"public/plugin": GroupLayers + "/plugin-api",
+	"channels/app":  GroupLayers + "/app",
+}
+
+var (
+	mockgenDestinationRegexp = regexp.MustCompile(`-destination[= ](\S+)`)
+	makefileVariableRegexp   = regexp.MustCompile(`\$\(([A-Za-z0-9_]+)\)`)
+	makefileAssignmentRegexp = regexp.MustCompile(`^([A-Za-z0-9_]+)\s*[:?]?=\s*(.*)$`)
+)
+
+// mockSource is a discovered mock generator before it gets a unique name
+type mockSource struct {
+	dir      string
+	source   string
+	triggers []string
+	run      func(m *Manager, out io.Writer) error
+}
+
+// discoverMocks scans the server directory for mockery configurations,
+// go:generate directives calling a mock generator and mockgen calls in the
+// Makefile
+func (m *Manager) discoverMocks() ([]Target, error) {
+	modules := m.modules()
+	var sources []mockSource
+
+	err := filepath.Walk(m.baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != m.baseDir && skipDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(m.baseDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case info.Name() == ".mockery.yaml" || info.Name() == ".mockery.yml":
+			sources = append(sources, mockeryConfigSource(path, rel, modules))
+		case strings.HasSuffix(info.Name(), ".go") && !strings.HasSuffix(info.Name(), "_test.go"):
+			found, err := goGenerateSources(path, rel)
+			if err != nil {
+				return err
+			}
+			sources = append(sources, found...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover mock generators: %w", err)
+	}
+
+	found, err := makefileSources(filepath.Join(m.baseDir, "Makefile"), modules)
+	if err != nil {
+		return nil, err
+	}
+	sources = append(sources, found...)
+
+	return mockTargets(sources), nil
+}
+
+// mockeryConfigSource creates a generator for a mockery configuration. The
+// sources are the directory of the configuration plus the directory and
+// packages it lists.
+func mockeryConfigSource(path, rel string, modules map[string]string) mockSource {
+	dir := filepath.ToSlash(filepath.Dir(rel))
+	triggers := []string{rel, dir + "/*.go"}
+
+	var config struct {
+		Dir      string                 `yaml:"dir"`
+		Packages map[string]interface{} `yaml:"packages"`
+	}
+	if data, err := os.ReadFile(path); err == nil && yaml.Unmarshal(data, &config) == nil {
+		if config.Dir != "" {
+			triggers = append(triggers, filepath.ToSlash(filepath.Clean(config.Dir))+"/*.go")
+		}
+		for importPath := range config.Packages {
+			if pkgDir, ok := importPathDir(modules, importPath); ok {
+				triggers = append(triggers, pkgDir+"/*.go")
+			}
+		}
+	}
+
+	return mockSource{
+		dir:      dir,
+		source:   rel,
+		triggers: triggers,
+		run: func(m *Manager, out io.Writer) error {
+			return m.generateMockery(out, dir, rel)
+		},
+	}
+}
+
+// goGenerateSources finds the go:generate directives of a file that call a
+// mock generator
+func goGenerateSources(path, rel string) ([]mockSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir := filepath.ToSlash(filepath.Dir(rel))
+	var sources []mockSource
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if !strings.HasPrefix(line, "//go:generate ") || !callsMockTool(line) {
+			continue
+		}
+
+		directive := line
+		sources = append(sources, mockSource{
+			dir:      dir,
+			source:   fmt.Sprintf("%s:%d", rel, lineNumber),
+			triggers: []string{dir + "/*.go"},
+			run: func(m *Manager, out io.Writer) error {
+				return m.generateDirective(out, dir, directive)
+			},
+		})
+	}
+	return sources, scanner.Err()
+}
+
+// makefileSources finds the mockgen calls in the Makefile recipes
+func makefileSources(path string, modules map[string]string) ([]mockSource, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read Makefile: %w", err)
+	}
+
+	vars := makefileVariables(string(data), modules)
+
+	var sources []mockSource
+	for i, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "\t") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimLeft(strings.TrimSpace(line), "@-"))
+		if len(fields) == 0 || (fields[0] != "mockgen" && !strings.HasSuffix(fields[0], "/mockgen")) {
+			continue
+		}
+		// The pinned mockgen is put first in the PATH when running
+		fields[0] = "mockgen"
+		command := makefileVariableRegexp.ReplaceAllStringFunc(strings.Join(fields, " "), func(ref string) string {
+			if value, ok := vars[makefileVariableRegexp.FindStringSubmatch(ref)[1]]; ok {
+				return value
+			}
+			return ref
+		})
+		// Skip calls depending on variables we can't expand
+		if strings.Contains(command, "$(") {
+			continue
+		}
+
+		match := mockgenDestinationRegexp.FindStringSubmatch(command)
+		if match == nil {
+			continue
+		}
+		dir := filepath.ToSlash(filepath.Dir(match[1]))
+		if filepath.Base(dir) == "mocks" {
+			dir = filepath.ToSlash(filepath.Dir(dir))
+		}
+
+		// The source package is the first argument that is an import path
+		var triggers []string
+		for _, arg := range strings.Fields(command)[1:] {
+			if pkgDir, ok := importPathDir(modules, arg); ok {
+				triggers = append(triggers, pkgDir+"/*.go")
+				break
+			}
+		}
+
+		sources = append(sources, mockSource{
+			dir:      dir,
+			source:   fmt.Sprintf("Makefile:%d", i+1),
+			triggers: triggers,
+			run: func(m *Manager, out io.Writer) error {
+				return m.generateShell(out, dir, command)
+			},
+		})
+	}
+	return sources, nil
+}
+
+// makefileVariables collects the Makefile variables with a literal value.
+// GO_MODULE is always the server module path.
+func makefileVariables(makefile string, modules map[string]string) map[string]string {
+	vars := make(map[string]string)
+	for _, line := range strings.Split(makefile, "\n") {
+		match := makefileAssignmentRegexp.FindStringSubmatch(line)
+		if match == nil || strings.Contains(match[2], "$") {
+			continue
+		}
+		vars[match[1]] = strings.TrimSpace(match[2])
+	}
+	for module, dir := range modules {
+		if dir == "." {
+			vars["GO_MODULE"] = module
+		}
+	}
+	return vars
+}
+
+// mockTargets names the discovered generators after their directory,
+// falling back to the whole path when the directory name is ambiguous
+func mockTargets(sources []mockSource) []Target {
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].source < sources[j].source
+	})
+
+	count := make(map[string]int)
+	for _, source := range sources {
+		count[filepath.Base(source.dir)]++
+	}
+
+	used := make(map[string]int)
+	var targets []Target
+	for _, source := range sources {
+		name := filepath.Base(source.dir)
+		if count[name] > 1 {
+			name = strings.ReplaceAll(source.dir, "/", "-")
+		}
+		used[name]++
+		if used[name] > 1 {
+			name = fmt.Sprintf("%s-%d", name, used[name])
+		}
+
+		target := Target{
+			Group:    GroupMocks,
+			Name:     name,
+			Source:   source.source,
+			Triggers: source.triggers,
+			run:      source.run,
+		}
+		for _, trigger := range source.triggers {
+			if dep, ok := layerOutputs[filepath.ToSlash(filepath.Dir(trigger))]; ok {
+				target.DependsOn = append(target.DependsOn, dep)
+				break
+			}
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+func callsMockTool(directive string) bool {
+	for _, field := range strings.Fields(directive)[1:] {
+		for _, tool := range mockTools {
+			if field == tool.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// modules maps the module paths of the server to their directories
+func (m *Manager) modules() map[string]string {
+	modules := make(map[string]string)
+	for _, dir := range []string{".", "public"} {
+		data, err := os.ReadFile(filepath.Join(m.baseDir, dir, "go.mod"))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == "module" {
+				modules[fields[1]] = dir
+				break
+			}
+		}
+	}
+	return modules
+}
+
+// importPathDir converts an import path of one of the server modules to a
+// directory relative to the server directory
+func importPathDir(modules map[string]string, importPath string) (string, bool) {
+	best := ""
+	for module := range modules {
+		if (importPath == module || strings.HasPrefix(importPath, module+"/")) && len(module) > len(best) {
+			best = module
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	return filepath.ToSlash(filepath.Join(modules[best], strings.TrimPrefix(importPath, best))), true
+}
@@ -2,55 +2,97 @@ package generator
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jespino/mmdev/pkg/tools"
 )
 
 // Manager handles code generation operations
 type Manager struct {
 	baseDir string
+	tools   *tools.Manager
+	env     []string
+	output  io.Writer
 }
 
 // NewManager creates a new generator manager
 func NewManager(baseDir string) *Manager {
 	return &Manager{
 		baseDir: baseDir,
+		tools:   tools.NewManager(baseDir),
+		output:  os.Stdout,
 	}
 }
 
+// SetOutput sets where the generators output goes, stdout by default
+func (m *Manager) SetOutput(w io.Writer) {
+	m.output = w
+}
+
 // GenerateAppLayers generates the app layer interfaces
 func (m *Manager) GenerateAppLayers() error {
-	// Install struct2interface
-	installCmd := exec.Command("go", "install", "github.com/reflog/struct2interface@v0.6.1")
-	installCmd.Env = os.Environ()
-	if err := installCmd.Run(); err != nil {
-		return fmt.Errorf("failed to install struct2interface: %w", err)
+	return m.generateAppLayers(m.output)
+}
+
+// GenerateStoreLayers generates the store layer code
+func (m *Manager) GenerateStoreLayers() error {
+	if err := m.generateMockery(m.output, "store", "channels/store/.mockery.yaml"); err != nil {
+		return err
+	}
+	return m.generateStoreLayers(m.output)
+}
+
+// GeneratePluginAPI generates plugin API and hooks code
+func (m *Manager) GeneratePluginAPI() error {
+	return m.generatePluginAPI(m.output)
+}
+
+// GenerateMocks generates all mock files
+func (m *Manager) GenerateMocks() error {
+	targets, err := m.Targets()
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		if target.Group != GroupMocks {
+			continue
+		}
+		if err := target.run(m, m.output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) generateAppLayers(out io.Writer) error {
+	struct2interface, err := m.tools.Path(tools.Struct2Interface)
+	if err != nil {
+		return err
 	}
 
 	// Generate app interface
-	cmd := exec.Command("struct2interface",
+	cmd := m.command(out, struct2interface,
 		"-f", "channels/app",
 		"-o", "channels/app/app_iface.go",
 		"-p", "app",
 		"-s", "App",
 		"-i", "AppIface",
 		"-t", "./channels/app/layer_generators/app_iface.go.tmpl")
-	cmd.Dir = m.baseDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to generate app interface: %w", err)
 	}
 
 	// Generate opentracing layer
-	cmd = exec.Command("go", "run",
+	cmd = m.command(out, "go", "run",
 		"./channels/app/layer_generators",
 		"-in", "./channels/app/app_iface.go",
 		"-out", "./channels/app/opentracing/opentracing_layer.go",
 		"-template", "./channels/app/layer_generators/opentracing_layer.go.tmpl")
-	cmd.Dir = m.baseDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to generate opentracing layer: %w", err)
 	}
@@ -58,103 +100,88 @@ func (m *Manager) GenerateAppLayers() error {
 	return nil
 }
 
-// GenerateStoreLayers generates the store layer code
-func (m *Manager) GenerateStoreLayers() error {
-	// Install mockery
-	installCmd := exec.Command("go", "install", "github.com/vektra/mockery/v2/...@v2.42.2")
-	installCmd.Env = os.Environ()
-	if err := installCmd.Run(); err != nil {
-		return fmt.Errorf("failed to install mockery: %w", err)
+func (m *Manager) generateStoreLayers(out io.Writer) error {
+	cmd := m.command(out, "go", "generate", "./channels/store")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to generate store layers: %w", err)
 	}
+	return nil
+}
 
-	// Generate store mocks
-	cmd := exec.Command("mockery", "--config", "channels/store/.mockery.yaml")
-	cmd.Dir = m.baseDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+func (m *Manager) generatePluginAPI(out io.Writer) error {
+	cmd := m.command(out, "go", "generate", "./public/plugin")
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to generate store mocks: %w", err)
+		return fmt.Errorf("failed to generate plugin API: %w", err)
 	}
+	return nil
+}
 
-	// Generate store layers
-	cmd = exec.Command("go", "generate", "./channels/store")
-	cmd.Dir = m.baseDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to generate store layers: %w", err)
+func (m *Manager) generateMockery(out io.Writer, name, config string) error {
+	mockery, err := m.tools.Path(tools.Mockery)
+	if err != nil {
+		return err
 	}
 
+	cmd := m.command(out, mockery, "--config", config)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to generate %s mocks: %w", name, err)
+	}
 	return nil
 }
 
-// GeneratePluginAPI generates plugin API and hooks code
-func (m *Manager) GeneratePluginAPI() error {
-	cmd := exec.Command("go", "generate", "./public/plugin")
-	cmd.Dir = m.baseDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// generateDirective runs a single go:generate directive of the package in
+// dir. go generate -run matches the whole line, //go:generate included.
+func (m *Manager) generateDirective(out io.Writer, dir, directive string) error {
+	env, err := m.toolsEnv()
+	if err != nil {
+		return err
+	}
+
+	cmd := m.command(out, "go", "generate", "-run", "^"+regexp.QuoteMeta(directive)+"$", "./"+dir)
+	cmd.Env = append(cmd.Env, env...)
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to generate plugin API: %w", err)
+		return fmt.Errorf("failed to generate %s mocks: %w", dir, err)
 	}
 	return nil
 }
 
-// GenerateMocks generates all mock files
-func (m *Manager) GenerateMocks() error {
-	// Install mockery
-	installCmd := exec.Command("go", "install", "github.com/vektra/mockery/v2/...@v2.42.2")
-	installCmd.Env = os.Environ()
-	if err := installCmd.Run(); err != nil {
-		return fmt.Errorf("failed to install mockery: %w", err)
-	}
-
-	configs := []struct {
-		name string
-		path string
-	}{
-		{"store", "channels/store/.mockery.yaml"},
-		{"cache", "platform/services/cache/.mockery.yaml"},
-		{"telemetry", "platform/services/telemetry/.mockery.yaml"},
-		{"filestore", "platform/shared/filestore/.mockery.yaml"},
-		{"plugin", "public/plugin/.mockery.yaml"},
-		{"einterfaces", "einterfaces/.mockery.yaml"},
-		{"searchengine", "platform/services/searchengine/.mockery.yaml"},
-		{"sharedchannel", "platform/services/sharedchannel/.mockery.yaml"},
-		{"misc", "channels/utils/.mockery.yaml"},
-		{"email", "channels/app/email/.mockery.yaml"},
-		{"platform", "channels/app/platform/.mockery.yaml"},
-	}
-
-	for _, config := range configs {
-		cmd := exec.Command("mockery", "--config", config.path)
-		cmd.Dir = m.baseDir
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to generate %s mocks: %w", config.name, err)
-		}
+// generateShell runs a generator command taken from the Makefile
+func (m *Manager) generateShell(out io.Writer, dir, command string) error {
+	env, err := m.toolsEnv()
+	if err != nil {
+		return err
 	}
 
-	// Generate MMCTL mocks
-	installCmd = exec.Command("go", "install", "github.com/golang/mock/mockgen@v1.6.0")
-	installCmd.Env = os.Environ()
-	if err := installCmd.Run(); err != nil {
-		return fmt.Errorf("failed to install mockgen: %w", err)
+	cmd := m.command(out, "sh", "-c", command)
+	cmd.Env = append(cmd.Env, env...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to generate %s mocks: %w", dir, err)
 	}
+	return nil
+}
 
-	cmd := exec.Command("mockgen",
-		"-destination=cmd/mmctl/mocks/client_mock.go",
-		"-copyright_file=cmd/mmctl/mocks/copyright.txt",
-		"-package=mocks",
-		"github.com/mattermost/mattermost/server/v8/cmd/mmctl/client",
-		"Client")
-	cmd.Dir = m.baseDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to generate mmctl mocks: %w", err)
+// toolsEnv returns the environment putting the pinned mock generators first
+// in the PATH
+func (m *Manager) toolsEnv() ([]string, error) {
+	var dirs []string
+	for _, tool := range mockTools {
+		path, err := m.tools.Path(tool)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, filepath.Dir(path))
 	}
+	dirs = append(dirs, os.Getenv("PATH"))
+	return []string{"PATH=" + strings.Join(dirs, string(os.PathListSeparator))}, nil
+}
 
-	return nil
+// command creates a command running in the server directory with its output
+// going to out
+func (m *Manager) command(out io.Writer, name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = m.baseDir
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.Env = append(os.Environ(), m.env...)
+	return cmd
 }
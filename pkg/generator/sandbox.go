@@ -0,0 +1,220 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Change kinds
+const (
+	ChangeAdded    = "added"
+	ChangeModified = "modified"
+	ChangeDeleted  = "deleted"
+)
+
+// Change is a file that differs between a sandbox and the working tree
+type Change struct {
+	Path string
+	Kind string
+}
+
+// copiedTime is the modification time set on every copied file, so the files
+// written by the generators can be found by their modification time
+var copiedTime = time.Unix(1, 0)
+
+// sandbox is a temporary copy of the server directory, with the tracked and
+// untracked but not ignored files, where generators can run without touching
+// the working tree
+type sandbox struct {
+	dir       string
+	sourceDir string
+	files     map[string]bool
+}
+
+func newSandbox(sourceDir string, out io.Writer) (*sandbox, error) {
+	files, err := listFiles(sourceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "mmdev-generate-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	sb := &sandbox{
+		dir:       dir,
+		sourceDir: sourceDir,
+		files:     make(map[string]bool),
+	}
+
+	fmt.Fprintln(out, "Copying server sources to a temporary directory...")
+	for _, file := range files {
+		dst := filepath.Join(dir, file)
+		copied, err := copyFile(filepath.Join(sourceDir, file), dst)
+		if err != nil {
+			sb.Close()
+			return nil, err
+		}
+		if !copied {
+			continue
+		}
+		if err := os.Chtimes(dst, copiedTime, copiedTime); err != nil {
+			sb.Close()
+			return nil, fmt.Errorf("failed to set times on %s: %w", dst, err)
+		}
+		sb.files[file] = true
+	}
+
+	return sb, nil
+}
+
+// copyFile copies a regular file, reporting false for anything else, like
+// deleted files still in the index or symlinks
+func copyFile(src, dst string) (bool, error) {
+	info, err := os.Lstat(src)
+	if err != nil || !info.Mode().IsRegular() {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return false, fmt.Errorf("failed to create directory for %s: %w", dst, err)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return false, fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	if err := out.Close(); err != nil {
+		return false, fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	return true, nil
+}
+
+// listFiles lists the tracked and untracked but not ignored files of dir
+func listFiles(dir string) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list server files: %w", err)
+	}
+
+	var files []string
+	for _, file := range strings.Split(string(output), "\n") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// Changes returns the files written or removed in the sandbox whose content
+// differs from the source directory
+func (s *sandbox) Changes() ([]Change, error) {
+	var changes []Change
+	seen := make(map[string]bool)
+
+	err := filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		seen[rel] = true
+
+		if !info.ModTime().After(copiedTime) {
+			return nil
+		}
+
+		generated, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		current, err := os.ReadFile(filepath.Join(s.sourceDir, rel))
+		if os.IsNotExist(err) {
+			changes = append(changes, Change{Path: rel, Kind: ChangeAdded})
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !bytes.Equal(generated, current) {
+			changes = append(changes, Change{Path: rel, Kind: ChangeModified})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare generated files: %w", err)
+	}
+
+	for file := range s.files {
+		if !seen[file] {
+			changes = append(changes, Change{Path: file, Kind: ChangeDeleted})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// WriteDiff writes the unified diff of a change to out
+func (s *sandbox) WriteDiff(out io.Writer, change Change) error {
+	if _, err := exec.LookPath("diff"); err != nil {
+		return fmt.Errorf("diff is required to show the generated changes: %w", err)
+	}
+
+	current := filepath.Join(s.sourceDir, change.Path)
+	generated := filepath.Join(s.dir, change.Path)
+	switch change.Kind {
+	case ChangeAdded:
+		current = os.DevNull
+	case ChangeDeleted:
+		generated = os.DevNull
+	}
+
+	cmd := exec.Command("diff", "-u",
+		"--label", "a/"+filepath.ToSlash(change.Path),
+		"--label", "b/"+filepath.ToSlash(change.Path),
+		current, generated)
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	// diff exits with 1 when the files differ
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to diff %s: %w", change.Path, err)
+	}
+	return nil
+}
+
+// Close removes the sandbox
+func (s *sandbox) Close() error {
+	return os.RemoveAll(s.dir)
+}
@@ -0,0 +1,88 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// snapshot is a backup of the generated files of the server directory, so
+// they can be restored if a generator fails midway
+type snapshot struct {
+	dir       string
+	sourceDir string
+	files     map[string]bool
+}
+
+func newSnapshot(sourceDir string) (*snapshot, error) {
+	files, err := listFiles(sourceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "mmdev-generate-backup-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	snap := &snapshot{
+		dir:       dir,
+		sourceDir: sourceDir,
+		files:     make(map[string]bool),
+	}
+	for _, file := range files {
+		if !isGenerated(filepath.ToSlash(file)) {
+			continue
+		}
+		copied, err := copyFile(filepath.Join(sourceDir, file), filepath.Join(dir, file))
+		if err != nil {
+			snap.Close()
+			return nil, err
+		}
+		if copied {
+			snap.files[file] = true
+		}
+	}
+
+	return snap, nil
+}
+
+// Restore puts back the backed up generated files that changed and removes
+// the generated files created since the snapshot was taken
+func (s *snapshot) Restore() error {
+	for file := range s.files {
+		backup := filepath.Join(s.dir, file)
+		current := filepath.Join(s.sourceDir, file)
+
+		want, err := os.ReadFile(backup)
+		if err != nil {
+			return fmt.Errorf("failed to read backup of %s: %w", file, err)
+		}
+		if got, err := os.ReadFile(current); err == nil && bytes.Equal(got, want) {
+			continue
+		}
+		if _, err := copyFile(backup, current); err != nil {
+			return err
+		}
+	}
+
+	files, err := listFiles(s.sourceDir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if s.files[file] || !isGenerated(filepath.ToSlash(file)) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.sourceDir, file)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// Close removes the backup
+func (s *snapshot) Close() error {
+	return os.RemoveAll(s.dir)
+}
@@ -0,0 +1,381 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jespino/mmdev/pkg/watcher"
+)
+
+// Target groups
+const (
+	GroupLayers = "layers"
+	GroupMocks  = "mocks"
+)
+
+// generatedPatterns match files written by the generators. Changes to them
+// never make a target stale.
+var generatedPatterns = []string{
+	"channels/app/app_iface.go",
+	"channels/app/opentracing/**",
+	"channels/store/timerlayer/**",
+	"channels/store/retrylayer/**",
+	"channels/store/opentracinglayer/**",
+	"**/mocks/**",
+	"**/*_generated.go",
+}
+
+// Target is a single code generator
+type Target struct {
+	Group string
+	Name  string
+	// Source is where the generator was defined, relative to the server
+	// directory
+	Source string
+	// Triggers are glob patterns, relative to the server directory, of the
+	// source files the generated code depends on
+	Triggers []string
+	// DependsOn lists the IDs of targets that must run first when selected
+	// together
+	DependsOn []string
+	run       func(m *Manager, out io.Writer) error
+}
+
+// ID returns the group qualified name of the target
+func (t Target) ID() string {
+	return t.Group + "/" + t.Name
+}
+
+// Targets returns all the generators, in the order they run sequentially.
+// Mock generators are discovered from the mockery configurations,
+// go:generate directives and Makefile recipes of the server.
+func (m *Manager) Targets() ([]Target, error) {
+	targets := []Target{
+		{
+			Group:    GroupLayers,
+			Name:     "app",
+			Source:   "channels/app/layer_generators",
+			Triggers: []string{"channels/app/*.go", "channels/app/layer_generators/**"},
+			run:      (*Manager).generateAppLayers,
+		},
+		{
+			Group:    GroupLayers,
+			Name:     "store",
+			Source:   "channels/store/layer_generators",
+			Triggers: []string{"channels/store/*.go", "channels/store/layer_generators/**"},
+			run:      (*Manager).generateStoreLayers,
+		},
+		{
+			Group:    GroupLayers,
+			Name:     "plugin-api",
+			Source:   "public/plugin/interface_generator",
+			Triggers: []string{"public/plugin/api.go", "public/plugin/hooks.go", "public/plugin/interface_generator/**"},
+			run:      (*Manager).generatePluginAPI,
+		},
+	}
+
+	mocks, err := m.discoverMocks()
+	if err != nil {
+		return nil, err
+	}
+	return append(targets, mocks...), nil
+}
+
+// Select returns the targets of a group with the given names, or the whole
+// group when no names are given
+func Select(targets []Target, group string, names []string) ([]Target, error) {
+	var inGroup []Target
+	for _, target := range targets {
+		if target.Group == group {
+			inGroup = append(inGroup, target)
+		}
+	}
+	if len(names) == 0 {
+		return inGroup, nil
+	}
+
+	var selected []Target
+	for _, name := range names {
+		found := false
+		for _, target := range inGroup {
+			if target.Name == name {
+				selected = append(selected, target)
+				found = true
+				break
+			}
+		}
+		if !found {
+			available := make([]string, 0, len(inGroup))
+			for _, target := range inGroup {
+				available = append(available, target.Name)
+			}
+			return nil, fmt.Errorf("unknown %s generator %q, available: %s", group, name, strings.Join(available, ", "))
+		}
+	}
+	return selected, nil
+}
+
+// Affected returns the targets whose sources are among the changed files,
+// given relative to the server directory
+func (m *Manager) Affected(targets []Target, changed []string) []Target {
+	var affected []Target
+	for _, target := range targets {
+		if target.isAffected(changed) {
+			affected = append(affected, target)
+		}
+	}
+	return affected
+}
+
+func (t Target) isAffected(changed []string) bool {
+	for _, file := range changed {
+		file = filepath.ToSlash(file)
+		if isGenerated(file) {
+			continue
+		}
+		for _, pattern := range t.Triggers {
+			if watcher.MatchGlob(pattern, file) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isGenerated(file string) bool {
+	for _, pattern := range generatedPatterns {
+		if watcher.MatchGlob(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// packages returns the directories of the sources of the target, sorted.
+// Generators reading the same package run one at a time.
+func (t Target) packages() []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, trigger := range t.Triggers {
+		dir := path.Dir(trigger)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// Run runs the given targets, up to parallel at a time, honoring the
+// dependencies between them. Targets sharing a source package never run at
+// the same time, but a generator writing into a package read by another one
+// is only ordered through DependsOn, so use a parallelism of 1 to run them
+// strictly one after the other. Output is prefixed with the target ID when
+// running more than one target.
+func (m *Manager) Run(targets []Target, parallel int) error {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	selected := make(map[string]bool)
+	for _, target := range targets {
+		selected[target.ID()] = true
+	}
+
+	var (
+		outMu    sync.Mutex
+		errMu    sync.Mutex
+		errs     []string
+		failed   = make(map[string]bool)
+		done     = make(map[string]chan struct{})
+		locks    = make(map[string]*sync.Mutex)
+		sem      = make(chan struct{}, parallel)
+		wg       sync.WaitGroup
+		prefixed = len(targets) > 1
+	)
+	for _, target := range targets {
+		done[target.ID()] = make(chan struct{})
+		for _, dir := range target.packages() {
+			if locks[dir] == nil {
+				locks[dir] = &sync.Mutex{}
+			}
+		}
+	}
+
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target Target) {
+			defer wg.Done()
+			defer close(done[target.ID()])
+
+			for _, dep := range target.DependsOn {
+				if !selected[dep] {
+					continue
+				}
+				<-done[dep]
+				errMu.Lock()
+				depFailed := failed[dep]
+				errMu.Unlock()
+				if depFailed {
+					errMu.Lock()
+					failed[target.ID()] = true
+					errs = append(errs, fmt.Sprintf("%s: skipped because %s failed", target.ID(), dep))
+					errMu.Unlock()
+					return
+				}
+			}
+
+			// Packages are locked in order, so targets can't wait on each other
+			for _, dir := range target.packages() {
+				locks[dir].Lock()
+				defer locks[dir].Unlock()
+			}
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			out := m.output
+			if prefixed {
+				pw := &prefixWriter{prefix: "[" + target.ID() + "] ", out: m.output, mu: &outMu}
+				defer pw.Flush()
+				out = pw
+			}
+
+			fmt.Fprintf(out, "Generating %s...\n", target.ID())
+			if err := target.run(m, out); err != nil {
+				errMu.Lock()
+				failed[target.ID()] = true
+				errs = append(errs, fmt.Sprintf("%s: %v", target.ID(), err))
+				errMu.Unlock()
+			}
+		}(target)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("code generation failed:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// Check runs the given targets in a temporary copy of the server directory
+// and returns the generated files that differ from the working tree
+func (m *Manager) Check(targets []Target, parallel int) ([]Change, error) {
+	sb, changes, err := m.runInSandbox(targets, parallel)
+	if err != nil {
+		return nil, err
+	}
+	defer sb.Close()
+	return changes, nil
+}
+
+// DryRun runs the given targets in a temporary copy of the server directory
+// and writes a unified diff of the generated files that differ from the
+// working tree to out
+func (m *Manager) DryRun(targets []Target, parallel int, out io.Writer) ([]Change, error) {
+	sb, changes, err := m.runInSandbox(targets, parallel)
+	if err != nil {
+		return nil, err
+	}
+	defer sb.Close()
+
+	for _, change := range changes {
+		if err := sb.WriteDiff(out, change); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// RunWithRollback runs the given targets like Run, restoring the generated
+// files of the working tree if any of them fails
+func (m *Manager) RunWithRollback(targets []Target, parallel int) error {
+	snap, err := newSnapshot(m.baseDir)
+	if err != nil {
+		return err
+	}
+	defer snap.Close()
+
+	runErr := m.Run(targets, parallel)
+	if runErr == nil {
+		return nil
+	}
+
+	fmt.Fprintln(m.output, "Code generation failed, restoring the generated files...")
+	if err := snap.Restore(); err != nil {
+		return fmt.Errorf("%w\nfailed to restore the generated files: %v", runErr, err)
+	}
+	return fmt.Errorf("%w\ngenerated files were restored to their previous state", runErr)
+}
+
+// runInSandbox runs the targets in a new sandbox and returns it, along with
+// the changes, for the caller to close
+func (m *Manager) runInSandbox(targets []Target, parallel int) (*sandbox, []Change, error) {
+	sb, err := newSandbox(m.baseDir, m.output)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sandboxed := &Manager{
+		baseDir: sb.dir,
+		tools:   m.tools,
+		env:     append(m.env, "GOWORK=off"),
+		output:  m.output,
+	}
+	if err := sandboxed.Run(targets, parallel); err != nil {
+		sb.Close()
+		return nil, nil, err
+	}
+
+	changes, err := sb.Changes()
+	if err != nil {
+		sb.Close()
+		return nil, nil, err
+	}
+	return sb, changes, nil
+}
+
+// prefixWriter prefixes every line written with a fixed string, so the
+// output of generators running in parallel can be told apart
+type prefixWriter struct {
+	prefix string
+	out    io.Writer
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		w.mu.Lock()
+		_, err := fmt.Fprintf(w.out, "%s%s\n", w.prefix, w.buf[:idx])
+		w.mu.Unlock()
+		w.buf = w.buf[idx+1:]
+		if err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes any pending partial line
+func (w *prefixWriter) Flush() {
+	if len(w.buf) == 0 {
+		return
+	}
+	w.mu.Lock()
+	fmt.Fprintf(w.out, "%s%s\n", w.prefix, w.buf)
+	w.mu.Unlock()
+	w.buf = nil
+}
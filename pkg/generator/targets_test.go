@@ -0,0 +1,239 @@
+package generator
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jespino/mmdev/pkg/tools"
+)
+
+// testServer creates a minimal server tree with the different kinds of mock
+// generators
+func testServer(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":        "module github.com/mattermost/mattermost/server/v8\n",
+		"public/go.mod": "module github.com/mattermost/mattermost/server/public\n",
+		"Makefile": "GO ?= go\n\nmmctl-mocks:\n" +
+			"\t$(GO) install github.com/golang/mock/mockgen@v1.6.0\n" +
+			"\t$(GOBIN)/mockgen -destination=cmd/mmctl/mocks/client_mock.go -package=mocks $(GO_MODULE)/cmd/mmctl/client Client\n",
+		"channels/store/.mockery.yaml": "dir: channels/store/storetest/mocks\npackages:\n  github.com/mattermost/mattermost/server/v8/channels/store:\n",
+		"channels/store/store.go":      "package store\n",
+		"public/plugin/.mockery.yaml":  "packages:\n  github.com/mattermost/mattermost/server/public/plugin:\n",
+		"public/plugin/api.go":         "package plugin\n",
+		"platform/services/remotecluster/service.go": "package remotecluster\n\n" +
+			"//go:generate mockery --name RemoteClusterServiceIFace\n",
+		"channels/app/layer_generators/.mockery.yaml": "ignored: true\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestTargets(t *testing.T) {
+	targets, err := NewManager(testServer(t)).Targets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"layers/app":          "channels/app/layer_generators",
+		"layers/store":        "channels/store/layer_generators",
+		"layers/plugin-api":   "public/plugin/interface_generator",
+		"mocks/mmctl":         "Makefile:5",
+		"mocks/store":         "channels/store/.mockery.yaml",
+		"mocks/remotecluster": "platform/services/remotecluster/service.go:3",
+		"mocks/plugin":        "public/plugin/.mockery.yaml",
+	}
+	if len(targets) != len(expected) {
+		t.Logf("expected %d targets, got %d", len(expected), len(targets))
+		t.Fail()
+	}
+	for _, target := range targets {
+		if expected[target.ID()] != target.Source {
+			t.Logf("expected %s source: %q, got %q", target.ID(), expected[target.ID()], target.Source)
+			t.Fail()
+		}
+	}
+}
+
+func TestAffected(t *testing.T) {
+	m := NewManager(testServer(t))
+	targets, err := m.Targets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		changed  []string
+		expected []string
+	}{
+		"no changes": {
+			changed:  nil,
+			expected: nil,
+		},
+		"store interface": {
+			changed:  []string{"channels/store/store.go"},
+			expected: []string{"layers/store", "mocks/store"},
+		},
+		"store implementation": {
+			changed:  []string{"channels/store/sqlstore/post_store.go"},
+			expected: nil,
+		},
+		"app method": {
+			changed:  []string{"channels/app/post.go"},
+			expected: []string{"layers/app"},
+		},
+		"generated files only": {
+			changed:  []string{"channels/app/app_iface.go", "channels/store/storetest/mocks/Store.go"},
+			expected: nil,
+		},
+		"plugin api": {
+			changed:  []string{"public/plugin/api.go"},
+			expected: []string{"layers/plugin-api", "mocks/plugin"},
+		},
+		"go:generate package": {
+			changed:  []string{"platform/services/remotecluster/service.go"},
+			expected: []string{"mocks/remotecluster"},
+		},
+		"mmctl client": {
+			changed:  []string{"cmd/mmctl/client/client.go"},
+			expected: []string{"mocks/mmctl"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, target := range m.Affected(targets, tc.changed) {
+				got = append(got, target.ID())
+			}
+
+			if len(got) != len(tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, got)
+			}
+			for i := range got {
+				if got[i] != tc.expected[i] {
+					t.Logf("expected [%d]: %v, got %v", i, tc.expected[i], got[i])
+					t.Fail()
+				}
+			}
+		})
+	}
+}
+
+func TestDirectiveTarget(t *testing.T) {
+	dir := testServer(t)
+	m := NewManager(dir)
+	m.SetOutput(io.Discard)
+
+	// Fake mock generators where the tools are installed, the mockery one
+	// writing the mock in the package directory
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	toolsDir, err := tools.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for tool, script := range map[tools.Tool]string{
+		tools.Mockery: "#!/bin/sh\necho \"package remotecluster // $*\" > mock_service.go\n",
+		tools.Mockgen: "#!/bin/sh\n",
+	} {
+		binDir := filepath.Join(toolsDir, tool.Name+"@"+m.tools.Version(tool))
+		if err := os.MkdirAll(binDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(binDir, tool.Name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	targets, err := m.Targets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var target *Target
+	for i := range targets {
+		if targets[i].ID() == "mocks/remotecluster" {
+			target = &targets[i]
+		}
+	}
+	if target == nil {
+		t.Fatal("mocks/remotecluster target not found")
+	}
+	if err := m.Run([]Target{*target}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "platform/services/remotecluster/mock_service.go"))
+	if err != nil {
+		t.Fatalf("expected the directive to write the mock: %v", err)
+	}
+	expected := "package remotecluster // --name RemoteClusterServiceIFace\n"
+	if string(data) != expected {
+		t.Logf("expected %q, got %q", expected, data)
+		t.Fail()
+	}
+}
+
+func TestRunSerializesSharedPackages(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		running = make(map[string]bool)
+		overlap []string
+	)
+	target := func(name string, triggers ...string) Target {
+		return Target{
+			Group:    GroupMocks,
+			Name:     name,
+			Triggers: triggers,
+			run: func(m *Manager, out io.Writer) error {
+				mu.Lock()
+				for other := range running {
+					overlap = append(overlap, other+"+"+name)
+				}
+				running[name] = true
+				mu.Unlock()
+
+				time.Sleep(20 * time.Millisecond)
+
+				mu.Lock()
+				delete(running, name)
+				mu.Unlock()
+				return nil
+			},
+		}
+	}
+
+	m := NewManager(t.TempDir())
+	m.SetOutput(io.Discard)
+	err := m.Run([]Target{
+		target("a", "channels/store/*.go"),
+		target("b", "channels/store/*.go", "channels/store/.mockery.yaml"),
+		target("c", "public/plugin/*.go", "channels/store/*.go"),
+		target("d", "platform/services/*.go"),
+	}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only d can run along with the others
+	for _, pair := range overlap {
+		if !strings.Contains(pair, "d") {
+			sort.Strings(overlap)
+			t.Logf("expected the targets sharing a package to run one at a time, got %q overlapping", overlap)
+			t.Fail()
+			break
+		}
+	}
+}
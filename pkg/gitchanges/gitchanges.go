@@ -0,0 +1,163 @@
+package gitchanges
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// mainBranches are the candidates for the main branch, in order of preference
+var mainBranches = []string{"origin/master", "origin/main", "master", "main"}
+
+// ChangedFiles returns the files modified in the working tree compared to
+// HEAD, including untracked ones, relative to dir
+func ChangedFiles(dir string) ([]string, error) {
+	return ChangedFilesSince(dir, "HEAD")
+}
+
+// CurrentBranch returns the name of the checked out branch, empty when HEAD
+// is detached
+func CurrentBranch(dir string) (string, error) {
+	lines, err := gitLines(dir, "branch", "--show-current")
+	if err != nil {
+		return "", fmt.Errorf("failed to get current branch: %w", err)
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return lines[0], nil
+}
+
+// IsMainBranch checks if a branch is one of the main branch candidates
+func IsMainBranch(branch string) bool {
+	for _, main := range mainBranches {
+		if branch == main {
+			return true
+		}
+	}
+	return false
+}
+
+// Head returns the commit checked out
+func Head(dir string) (string, error) {
+	lines, err := gitLines(dir, "rev-parse", "HEAD")
+	if err != nil || len(lines) == 0 {
+		return "", fmt.Errorf("failed to get HEAD commit: %w", err)
+	}
+	return lines[0], nil
+}
+
+// MergeBase returns the merge base between HEAD and the main branch
+func MergeBase(dir string) (string, error) {
+	for _, branch := range mainBranches {
+		if _, err := gitLines(dir, "rev-parse", "--verify", "--quiet", branch); err != nil {
+			continue
+		}
+		lines, err := gitLines(dir, "merge-base", "HEAD", branch)
+		if err != nil || len(lines) == 0 {
+			continue
+		}
+		return lines[0], nil
+	}
+	return "", fmt.Errorf("failed to find the merge base with any of %s", strings.Join(mainBranches, ", "))
+}
+
+// ChangedFilesSinceMergeBase returns the files changed in the branch and the
+// working tree compared to the merge base with the main branch
+func ChangedFilesSinceMergeBase(dir string) ([]string, error) {
+	base, err := MergeBase(dir)
+	if err != nil {
+		return nil, err
+	}
+	return ChangedFilesSince(dir, base)
+}
+
+// ChangedFilesSince returns the files modified in the working tree compared
+// to the given revision, including untracked ones, relative to dir
+func ChangedFilesSince(dir, rev string) ([]string, error) {
+	diff, err := gitLines(dir, "diff", "--name-only", "--relative", rev)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get changed files: %w", err)
+	}
+
+	untracked, err := gitLines(dir, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get untracked files: %w", err)
+	}
+
+	return unique(append(diff, untracked...)), nil
+}
+
+// GoPackages maps a list of files to the Go packages containing them, in the
+// "./path/to/pkg" form accepted by the go tool. Packages that no longer exist
+// are skipped.
+func GoPackages(dir string, files []string) []string {
+	var packages []string
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".go") {
+			continue
+		}
+		pkgDir := filepath.Dir(file)
+		if _, err := os.Stat(filepath.Join(dir, pkgDir)); err != nil {
+			continue
+		}
+		if pkgDir == "." {
+			packages = append(packages, ".")
+			continue
+		}
+		packages = append(packages, "./"+filepath.ToSlash(pkgDir))
+	}
+	return unique(packages)
+}
+
+// WithExtensions filters a list of files relative to dir keeping the ones that
+// still exist and have one of the given extensions
+func WithExtensions(dir string, files []string, extensions ...string) []string {
+	var result []string
+	for _, file := range files {
+		for _, ext := range extensions {
+			if !strings.HasSuffix(file, ext) {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(dir, file)); err == nil {
+				result = append(result, file)
+			}
+			break
+		}
+	}
+	return result
+}
+
+func gitLines(dir string, args ...string) ([]string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+func unique(items []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			result = append(result, item)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
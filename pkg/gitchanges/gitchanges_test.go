@@ -0,0 +1,191 @@
+package gitchanges
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// git runs a git command in dir, returning its trimmed output
+func git(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@test", "-c", "commit.gpgsign=false"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v: %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func writeFile(t *testing.T, root, name, content string) {
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commit writes the files and commits them, returning the commit
+func commit(t *testing.T, root string, files map[string]string) string {
+	for name, content := range files {
+		writeFile(t, root, name, content)
+	}
+	git(t, root, "add", "-A")
+	git(t, root, "commit", "-q", "-m", "change")
+	return git(t, root, "rev-parse", "HEAD")
+}
+
+func TestMergeBase(t *testing.T) {
+	for name, tc := range map[string]struct {
+		// refs point to the commits of a linear history, by index, HEAD
+		// being on the last one
+		refs     map[string]int
+		expected int
+		err      bool
+	}{
+		"local main": {
+			refs:     map[string]int{"refs/heads/main": 0},
+			expected: 0,
+		},
+		"master before main": {
+			refs:     map[string]int{"refs/heads/master": 0, "refs/heads/main": 1},
+			expected: 0,
+		},
+		"remote before local": {
+			refs:     map[string]int{"refs/heads/master": 0, "refs/remotes/origin/main": 1},
+			expected: 1,
+		},
+		"origin/master before origin/main": {
+			refs:     map[string]int{"refs/remotes/origin/master": 1, "refs/remotes/origin/main": 0},
+			expected: 1,
+		},
+		"no main branch": {
+			refs: map[string]int{"refs/heads/develop": 0},
+			err:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			git(t, root, "init", "-q", "-b", "feature")
+			var commits []string
+			for _, content := range []string{"one", "two", "three"} {
+				commits = append(commits, commit(t, root, map[string]string{"file.txt": content}))
+			}
+			for ref, i := range tc.refs {
+				git(t, root, "update-ref", ref, commits[i])
+			}
+
+			base, err := MergeBase(root)
+			if tc.err {
+				if err == nil {
+					t.Logf("expected an error, got %s", base)
+					t.Fail()
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if base != commits[tc.expected] {
+				t.Logf("expected the merge base to be commit %d, got %s", tc.expected, base)
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestChangedFilesSince(t *testing.T) {
+	root := t.TempDir()
+	git(t, root, "init", "-q", "-b", "main")
+	base := commit(t, root, map[string]string{
+		".gitignore":         "*.log\n",
+		"server/app/post.go": "package app\n",
+		"server/app/user.go": "package app\n",
+		"server/store/db.go": "package store\n",
+		"webapp/index.ts":    "export {};\n",
+	})
+	commit(t, root, map[string]string{"server/app/post.go": "package app\n\nvar a = 1\n"})
+
+	// Uncommitted, untracked, ignored and outside of the server directory
+	writeFile(t, root, "server/store/db.go", "package store\n\nvar b = 2\n")
+	writeFile(t, root, "server/jobs/job.go", "package jobs\n")
+	writeFile(t, root, "server/debug.log", "ignored\n")
+	writeFile(t, root, "webapp/index.ts", "export const c = 3;\n")
+	writeFile(t, root, "notes.md", "untracked\n")
+
+	server := filepath.Join(root, "server")
+	for name, tc := range map[string]struct {
+		changed  func() ([]string, error)
+		expected []string
+	}{
+		"since a revision": {
+			changed:  func() ([]string, error) { return ChangedFilesSince(server, base) },
+			expected: []string{"app/post.go", "jobs/job.go", "store/db.go"},
+		},
+		"since HEAD": {
+			changed:  func() ([]string, error) { return ChangedFiles(server) },
+			expected: []string{"jobs/job.go", "store/db.go"},
+		},
+		"from the root": {
+			changed:  func() ([]string, error) { return ChangedFilesSince(root, base) },
+			expected: []string{"notes.md", "server/app/post.go", "server/jobs/job.go", "server/store/db.go", "webapp/index.ts"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			files, err := tc.changed()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(files, tc.expected) {
+				t.Logf("expected %q, got %q", tc.expected, files)
+				t.Fail()
+			}
+		})
+	}
+
+	if _, err := ChangedFilesSince(server, "unknown-revision"); err == nil {
+		t.Log("expected an error for an unknown revision")
+		t.Fail()
+	}
+}
+
+func TestGoPackages(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "main.go", "package main\n")
+	writeFile(t, root, "app/post.go", "package app\n")
+	writeFile(t, root, "store/sqlstore/post_store.go", "package sqlstore\n")
+
+	files := []string{
+		"app/post.go",
+		"app/user.go",
+		"main.go",
+		"removed/gone.go",
+		"store/sqlstore/post_store.go",
+		"store/sqlstore/schema.sql",
+		"README.md",
+	}
+	expected := []string{".", "./app", "./store/sqlstore"}
+	if packages := GoPackages(root, files); !reflect.DeepEqual(packages, expected) {
+		t.Logf("expected %q, got %q", expected, packages)
+		t.Fail()
+	}
+}
+
+func TestWithExtensions(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"src/post.ts", "src/post.tsx", "src/post.go", "src/post.test.js"} {
+		writeFile(t, root, name, "")
+	}
+
+	files := []string{"src/post.ts", "src/post.tsx", "src/post.go", "src/post.test.js", "src/deleted.ts", "src/posts"}
+	expected := []string{"src/post.ts", "src/post.tsx", "src/post.test.js"}
+	if result := WithExtensions(root, files, ".ts", ".tsx", ".js"); !reflect.DeepEqual(result, expected) {
+		t.Logf("expected %q, got %q", expected, result)
+		t.Fail()
+	}
+}
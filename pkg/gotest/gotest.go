@@ -0,0 +1,201 @@
+package gotest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event is a single line of `go test -json` output
+type Event struct {
+	Time    time.Time `json:"Time"`
+	Action  string    `json:"Action"`
+	Package string    `json:"Package"`
+	Test    string    `json:"Test"`
+	Elapsed float64   `json:"Elapsed"`
+	Output  string    `json:"Output"`
+
+	// ImportPath identifies the package of the build events of go 1.24,
+	// referred by the FailedBuild of its failed package
+	ImportPath  string `json:"ImportPath"`
+	FailedBuild string `json:"FailedBuild"`
+}
+
+// Failure holds a failed test, or a failed package when Test is empty
+type Failure struct {
+	Package string
+	Test    string
+	Elapsed float64
+	Output  []string
+}
+
+// Report consumes `go test -json` events and keeps track of the results
+type Report struct {
+	out      io.Writer
+	verbose  bool
+	outputs  map[string][]string
+	builds   map[string][]string
+	failures []Failure
+
+	Passed   int
+	Skipped  int
+	Packages int
+}
+
+// NewReport creates a report printing per package results to out. In
+// verbose mode the output of every test is printed as it arrives.
+func NewReport(out io.Writer, verbose bool) *Report {
+	return &Report{
+		out:     out,
+		verbose: verbose,
+		outputs: make(map[string][]string),
+		builds:  make(map[string][]string),
+	}
+}
+
+// Consume reads events from r until EOF. Lines that are not JSON events,
+// like build errors, are printed as they are.
+func (r *Report) Consume(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var event Event
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &event) != nil {
+			fmt.Fprintln(r.out, line)
+			continue
+		}
+		r.HandleEvent(event)
+	}
+	return scanner.Err()
+}
+
+// HandleEvent updates the report with a single event
+func (r *Report) HandleEvent(event Event) {
+	key := event.Package + " " + event.Test
+
+	switch event.Action {
+	case "build-output":
+		r.builds[event.ImportPath] = append(r.builds[event.ImportPath], event.Output)
+		fmt.Fprint(r.out, event.Output)
+	case "output":
+		r.outputs[key] = append(r.outputs[key], event.Output)
+		if r.verbose {
+			fmt.Fprint(r.out, event.Output)
+		}
+	case "pass", "skip":
+		if event.Test == "" {
+			r.Packages++
+			if event.Action == "pass" {
+				fmt.Fprintf(r.out, "ok   %s %.2fs\n", event.Package, event.Elapsed)
+			} else {
+				fmt.Fprintf(r.out, "?    %s [no test files]\n", event.Package)
+			}
+		} else if event.Action == "pass" {
+			r.Passed++
+		} else {
+			r.Skipped++
+		}
+		delete(r.outputs, key)
+	case "fail":
+		output := r.outputs[key]
+		if event.FailedBuild != "" {
+			output = append(slices.Clone(r.builds[event.FailedBuild]), output...)
+		}
+		r.failures = append(r.failures, Failure{
+			Package: event.Package,
+			Test:    event.Test,
+			Elapsed: event.Elapsed,
+			Output:  output,
+		})
+		delete(r.outputs, key)
+		if event.Test == "" {
+			r.Packages++
+			fmt.Fprintf(r.out, "FAIL %s %.2fs\n", event.Package, event.Elapsed)
+		}
+	}
+}
+
+// Failures returns the most specific failures: parent tests failing only
+// because of a failed subtest and packages failing only because of a failed
+// test are left out
+func (r *Report) Failures() []Failure {
+	var result []Failure
+	for _, f := range r.failures {
+		covered := false
+		for _, other := range r.failures {
+			if other.Package != f.Package || other.Test == f.Test {
+				continue
+			}
+			if f.Test == "" || strings.HasPrefix(other.Test, f.Test+"/") {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			result = append(result, f)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Package != result[j].Package {
+			return result[i].Package < result[j].Package
+		}
+		return result[i].Test < result[j].Test
+	})
+	return result
+}
+
+// Failed reports whether any test or package failed
+func (r *Report) Failed() bool {
+	return len(r.failures) > 0
+}
+
+// PrintSummary prints the output of every failure followed by the commands
+// to re-run them. rerun builds the command for a package and -run pattern.
+func (r *Report) PrintSummary(rerun func(pkg, pattern string) string) {
+	failures := r.Failures()
+
+	fmt.Fprintln(r.out)
+	if len(failures) == 0 {
+		fmt.Fprintf(r.out, "All tests passed: %d passed, %d skipped in %d packages\n", r.Passed, r.Skipped, r.Packages)
+		return
+	}
+
+	for _, f := range failures {
+		name := f.Package
+		if f.Test != "" {
+			name = f.Package + " " + f.Test
+		}
+		fmt.Fprintf(r.out, "=== FAIL: %s (%.2fs)\n", name, f.Elapsed)
+		for _, line := range f.Output {
+			fmt.Fprint(r.out, line)
+		}
+		fmt.Fprintln(r.out)
+	}
+
+	fmt.Fprintf(r.out, "%d failed, %d passed, %d skipped in %d packages\n\n", len(failures), r.Passed, r.Skipped, r.Packages)
+	fmt.Fprintln(r.out, "Re-run the failures with:")
+	for _, f := range failures {
+		fmt.Fprintf(r.out, "  %s\n", rerun(f.Package, RunPattern(f.Test)))
+	}
+}
+
+// RunPattern builds a -run expression matching exactly the given test,
+// including subtests
+func RunPattern(test string) string {
+	if test == "" {
+		return ""
+	}
+	parts := strings.Split(test, "/")
+	for i, part := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(part) + "$"
+	}
+	return strings.Join(parts, "/")
+}
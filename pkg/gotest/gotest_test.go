@@ -0,0 +1,135 @@
+package gotest
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReportFailures(t *testing.T) {
+	input := strings.Join([]string{
+		`{"Action":"run","Package":"example.com/app","Test":"TestA"}`,
+		`{"Action":"output","Package":"example.com/app","Test":"TestA","Output":"=== RUN   TestA\n"}`,
+		`{"Action":"pass","Package":"example.com/app","Test":"TestA","Elapsed":0.1}`,
+		`{"Action":"run","Package":"example.com/app","Test":"TestB"}`,
+		`{"Action":"run","Package":"example.com/app","Test":"TestB/sub case"}`,
+		`{"Action":"output","Package":"example.com/app","Test":"TestB/sub case","Output":"    app_test.go:10: boom\n"}`,
+		`{"Action":"fail","Package":"example.com/app","Test":"TestB/sub case","Elapsed":0.2}`,
+		`{"Action":"fail","Package":"example.com/app","Test":"TestB","Elapsed":0.2}`,
+		`{"Action":"skip","Package":"example.com/app","Test":"TestC"}`,
+		`{"Action":"fail","Package":"example.com/app","Elapsed":0.5}`,
+		`{"Action":"output","Package":"example.com/store","Output":"panic: no database\n"}`,
+		`{"Action":"fail","Package":"example.com/store","Elapsed":0.1}`,
+	}, "\n")
+
+	report := NewReport(io.Discard, false)
+	if err := report.Consume(strings.NewReader(input)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if report.Passed != 1 || report.Skipped != 1 || report.Packages != 2 {
+		t.Logf("unexpected counts: passed %d, skipped %d, packages %d", report.Passed, report.Skipped, report.Packages)
+		t.Fail()
+	}
+
+	failures := report.Failures()
+	if len(failures) != 2 {
+		t.Fatalf("expected 2 failures, got %+v", failures)
+	}
+	if failures[0].Package != "example.com/app" || failures[0].Test != "TestB/sub case" {
+		t.Logf("unexpected first failure: %+v", failures[0])
+		t.Fail()
+	}
+	if len(failures[0].Output) != 1 || failures[0].Output[0] != "    app_test.go:10: boom\n" {
+		t.Logf("unexpected first failure output: %q", failures[0].Output)
+		t.Fail()
+	}
+	if failures[1].Package != "example.com/store" || failures[1].Test != "" {
+		t.Logf("unexpected second failure: %+v", failures[1])
+		t.Fail()
+	}
+}
+
+func TestRunPattern(t *testing.T) {
+	for test, expected := range map[string]string{
+		"":                   "",
+		"TestA":              "^TestA$",
+		"TestB/sub_case":     "^TestB$/^sub_case$",
+		"TestC/with.(regex)": `^TestC$/^with\.\(regex\)$`,
+	} {
+		if got := RunPattern(test); got != expected {
+			t.Logf("expected RunPattern(%q) to be %q, got %q", test, expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestReportBuildFailure(t *testing.T) {
+	build := []string{
+		`{"ImportPath":"example.com/app [example.com/app.test]","Action":"build-output","Output":"# example.com/app [example.com/app.test]\n"}`,
+		`{"ImportPath":"example.com/app [example.com/app.test]","Action":"build-output","Output":"./app.go:2:23: undefined: foo\n"}`,
+		`{"ImportPath":"example.com/app [example.com/app.test]","Action":"build-output","Output":"./app.go:3:5: undefined: bar\n"}`,
+		`{"ImportPath":"example.com/app [example.com/app.test]","Action":"build-fail"}`,
+	}
+	buildOutput := []string{
+		"# example.com/app [example.com/app.test]\n",
+		"./app.go:2:23: undefined: foo\n",
+		"./app.go:3:5: undefined: bar\n",
+	}
+	failed := func(pkg string) []string {
+		return []string{
+			`{"Action":"start","Package":"` + pkg + `"}`,
+			`{"Action":"output","Package":"` + pkg + `","Output":"FAIL\t` + pkg + ` [build failed]\n"}`,
+			`{"Action":"fail","Package":"` + pkg + `","Elapsed":0,"FailedBuild":"example.com/app [example.com/app.test]"}`,
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		input    []string
+		expected map[string][]string
+	}{
+		"single package": {
+			input: slices.Concat(build, failed("example.com/app")),
+			expected: map[string][]string{
+				"example.com/app": append(slices.Clone(buildOutput), "FAIL\texample.com/app [build failed]\n"),
+			},
+		},
+		"packages sharing a build": {
+			input: slices.Concat(build, failed("example.com/api"), failed("example.com/web")),
+			expected: map[string][]string{
+				"example.com/api": append(slices.Clone(buildOutput), "FAIL\texample.com/api [build failed]\n"),
+				"example.com/web": append(slices.Clone(buildOutput), "FAIL\texample.com/web [build failed]\n"),
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var out strings.Builder
+			report := NewReport(&out, false)
+			if err := report.Consume(strings.NewReader(strings.Join(tc.input, "\n"))); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !strings.Contains(out.String(), "./app.go:2:23: undefined: foo\n") {
+				t.Logf("expected the build output to be printed, got %q", out.String())
+				t.Fail()
+			}
+			failures := report.Failures()
+			if len(failures) != len(tc.expected) {
+				t.Fatalf("expected %d failed packages, got %+v", len(tc.expected), failures)
+			}
+			for _, f := range failures {
+				expected, ok := tc.expected[f.Package]
+				if !ok || f.Test != "" {
+					t.Logf("unexpected failure %+v", f)
+					t.Fail()
+					continue
+				}
+				if !slices.Equal(f.Output, expected) {
+					t.Logf("expected the failure output of %s to be %q, got %q", f.Package, expected, f.Output)
+					t.Fail()
+				}
+			}
+		})
+	}
+}
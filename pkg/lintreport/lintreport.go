@@ -0,0 +1,265 @@
+package lintreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Issue is a single problem reported by a linter
+type Issue struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+// Supported output formats
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatSARIF = "sarif"
+)
+
+// ValidateFormat checks if the format is one of the supported ones
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatText, FormatJSON, FormatSARIF:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q, expected one of text, json or sarif", format)
+}
+
+// Write writes the issues in the given format
+func Write(w io.Writer, format, tool string, issues []Issue) error {
+	switch format {
+	case FormatJSON:
+		return WriteJSON(w, issues)
+	case FormatSARIF:
+		return WriteSARIF(w, tool, issues)
+	default:
+		return WriteText(w, issues)
+	}
+}
+
+// WriteFile writes the issues in the given format to path, or to stdout
+// when path is empty
+func WriteFile(path, format, tool string, issues []Issue) error {
+	if path == "" {
+		return Write(os.Stdout, format, tool, issues)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer f.Close()
+
+	if err := Write(f, format, tool, issues); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// WriteText writes the issues in the file:line:col format understood by most editors
+func WriteText(w io.Writer, issues []Issue) error {
+	for _, issue := range issues {
+		if _, err := fmt.Fprintf(w, "%s:%d:%d: %s (%s)\n", issue.File, issue.Line, issue.Column, issue.Message, issue.Rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the issues as a JSON array
+func WriteJSON(w io.Writer, issues []Issue) error {
+	if issues == nil {
+		issues = []Issue{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(issues)
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// WriteSARIF writes the issues as a SARIF 2.1.0 log
+func WriteSARIF(w io.Writer, tool string, issues []Issue) error {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: tool, Rules: []sarifRule{}}},
+		Results: []sarifResult{},
+	}
+
+	rules := make(map[string]bool)
+	for _, issue := range issues {
+		if issue.Rule != "" && !rules[issue.Rule] {
+			rules[issue.Rule] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: issue.Rule})
+		}
+
+		line := issue.Line
+		if line < 1 {
+			line = 1
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  issue.Rule,
+			Level:   sarifLevel(issue.Severity),
+			Message: sarifMessage{Text: issue.Message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: issue.File},
+					Region:           sarifRegion{StartLine: line, StartColumn: issue.Column},
+				},
+			}},
+		})
+	}
+	sort.Slice(run.Tool.Driver.Rules, func(i, j int) bool {
+		return run.Tool.Driver.Rules[i].ID < run.Tool.Driver.Rules[j].ID
+	})
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	})
+}
+
+func sarifLevel(severity string) string {
+	switch severity {
+	case "warning":
+		return "warning"
+	case "info", "note":
+		return "note"
+	default:
+		return "error"
+	}
+}
+
+// ParseGolangciLint parses the output of golangci-lint run --out-format json
+func ParseGolangciLint(data []byte) ([]Issue, error) {
+	var report struct {
+		Issues []struct {
+			FromLinter string `json:"FromLinter"`
+			Text       string `json:"Text"`
+			Severity   string `json:"Severity"`
+			Pos        struct {
+				Filename string `json:"Filename"`
+				Line     int    `json:"Line"`
+				Column   int    `json:"Column"`
+			} `json:"Pos"`
+		} `json:"Issues"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse golangci-lint output: %w", err)
+	}
+
+	var issues []Issue
+	for _, i := range report.Issues {
+		severity := i.Severity
+		if severity == "" {
+			severity = "error"
+		}
+		issues = append(issues, Issue{
+			File:     i.Pos.Filename,
+			Line:     i.Pos.Line,
+			Column:   i.Pos.Column,
+			Severity: severity,
+			Rule:     i.FromLinter,
+			Message:  i.Text,
+		})
+	}
+	return issues, nil
+}
+
+// ParseESLint parses the output of eslint --format json
+func ParseESLint(data []byte) ([]Issue, error) {
+	var results []struct {
+		FilePath string `json:"filePath"`
+		Messages []struct {
+			RuleID   string `json:"ruleId"`
+			Severity int    `json:"severity"`
+			Message  string `json:"message"`
+			Line     int    `json:"line"`
+			Column   int    `json:"column"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse eslint output: %w", err)
+	}
+
+	var issues []Issue
+	for _, r := range results {
+		for _, m := range r.Messages {
+			severity := "error"
+			if m.Severity == 1 {
+				severity = "warning"
+			}
+			issues = append(issues, Issue{
+				File:     r.FilePath,
+				Line:     m.Line,
+				Column:   m.Column,
+				Severity: severity,
+				Rule:     m.RuleID,
+				Message:  m.Message,
+			})
+		}
+	}
+	return issues, nil
+}
@@ -0,0 +1,108 @@
+package lintreport
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParse(t *testing.T) {
+	for name, tc := range map[string]struct {
+		parse    func([]byte) ([]Issue, error)
+		fixture  string
+		expected []Issue
+	}{
+		"golangci-lint": {
+			parse:   ParseGolangciLint,
+			fixture: "golangci-lint.json",
+			expected: []Issue{
+				{File: "channels/app/post.go", Line: 142, Column: 23, Severity: "error", Rule: "errcheck", Message: "Error return value of `resp.Body.Close` is not checked"},
+				{File: "channels/store/sqlstore/user_store.go", Line: 37, Column: 9, Severity: "warning", Rule: "govet", Message: "printf: fmt.Sprintf format %d has arg name of wrong type string"},
+				{File: "cmd/mattermost/main.go", Line: 0, Column: 0, Severity: "error", Rule: "typecheck", Message: "undefined: foo"},
+			},
+		},
+		"eslint": {
+			parse:   ParseESLint,
+			fixture: "eslint.json",
+			expected: []Issue{
+				{File: "/home/dev/mattermost/webapp/channels/src/components/post.tsx", Line: 12, Column: 7, Severity: "error", Rule: "no-unused-vars", Message: "'props' is defined but never used."},
+				{File: "/home/dev/mattermost/webapp/channels/src/components/post.tsx", Line: 40, Column: 13, Severity: "warning", Rule: "react/jsx-key", Message: `Missing "key" prop for element in iterator`},
+				{File: "/home/dev/mattermost/webapp/channels/src/broken.ts", Line: 3, Column: 10, Severity: "error", Rule: "", Message: "Parsing error: ';' expected."},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			issues, err := tc.parse(readFixture(t, tc.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(issues, tc.expected) {
+				t.Logf("expected %+v, got %+v", tc.expected, issues)
+				t.Fail()
+			}
+
+			if _, err := tc.parse([]byte("Error: can't load config")); err == nil {
+				t.Log("expected an error for output that isn't JSON")
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	issues, err := ParseGolangciLint(readFixture(t, "golangci-lint.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, "golangci-lint", issues); err != nil {
+		t.Fatal(err)
+	}
+	if expected := readFixture(t, "golangci-lint.sarif"); !bytes.Equal(buf.Bytes(), expected) {
+		t.Logf("expected:\n%s\ngot:\n%s", expected, buf.Bytes())
+		t.Fail()
+	}
+
+	buf.Reset()
+	if err := WriteSARIF(&buf, "eslint", nil); err != nil {
+		t.Fatal(err)
+	}
+	var empty sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &empty); err != nil {
+		t.Fatal(err)
+	}
+	if len(empty.Runs) != 1 || empty.Runs[0].Results == nil || empty.Runs[0].Tool.Driver.Name != "eslint" {
+		t.Logf("expected a single run with empty results, got %s", buf.Bytes())
+		t.Fail()
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	issues, err := ParseESLint(readFixture(t, "eslint.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, issues[:2]); err != nil {
+		t.Fatal(err)
+	}
+	expected := "/home/dev/mattermost/webapp/channels/src/components/post.tsx:12:7: 'props' is defined but never used. (no-unused-vars)\n" +
+		"/home/dev/mattermost/webapp/channels/src/components/post.tsx:40:13: Missing \"key\" prop for element in iterator (react/jsx-key)\n"
+	if buf.String() != expected {
+		t.Logf("expected %q, got %q", expected, buf.String())
+		t.Fail()
+	}
+}
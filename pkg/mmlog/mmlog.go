@@ -0,0 +1,388 @@
+package mmlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const timeStampFormat = "2006-01-02 15:04:05.000 Z07:00"
+
+// ANSI escape sequences used to render log entries
+const (
+	colorReset  = "\033[0m"
+	colorBold   = "\033[1m"
+	colorDim    = "\033[2m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorBlue   = "\033[34m"
+	colorCyan   = "\033[36m"
+	colorGray   = "\033[90m"
+)
+
+// levelOrder maps the Mattermost log levels to their severity
+var levelOrder = map[string]int{
+	"trace":    0,
+	"debug":    1,
+	"info":     2,
+	"warn":     3,
+	"error":    4,
+	"critical": 5,
+	"fatal":    6,
+	"panic":    7,
+}
+
+// Levels are the Mattermost log levels, from the least to the most severe
+var Levels = []string{"trace", "debug", "info", "warn", "error", "critical", "fatal", "panic"}
+
+// ansiRegexp matches the ANSI color sequences
+var ansiRegexp = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// Entry is a single parsed log line
+type Entry struct {
+	Raw       string
+	JSON      bool
+	Timestamp string
+	Level     string
+	Message   string
+	Caller    string
+	Logger    string
+	Fields    map[string]any
+}
+
+// Parse parses a Mattermost JSON log line. Lines that are not JSON, like
+// compiler output or panics, are returned as plain entries.
+func Parse(line string) Entry {
+	entry := Entry{Raw: line}
+
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return entry
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(trimmed), &fields); err != nil {
+		return entry
+	}
+
+	entry.JSON = true
+	entry.Timestamp = takeString(fields, "timestamp")
+	entry.Level = strings.ToLower(takeString(fields, "level"))
+	entry.Message = takeString(fields, "msg")
+	if entry.Message == "" {
+		entry.Message = takeString(fields, "message")
+	}
+	entry.Caller = takeString(fields, "caller")
+	entry.Logger = takeString(fields, "logger")
+	entry.Fields = fields
+
+	return entry
+}
+
+// takeString removes a key from fields and returns its value as a string
+func takeString(fields map[string]any, key string) string {
+	value, ok := fields[key]
+	if !ok {
+		return ""
+	}
+	delete(fields, key)
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// Options configures filtering and rendering of log entries
+type Options struct {
+	// Level is the minimum level to show, empty shows everything
+	Level string
+	// Grep only shows lines matching the regular expression
+	Grep *regexp.Regexp
+	// Logger only shows entries whose logger or plugin contains this text
+	Logger string
+	// Caller only shows entries whose caller contains this text
+	Caller string
+	// Highlight lists field names to make stand out
+	Highlight []string
+	// NoColor disables ANSI colors
+	NoColor bool
+}
+
+// SetGrep compiles the pattern used to filter lines, an empty pattern disables it
+func (o *Options) SetGrep(pattern string) error {
+	if pattern == "" {
+		o.Grep = nil
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid grep pattern: %w", err)
+	}
+	o.Grep = re
+	return nil
+}
+
+// Validate checks the options are usable
+func (o Options) Validate() error {
+	if o.Level == "" {
+		return nil
+	}
+	if _, ok := levelOrder[strings.ToLower(o.Level)]; !ok {
+		return fmt.Errorf("unknown log level %q", o.Level)
+	}
+	return nil
+}
+
+// Match checks if an entry passes the filters. Plain lines are only subject
+// to the grep filter so build errors are never hidden.
+func (o Options) Match(entry Entry) bool {
+	if o.Grep != nil && !o.Grep.MatchString(entry.Raw) {
+		return false
+	}
+
+	if !entry.JSON {
+		return true
+	}
+
+	if !MatchLevel(entry.Level, o.Level) {
+		return false
+	}
+
+	if o.Logger != "" {
+		pluginID, _ := entry.Fields["plugin_id"].(string)
+		if !strings.Contains(entry.Logger, o.Logger) && !strings.Contains(pluginID, o.Logger) {
+			return false
+		}
+	}
+
+	if o.Caller != "" && !strings.Contains(entry.Caller, o.Caller) {
+		return false
+	}
+
+	return true
+}
+
+// MatchLevel checks if a level is at least min. Empty or unknown levels
+// always match.
+func MatchLevel(level, min string) bool {
+	if min == "" {
+		return true
+	}
+	l, ok := levelOrder[strings.ToLower(level)]
+	return !ok || l >= levelOrder[strings.ToLower(min)]
+}
+
+// LineLevel returns the level of a log line, either in JSON or rendered by
+// Format, empty when it has none
+func LineLevel(line string) string {
+	if entry := Parse(line); entry.JSON {
+		return entry.Level
+	}
+
+	// Rendered lines start with the level, after the time when there is one
+	fields := strings.Fields(StripANSI(line))
+	if len(fields) > 1 && strings.Count(fields[0], ":") == 2 {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	level := strings.ToLower(fields[0])
+	if _, ok := levelOrder[level]; !ok {
+		return ""
+	}
+	return level
+}
+
+// StripANSI removes the ANSI color sequences from a line
+func StripANSI(line string) string {
+	return ansiRegexp.ReplaceAllString(line, "")
+}
+
+// Format renders an entry as a human readable line, without trailing newline
+func (o Options) Format(entry Entry) string {
+	if !entry.JSON {
+		return entry.Raw
+	}
+
+	var b strings.Builder
+
+	if ts := formatTimestamp(entry.Timestamp); ts != "" {
+		b.WriteString(o.paint(colorGray, ts))
+		b.WriteString(" ")
+	}
+
+	level := strings.ToUpper(entry.Level)
+	if level == "" {
+		level = "-"
+	}
+	b.WriteString(o.paint(levelColor(entry.Level), fmt.Sprintf("%-5s", level)))
+	b.WriteString(" ")
+	b.WriteString(entry.Message)
+
+	keys := make([]string, 0, len(entry.Fields))
+	for key := range entry.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := formatValue(entry.Fields[key])
+		b.WriteString(" ")
+		switch {
+		case key == "error" || key == "err":
+			b.WriteString(o.paint(colorRed, key+"="+value))
+		case o.isHighlighted(key):
+			b.WriteString(o.paint(colorBold+colorYellow, key+"="+value))
+		default:
+			b.WriteString(o.paint(colorCyan, key))
+			b.WriteString("=")
+			b.WriteString(value)
+		}
+	}
+
+	if entry.Logger != "" {
+		b.WriteString(" ")
+		b.WriteString(o.paint(colorBlue, "logger="+entry.Logger))
+	}
+	if entry.Caller != "" {
+		b.WriteString(" ")
+		b.WriteString(o.paint(colorDim, "caller="+entry.Caller))
+	}
+
+	return b.String()
+}
+
+func (o Options) isHighlighted(key string) bool {
+	for _, h := range o.Highlight {
+		if h == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (o Options) paint(color, text string) string {
+	if o.NoColor {
+		return text
+	}
+	return color + text + colorReset
+}
+
+func levelColor(level string) string {
+	switch level {
+	case "trace", "debug":
+		return colorGray
+	case "info":
+		return colorGreen
+	case "warn":
+		return colorYellow
+	case "error", "critical", "fatal", "panic":
+		return colorBold + colorRed
+	default:
+		return colorBlue
+	}
+}
+
+// formatTimestamp reduces the log timestamp to the time of day
+func formatTimestamp(ts string) string {
+	if ts == "" {
+		return ""
+	}
+	t, err := time.Parse(timeStampFormat, ts)
+	if err != nil {
+		return ts
+	}
+	return t.Format("15:04:05.000")
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		if v == "" || strings.ContainsAny(v, " \t\"=") {
+			return fmt.Sprintf("%q", v)
+		}
+		return v
+	case map[string]any, []any:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Renderer is an io.Writer that parses the log lines written to it and
+// writes the filtered and formatted result to the underlying writer
+type Renderer struct {
+	out  io.Writer
+	opts Options
+	mu   sync.Mutex
+	buf  []byte
+}
+
+// NewRenderer creates a renderer writing to out
+func NewRenderer(out io.Writer, opts Options) *Renderer {
+	return &Renderer{
+		out:  out,
+		opts: opts,
+	}
+}
+
+// Write implements io.Writer, rendering every complete line
+func (r *Renderer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.buf = append(r.buf, p...)
+	for {
+		idx := bytes.IndexByte(r.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		line := string(r.buf[:idx])
+		r.buf = r.buf[idx+1:]
+		if err := r.writeLine(line); err != nil {
+			return len(p), err
+		}
+	}
+
+	return len(p), nil
+}
+
+// WriteLine renders a single line
+func (r *Renderer) WriteLine(line string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.writeLine(line)
+}
+
+// Flush renders any pending partial line
+func (r *Renderer) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.buf) == 0 {
+		return nil
+	}
+	line := string(r.buf)
+	r.buf = nil
+	return r.writeLine(line)
+}
+
+func (r *Renderer) writeLine(line string) error {
+	entry := Parse(strings.TrimSuffix(line, "\r"))
+	if !r.opts.Match(entry) {
+		return nil
+	}
+	_, err := io.WriteString(r.out, r.opts.Format(entry)+"\n")
+	return err
+}
@@ -0,0 +1,139 @@
+package mmlog
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	entry := Parse(`{"timestamp":"2024-01-02 10:58:53.091 +01:00","level":"warn","msg":"Slow query","caller":"sqlstore/store.go:42","duration":1.5,"user_id":"abc"}`)
+
+	if !entry.JSON {
+		t.Fatalf("expected a JSON entry")
+	}
+	if entry.Level != "warn" || entry.Message != "Slow query" || entry.Caller != "sqlstore/store.go:42" {
+		t.Logf("unexpected entry: %+v", entry)
+		t.Fail()
+	}
+	if len(entry.Fields) != 2 {
+		t.Logf("expected 2 extra fields, got %v", entry.Fields)
+		t.Fail()
+	}
+
+	plain := Parse("# github.com/mattermost/mattermost/server/v8/channels/app")
+	if plain.JSON {
+		t.Logf("expected a plain entry")
+		t.Fail()
+	}
+}
+
+func TestMatch(t *testing.T) {
+	debug := Parse(`{"level":"debug","msg":"Received HTTP request","caller":"web/handlers.go:10"}`)
+	errorEntry := Parse(`{"level":"error","msg":"Plugin failed","caller":"plugin/health.go:20","plugin_id":"com.mattermost.demo"}`)
+	plain := Parse("panic: runtime error")
+
+	for name, tc := range map[string]struct {
+		opts     Options
+		entry    Entry
+		expected bool
+	}{
+		"no filters": {
+			entry:    debug,
+			expected: true,
+		},
+		"below level": {
+			opts:     Options{Level: "info"},
+			entry:    debug,
+			expected: false,
+		},
+		"above level": {
+			opts:     Options{Level: "warn"},
+			entry:    errorEntry,
+			expected: true,
+		},
+		"level filter keeps plain lines": {
+			opts:     Options{Level: "error"},
+			entry:    plain,
+			expected: true,
+		},
+		"grep mismatch": {
+			opts:     Options{Grep: regexp.MustCompile("HTTP")},
+			entry:    errorEntry,
+			expected: false,
+		},
+		"grep on plain lines": {
+			opts:     Options{Grep: regexp.MustCompile("^panic")},
+			entry:    plain,
+			expected: true,
+		},
+		"logger matches plugin id": {
+			opts:     Options{Logger: "demo"},
+			entry:    errorEntry,
+			expected: true,
+		},
+		"caller mismatch": {
+			opts:     Options{Caller: "sqlstore"},
+			entry:    debug,
+			expected: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := tc.opts.Match(tc.entry); got != tc.expected {
+				t.Logf("expected %v, got %v", tc.expected, got)
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestRenderer(t *testing.T) {
+	var out bytes.Buffer
+	r := NewRenderer(&out, Options{Level: "info", NoColor: true})
+
+	r.Write([]byte(`{"level":"debug","msg":"hidden"}` + "\n" + `{"level":"info","msg":"Server is start`))
+	r.Write([]byte(`ing","port":8065}` + "\nplain line\n"))
+
+	expected := "INFO  Server is starting port=8065\nplain line\n"
+	if out.String() != expected {
+		t.Logf("expected %q, got %q", expected, out.String())
+		t.Fail()
+	}
+}
+
+func TestLineLevel(t *testing.T) {
+	rendered := Options{}.Format(Parse(`{"timestamp":"2024-01-02 10:58:53.091 +01:00","level":"warn","msg":"Slow query"}`))
+
+	for name, tc := range map[string]struct {
+		line     string
+		expected string
+	}{
+		"json": {
+			line:     `{"level":"error","msg":"Plugin failed"}`,
+			expected: "error",
+		},
+		"rendered with colors": {
+			line:     rendered,
+			expected: "warn",
+		},
+		"rendered without time": {
+			line:     "DEBUG Received HTTP request",
+			expected: "debug",
+		},
+		"plain": {
+			line:     "panic: runtime error",
+			expected: "",
+		},
+		"empty": {
+			line:     "",
+			expected: "",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := LineLevel(tc.line); got != tc.expected {
+				t.Logf("expected %q, got %q", tc.expected, got)
+				t.Fail()
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Runtime is a Node installation able to run the npm commands of a directory
+type Runtime struct {
+	// Version is the version of the installation, without the leading v
+	Version string
+	// BinDir is the directory holding the node, npm and npx binaries
+	BinDir string
+	// Source is the version manager the installation comes from, or "system"
+	Source string
+}
+
+// versionManager describes where a Node version manager keeps its installs
+type versionManager struct {
+	name string
+	// versionsDir returns the directory with one entry per installed version
+	versionsDir func(getenv func(string) string, home string) string
+	// binDir is the path of the bin directory inside a version entry
+	binDir string
+}
+
+var versionManagers = []versionManager{
+	{
+		name: "nvm",
+		versionsDir: func(getenv func(string) string, home string) string {
+			return filepath.Join(envOr(getenv, "NVM_DIR", filepath.Join(home, ".nvm")), "versions", "node")
+		},
+		binDir: "bin",
+	},
+	{
+		name: "fnm",
+		versionsDir: func(getenv func(string) string, home string) string {
+			dir := getenv("FNM_DIR")
+			if dir == "" {
+				dir = filepath.Join(envOr(getenv, "XDG_DATA_HOME", filepath.Join(home, ".local", "share")), "fnm")
+				if _, err := os.Stat(dir); err != nil {
+					dir = filepath.Join(home, ".fnm")
+				}
+			}
+			return filepath.Join(dir, "node-versions")
+		},
+		binDir: filepath.Join("installation", "bin"),
+	},
+	{
+		name: "volta",
+		versionsDir: func(getenv func(string) string, home string) string {
+			return filepath.Join(envOr(getenv, "VOLTA_HOME", filepath.Join(home, ".volta")), "tools", "image", "node")
+		},
+		binDir: "bin",
+	},
+	{
+		name: "asdf",
+		versionsDir: func(getenv func(string) string, home string) string {
+			return filepath.Join(envOr(getenv, "ASDF_DATA_DIR", filepath.Join(home, ".asdf")), "installs", "nodejs")
+		},
+		binDir: "bin",
+	},
+}
+
+// resolver finds Node installations, with the environment injectable for
+// testing
+type resolver struct {
+	home     string
+	getenv   func(string) string
+	lookPath func(string) (string, error)
+	version  func(node string) (string, error)
+}
+
+// Resolve finds the Node runtime for the given directory. The version is
+// taken from the closest .nvmrc and looked up in the nvm, fnm, volta and asdf
+// installs, falling back to the node binary in the PATH.
+func Resolve(dir string) (*Runtime, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	r := resolver{
+		home:     home,
+		getenv:   os.Getenv,
+		lookPath: exec.LookPath,
+		version:  nodeVersion,
+	}
+	return r.resolve(dir)
+}
+
+func (r resolver) resolve(dir string) (*Runtime, error) {
+	wanted, nvmrc, err := readNvmrc(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if wanted != "" {
+		var best *Runtime
+		for _, vm := range versionManagers {
+			rt := r.installed(vm, wanted)
+			if rt != nil && (best == nil || compareVersions(rt.Version, best.Version) > 0) {
+				best = rt
+			}
+		}
+		if best != nil {
+			return best, nil
+		}
+	}
+
+	system, err := r.system()
+	if err != nil {
+		if wanted != "" {
+			return nil, fmt.Errorf("node %s required by %s is not installed: %w", wanted, nvmrc, err)
+		}
+		return nil, err
+	}
+	if isUnresolvedAlias(wanted) {
+		fmt.Fprintf(os.Stderr, "Warning: %s requires node %s, which mmdev can't resolve, using node %s from the PATH\n", nvmrc, wanted, system.Version)
+	} else if wanted != "" && !matchesVersion(wanted, system.Version) {
+		fmt.Fprintf(os.Stderr, "Warning: %s requires node %s but no version manager has it installed, using node %s from the PATH\n", nvmrc, wanted, system.Version)
+	}
+	return system, nil
+}
+
+// installed returns the highest version of a version manager matching wanted
+func (r resolver) installed(vm versionManager, wanted string) *Runtime {
+	versionsDir := vm.versionsDir(r.getenv, r.home)
+	entries, err := os.ReadDir(versionsDir)
+	if err != nil {
+		return nil
+	}
+
+	var best *Runtime
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		version := strings.TrimPrefix(entry.Name(), "v")
+		if !matchesVersion(wanted, version) {
+			continue
+		}
+		binDir := filepath.Join(versionsDir, entry.Name(), vm.binDir)
+		if _, err := os.Stat(filepath.Join(binDir, "node")); err != nil {
+			continue
+		}
+		if best == nil || compareVersions(version, best.Version) > 0 {
+			best = &Runtime{Version: version, BinDir: binDir, Source: vm.name}
+		}
+	}
+	return best
+}
+
+// system returns the node binary in the PATH
+func (r resolver) system() (*Runtime, error) {
+	path, err := r.lookPath("node")
+	if err != nil {
+		return nil, fmt.Errorf("node not found in PATH: %w", err)
+	}
+	version, err := r.version(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Runtime{Version: version, BinDir: filepath.Dir(path), Source: "system"}, nil
+}
+
+// Command creates a command running a binary of the runtime, like npm or npx,
+// in dir
+func (rt *Runtime) Command(dir, name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(filepath.Join(rt.BinDir, name), args...)
+	cmd.Dir = dir
+	cmd.Env = rt.Env()
+	return cmd
+}
+
+// Env returns the current environment with the runtime first in the PATH
+func (rt *Runtime) Env() []string {
+	env := make([]string, 0, len(os.Environ())+1)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "PATH=") {
+			env = append(env, kv)
+		}
+	}
+	return append(env, "PATH="+rt.BinDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// String describes the runtime
+func (rt *Runtime) String() string {
+	return fmt.Sprintf("node v%s (%s)", rt.Version, rt.Source)
+}
+
+// readNvmrc reads the version from the closest .nvmrc in dir or its parents
+func readNvmrc(dir string) (string, string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	for {
+		path := filepath.Join(abs, ".nvmrc")
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return strings.TrimPrefix(strings.TrimSpace(string(data)), "v"), path, nil
+		} else if !os.IsNotExist(err) {
+			return "", "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return "", "", nil
+		}
+		abs = parent
+	}
+}
+
+func nodeVersion(node string) (string, error) {
+	output, err := exec.Command(node, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get node version: %w", err)
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(output)), "v"), nil
+}
+
+// ltsCodenames maps the codenames of the LTS aliases, like lts/iron, to
+// their major version
+var ltsCodenames = map[string]string{
+	"argon":    "4",
+	"boron":    "6",
+	"carbon":   "8",
+	"dubnium":  "10",
+	"erbium":   "12",
+	"fermium":  "14",
+	"gallium":  "16",
+	"hydrogen": "18",
+	"iron":     "20",
+	"jod":      "22",
+	"krypton":  "24",
+}
+
+// matchesVersion checks if version satisfies wanted, which may be a partial
+// version like 20 or 20.11, an alias like node matching any version, lts/*
+// matching the LTS lines or an LTS codename like lts/iron. Other aliases
+// match no version.
+func matchesVersion(wanted, version string) bool {
+	switch {
+	case wanted == "node" || wanted == "stable":
+		return true
+	case wanted == "lts/*":
+		// Every even major version is an LTS line
+		major, err := strconv.Atoi(strings.Split(version, ".")[0])
+		return err == nil && major%2 == 0
+	case strings.HasPrefix(wanted, "lts/"):
+		major, ok := ltsCodenames[strings.ToLower(strings.TrimPrefix(wanted, "lts/"))]
+		return ok && matchesVersion(major, version)
+	}
+	return version == wanted || strings.HasPrefix(version, wanted+".")
+}
+
+// isUnresolvedAlias reports whether wanted is an alias matchesVersion
+// doesn't know, like lts/-1 or the codename of a newer LTS
+func isUnresolvedAlias(wanted string) bool {
+	if wanted == "lts/*" || !strings.HasPrefix(wanted, "lts/") {
+		return false
+	}
+	_, ok := ltsCodenames[strings.ToLower(strings.TrimPrefix(wanted, "lts/"))]
+	return !ok
+}
+
+// compareVersions compares two dotted versions numerically
+func compareVersions(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var an, bn int
+		if i < len(as) {
+			an, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			bn, _ = strconv.Atoi(bs[i])
+		}
+		if an != bn {
+			if an < bn {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func envOr(getenv func(string) string, key, fallback string) string {
+	if value := getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,72 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	home := t.TempDir()
+	for _, dir := range []string{
+		".nvm/versions/node/v18.17.0/bin",
+		".nvm/versions/node/v20.9.0/bin",
+		".local/share/fnm/node-versions/v20.11.1/installation/bin",
+		".volta/tools/image/node/21.0.0/bin",
+		".asdf/installs/nodejs/20.10.0",
+	} {
+		if err := os.MkdirAll(filepath.Join(home, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Base(dir) == "bin" {
+			if err := os.WriteFile(filepath.Join(home, dir, "node"), nil, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	r := resolver{
+		home:   home,
+		getenv: func(string) string { return "" },
+		lookPath: func(string) (string, error) {
+			return "/usr/bin/node", nil
+		},
+		version: func(string) (string, error) { return "22.1.0", nil },
+	}
+
+	for name, tc := range map[string]struct {
+		nvmrc    string
+		expected string
+	}{
+		"exact version":          {nvmrc: "18.17.0", expected: "18.17.0 nvm"},
+		"major picks the newest": {nvmrc: "20", expected: "20.11.1 fnm"},
+		"minor version":          {nvmrc: "v20.9", expected: "20.9.0 nvm"},
+		"volta":                  {nvmrc: "21", expected: "21.0.0 volta"},
+		"not installed":          {nvmrc: "16", expected: "22.1.0 system"},
+		"no nvmrc":               {nvmrc: "", expected: "22.1.0 system"},
+		"lts alias":              {nvmrc: "lts/*", expected: "20.11.1 fnm"},
+		"lts codename":           {nvmrc: "lts/hydrogen", expected: "18.17.0 nvm"},
+		"lts codename newest":    {nvmrc: "lts/Iron", expected: "20.11.1 fnm"},
+		"lts codename missing":   {nvmrc: "lts/gallium", expected: "22.1.0 system"},
+		"unknown lts alias":      {nvmrc: "lts/-1", expected: "22.1.0 system"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.nvmrc != "" {
+				if err := os.WriteFile(filepath.Join(dir, ".nvmrc"), []byte(tc.nvmrc+"\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			rt, err := r.resolve(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprintf("%s %s", rt.Version, rt.Source); got != tc.expected {
+				t.Logf("expected: %v, got %v", tc.expected, got)
+				t.Fail()
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Event is what happened to a process
+type Event string
+
+const (
+	BuildFailed Event = "build_failed"
+	Crashed     Event = "crashed"
+	Recovered   Event = "recovered"
+)
+
+// Events are the events notified by default
+var Events = []Event{BuildFailed, Crashed, Recovered}
+
+// Notification tells about an event of a process
+type Notification struct {
+	Event   Event
+	Process string
+	// Detail is the error of a build failure or the exit status of a crash
+	Detail string
+	Time   time.Time
+}
+
+// Message returns the text of the notification
+func (n Notification) Message() string {
+	switch n.Event {
+	case BuildFailed:
+		return fmt.Sprintf("%s build failed: %s", n.Process, n.Detail)
+	case Crashed:
+		if n.Detail == "" {
+			return fmt.Sprintf("%s crashed", n.Process)
+		}
+		return fmt.Sprintf("%s crashed (%s)", n.Process, n.Detail)
+	case Recovered:
+		return fmt.Sprintf("%s recovered", n.Process)
+	}
+	return fmt.Sprintf("%s: %s", n.Process, n.Event)
+}
+
+// Target delivers the notifications
+type Target interface {
+	Send(n Notification) error
+}
+
+// Options configures the targets of a notifier
+type Options struct {
+	// Bell rings the terminal bell
+	Bell bool
+	// OSC9 shows a desktop notification through the terminal
+	OSC9 bool
+	// Webhook receives the message as the text of a JSON post, the format
+	// of the Mattermost and Slack incoming webhooks
+	Webhook string
+	// Command is run with sh -c, getting the notification in its environment
+	Command string
+	// Events limits the notifications to these events, all by default
+	Events []string
+}
+
+// Validate checks the events and the webhook URL
+func (o Options) Validate() error {
+	for _, event := range o.Events {
+		if !validEvent(Event(event)) {
+			return fmt.Errorf("unknown notification event %q, expected one of %s", event, joinEvents(Events))
+		}
+	}
+	if o.Webhook != "" {
+		u, err := url.Parse(o.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid notification webhook %q, expected an http or https URL", o.Webhook)
+		}
+	}
+	return nil
+}
+
+func validEvent(event Event) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func joinEvents(events []Event) string {
+	var names []string
+	for _, event := range events {
+		names = append(names, string(event))
+	}
+	return strings.Join(names, ", ")
+}
+
+// Notifier sends the notifications to its targets
+type Notifier struct {
+	targets []Target
+	events  map[Event]bool
+}
+
+// New creates a notifier with the targets of the options. The terminal ones
+// write to terminal, skipped when it's nil.
+func New(opts Options, terminal io.Writer) (*Notifier, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	n := &Notifier{events: make(map[Event]bool)}
+	events := opts.Events
+	if len(events) == 0 {
+		for _, event := range Events {
+			events = append(events, string(event))
+		}
+	}
+	for _, event := range events {
+		n.events[Event(event)] = true
+	}
+
+	if terminal != nil && (opts.Bell || opts.OSC9) {
+		n.targets = append(n.targets, NewTerminal(terminal, opts.Bell, opts.OSC9))
+	}
+	if opts.Webhook != "" {
+		n.targets = append(n.targets, NewWebhook(opts.Webhook))
+	}
+	if opts.Command != "" {
+		n.targets = append(n.targets, NewCommand(opts.Command))
+	}
+	return n, nil
+}
+
+// Enabled tells if the notifier has targets for the event
+func (n *Notifier) Enabled(event Event) bool {
+	return n != nil && len(n.targets) > 0 && n.events[event]
+}
+
+// Send delivers a notification to all the targets, unless its event is
+// filtered out. It may block on slow targets, like webhooks.
+func (n *Notifier) Send(notification Notification) error {
+	if !n.Enabled(notification.Event) {
+		return nil
+	}
+	if notification.Time.IsZero() {
+		notification.Time = time.Now()
+	}
+	var errs []error
+	for _, target := range n.targets {
+		if err := target.Send(notification); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
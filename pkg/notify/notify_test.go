@@ -0,0 +1,184 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTracker(t *testing.T) {
+	tr := NewTracker()
+	var events []string
+	record := func(n Notification, ok bool) {
+		if ok {
+			events = append(events, n.Message())
+		}
+	}
+
+	record(tr.Ready("server"))
+	record(tr.BuildFailed("server", errors.New("undefined: foo")))
+	record(tr.BuildFailed("server", errors.New("undefined: bar")))
+	record(tr.Crashed("server", "exit 2"))
+	record(tr.Crashed("webapp", "exit 1"))
+	record(tr.Ready("server"))
+	record(tr.Ready("server"))
+	record(tr.Crashed("server", "signal: killed"))
+	tr.Reset("webapp")
+	record(tr.Ready("webapp"))
+
+	expected := []string{
+		"server build failed: undefined: foo",
+		"webapp crashed (exit 1)",
+		"server recovered",
+		"server crashed (signal: killed)",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Logf("expected %q, got %q", expected, events)
+		t.Fail()
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	n := Notification{Event: BuildFailed, Process: "server", Detail: "line one\nline\x1b two\a"}
+	for name, tc := range map[string]struct {
+		bell     bool
+		osc9     bool
+		tmux     bool
+		expected string
+	}{
+		"bell": {
+			bell:     true,
+			expected: "\a",
+		},
+		"osc9": {
+			osc9:     true,
+			expected: "\x1b]9;mmdev: server build failed: line one line two\x07",
+		},
+		"both in tmux": {
+			bell:     true,
+			osc9:     true,
+			tmux:     true,
+			expected: "\x1bPtmux;\x1b\x1b]9;mmdev: server build failed: line one line two\x07\x1b\\\a",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			term := NewTerminal(&buf, tc.bell, tc.osc9)
+			term.tmux = tc.tmux
+			if err := term.Send(n); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tc.expected {
+				t.Logf("expected %q, got %q", tc.expected, buf.String())
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/hooks/ok" {
+			http.Error(w, "unknown hook", http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	n := Notification{Event: Crashed, Process: "server", Detail: "exit 2"}
+	if err := NewWebhook(srv.URL + "/hooks/ok").Send(n); err != nil {
+		t.Fatal(err)
+	}
+	if got["text"] != "server crashed (exit 2)" {
+		t.Logf("expected the message as text, got %v", got)
+		t.Fail()
+	}
+	if err := NewWebhook(srv.URL + "/hooks/missing").Send(n); err == nil {
+		t.Log("expected an error for a failed post")
+		t.Fail()
+	}
+}
+
+func TestCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	n := Notification{Event: BuildFailed, Process: "webapp", Detail: "syntax error"}
+	if err := NewCommand(`echo "$MMDEV_EVENT|$MMDEV_PROCESS|$MMDEV_DETAIL|$MMDEV_MESSAGE" > ` + out).Send(n); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "build_failed|webapp|syntax error|webapp build failed: syntax error\n"
+	if string(data) != expected {
+		t.Logf("expected %q, got %q", expected, data)
+		t.Fail()
+	}
+
+	err = NewCommand("echo broken >&2; exit 3").Send(n)
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Logf("expected the failure with its output, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opts     Options
+		expected string
+		err      bool
+	}{
+		"all events": {
+			opts:     Options{Bell: true},
+			expected: "\a\a",
+		},
+		"filtered events": {
+			opts:     Options{Bell: true, Events: []string{"recovered"}},
+			expected: "\a",
+		},
+		"no targets": {
+			opts: Options{},
+		},
+		"unknown event": {
+			opts: Options{Bell: true, Events: []string{"exploded"}},
+			err:  true,
+		},
+		"invalid webhook": {
+			opts: Options{Webhook: "localhost:8065/hooks/x"},
+			err:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := New(tc.opts, &buf)
+			if tc.err {
+				if err == nil {
+					t.Log("expected an error")
+					t.Fail()
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, event := range []Event{Crashed, Recovered} {
+				if err := n.Send(Notification{Event: event, Process: "server"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if buf.String() != tc.expected {
+				t.Logf("expected %q, got %q", tc.expected, buf.String())
+				t.Fail()
+			}
+		})
+	}
+}
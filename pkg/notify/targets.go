@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sendTimeout limits the webhook requests and commands
+const sendTimeout = 30 * time.Second
+
+// Terminal rings the bell and shows desktop notifications with the OSC 9
+// escape sequence, understood by iTerm2, kitty, WezTerm, Windows Terminal
+// and others. Inside tmux the bell marks the window, and OSC 9 is passed
+// through to the outer terminal when tmux allows it.
+type Terminal struct {
+	mu   sync.Mutex
+	w    io.Writer
+	bell bool
+	osc9 bool
+	tmux bool
+}
+
+// NewTerminal creates a target writing to the terminal
+func NewTerminal(w io.Writer, bell, osc9 bool) *Terminal {
+	return &Terminal{w: w, bell: bell, osc9: osc9, tmux: os.Getenv("TMUX") != ""}
+}
+
+func (t *Terminal) Send(n Notification) error {
+	var seq strings.Builder
+	if t.osc9 {
+		osc := "\x1b]9;mmdev: " + sanitize(n.Message()) + "\x07"
+		if t.tmux {
+			// The escape characters of the wrapped sequence are doubled
+			osc = "\x1bPtmux;" + strings.ReplaceAll(osc, "\x1b", "\x1b\x1b") + "\x1b\\"
+		}
+		seq.WriteString(osc)
+	}
+	if t.bell {
+		seq.WriteString("\a")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// A single write, so it isn't split by the output of others
+	if _, err := io.WriteString(t.w, seq.String()); err != nil {
+		return fmt.Errorf("failed to notify the terminal: %w", err)
+	}
+	return nil
+}
+
+// sanitize keeps a message in a single line without control characters,
+// which would end the escape sequence
+func sanitize(message string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			return ' '
+		case r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0):
+			return -1
+		}
+		return r
+	}, message)
+}
+
+// Webhook posts the notifications as {"text": message}, the payload of the
+// Mattermost and Slack incoming webhooks
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook creates a target posting to the URL
+func NewWebhook(url string) *Webhook {
+	return &Webhook{url: url, client: &http.Client{Timeout: sendTimeout}}
+}
+
+func (w *Webhook) Send(n Notification) error {
+	body, err := json.Marshal(map[string]string{
+		"text": n.Message(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode the webhook payload: %w", err)
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post to the webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered with status %s", resp.Status)
+	}
+	return nil
+}
+
+// Command runs a shell command per notification, with the details in the
+// MMDEV_EVENT, MMDEV_PROCESS, MMDEV_DETAIL and MMDEV_MESSAGE environment
+// variables
+type Command struct {
+	run string
+}
+
+// NewCommand creates a target running the command line with sh -c
+func NewCommand(run string) *Command {
+	return &Command{run: run}
+}
+
+func (c *Command) Send(n Notification) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.run)
+	cmd.Env = append(os.Environ(),
+		"MMDEV_EVENT="+string(n.Event),
+		"MMDEV_PROCESS="+n.Process,
+		"MMDEV_DETAIL="+n.Detail,
+		"MMDEV_MESSAGE="+n.Message(),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("notification command failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
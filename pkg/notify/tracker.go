@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"sync"
+	"time"
+)
+
+// Tracker follows the state of the processes to notify only the changes: the
+// first failure, and the recovery once the process is ready again. Crash
+// loops and builds failing again don't notify every time.
+type Tracker struct {
+	mu      sync.Mutex
+	failing map[string]bool
+}
+
+// NewTracker creates a tracker with all the processes healthy
+func NewTracker() *Tracker {
+	return &Tracker{failing: make(map[string]bool)}
+}
+
+// BuildFailed records a failed build, returning the notification unless the
+// process was already failing
+func (t *Tracker) BuildFailed(process string, err error) (Notification, bool) {
+	detail := ""
+	if err != nil {
+		detail = err.Error()
+	}
+	return t.fail(Notification{Event: BuildFailed, Process: process, Detail: detail})
+}
+
+// Crashed records an unexpected exit, returning the notification unless the
+// process was already failing
+func (t *Tracker) Crashed(process, status string) (Notification, bool) {
+	return t.fail(Notification{Event: Crashed, Process: process, Detail: status})
+}
+
+func (t *Tracker) fail(n Notification) (Notification, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failing[n.Process] {
+		return Notification{}, false
+	}
+	t.failing[n.Process] = true
+	n.Time = time.Now()
+	return n, true
+}
+
+// Ready records that the process works, returning the recovery notification
+// when it was failing
+func (t *Tracker) Ready(process string) (Notification, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.failing[process] {
+		return Notification{}, false
+	}
+	delete(t.failing, process)
+	return Notification{Event: Recovered, Process: process, Time: time.Now()}, true
+}
+
+// Reset forgets the failure of a process stopped on purpose, so it doesn't
+// notify a recovery
+func (t *Tracker) Reset(process string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.failing, process)
+}
@@ -5,12 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"slices"
 	"strings"
 	"time"
 
+	"github.com/jespino/mmdev/pkg/mmlog"
 	"github.com/mattermost/mattermost/server/public/model"
 )
 
@@ -21,7 +21,7 @@ const (
 
 // logs fetches the latest 500 log entries from Mattermost,
 // and prints only the ones related to the plugin to stdout.
-func logs(ctx context.Context, client *model.Client4, pluginID string) error {
+func logs(ctx context.Context, client *model.Client4, pluginID string, opts mmlog.Options) error {
 	err := checkJSONLogsSetting(ctx, client)
 	if err != nil {
 		return err
@@ -32,7 +32,7 @@ func logs(ctx context.Context, client *model.Client4, pluginID string) error {
 		return fmt.Errorf("failed to fetch log entries: %w", err)
 	}
 
-	err = printLogEntries(logs)
+	err = printLogEntries(mmlog.NewRenderer(os.Stdout, opts), logs)
 	if err != nil {
 		return fmt.Errorf("failed to print logs entries: %w", err)
 	}
@@ -42,12 +42,14 @@ func logs(ctx context.Context, client *model.Client4, pluginID string) error {
 
 // watchLogs fetches log entries from Mattermost and print them to stdout.
 // It will return without an error when ctx is canceled.
-func watchLogs(ctx context.Context, client *model.Client4, pluginID string) error {
+func watchLogs(ctx context.Context, client *model.Client4, pluginID string, opts mmlog.Options) error {
 	err := checkJSONLogsSetting(ctx, client)
 	if err != nil {
 		return err
 	}
 
+	renderer := mmlog.NewRenderer(os.Stdout, opts)
+
 	now := time.Now()
 	var oldestEntry string
 
@@ -68,7 +70,7 @@ func watchLogs(ctx context.Context, client *model.Client4, pluginID string) erro
 				var allNew bool
 				logs, oldestEntry, allNew = checkOldestEntry(logs, oldestEntry)
 
-				err = printLogEntries(logs)
+				err = printLogEntries(renderer, logs)
 				if err != nil {
 					return fmt.Errorf("failed to print logs entries: %w", err)
 				}
@@ -160,10 +162,10 @@ func filterLogEntries(logs []string, pluginID string, since time.Time) ([]string
 	return ret, nil
 }
 
-// printLogEntries prints a slice of log entries to stdout.
-func printLogEntries(entries []string) error {
+// printLogEntries renders a slice of log entries.
+func printLogEntries(renderer *mmlog.Renderer, entries []string) error {
 	for _, e := range entries {
-		_, err := io.WriteString(os.Stdout, e+"\n")
+		err := renderer.WriteLine(e)
 		if err != nil {
 			return fmt.Errorf("failed to write log entry to stdout: %w", err)
 		}
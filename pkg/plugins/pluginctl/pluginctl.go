@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 
+	"github.com/jespino/mmdev/pkg/mmlog"
 	"github.com/mattermost/mattermost/server/public/model"
 )
 
@@ -79,13 +80,13 @@ func (c *Client) Reset(ctx context.Context, pluginID string) error {
 }
 
 // GetLogs fetches and filters plugin logs
-func (c *Client) GetLogs(ctx context.Context, pluginID string) error {
-	return logs(ctx, c.client, pluginID)
+func (c *Client) GetLogs(ctx context.Context, pluginID string, opts mmlog.Options) error {
+	return logs(ctx, c.client, pluginID, opts)
 }
 
 // WatchLogs continuously fetches and displays plugin logs
-func (c *Client) WatchLogs(ctx context.Context, pluginID string) error {
-	return watchLogs(ctx, c.client, pluginID)
+func (c *Client) WatchLogs(ctx context.Context, pluginID string, opts mmlog.Options) error {
+	return watchLogs(ctx, c.client, pluginID, opts)
 }
 
 // NewPlugin creates a new plugin from the starter template
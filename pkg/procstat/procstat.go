@@ -0,0 +1,122 @@
+package procstat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is the unit of the CPU times in /proc, USER_HZ, which is 100 on
+// all the supported Linux architectures
+const clockTicks = 100
+
+// Usage is the resources used by a process and its descendants
+type Usage struct {
+	// CPU is the total CPU time consumed
+	CPU time.Duration
+	// RSS is the resident memory in bytes
+	RSS int64
+	// Processes is the number of processes in the tree
+	Processes int
+}
+
+// stat is the part of /proc/<pid>/stat used to compute the usage
+type stat struct {
+	pid   int
+	ppid  int
+	ticks uint64
+	rss   int64
+}
+
+// Tree returns the resources used by the process pid and all its
+// descendants. It's only supported on systems with a Linux /proc.
+func Tree(pid int) (Usage, error) {
+	return tree("/proc", pid)
+}
+
+func tree(procDir string, pid int) (Usage, error) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to read %s: %w", procDir, err)
+	}
+
+	stats := make(map[int]stat)
+	children := make(map[int][]int)
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(procDir, entry.Name(), "stat"))
+		if err != nil {
+			// The process exited while listing them
+			continue
+		}
+		s, err := parseStat(string(data))
+		if err != nil {
+			continue
+		}
+		stats[s.pid] = s
+		children[s.ppid] = append(children[s.ppid], s.pid)
+	}
+
+	if _, ok := stats[pid]; !ok {
+		return Usage{}, fmt.Errorf("process %d not found", pid)
+	}
+
+	var usage Usage
+	var ticks uint64
+	pending := []int{pid}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = append(pending[1:], children[current]...)
+		s := stats[current]
+		ticks += s.ticks
+		usage.RSS += s.rss * int64(os.Getpagesize())
+		usage.Processes++
+	}
+	usage.CPU = time.Duration(ticks) * time.Second / clockTicks
+	return usage, nil
+}
+
+// parseStat parses the content of /proc/<pid>/stat. The command name is
+// between parentheses and may contain spaces, so the fields are counted
+// from the last closing parenthesis.
+func parseStat(content string) (stat, error) {
+	open := strings.IndexByte(content, '(')
+	end := strings.LastIndexByte(content, ')')
+	if open < 0 || end < open {
+		return stat{}, fmt.Errorf("invalid stat: %q", content)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(content[:open]))
+	if err != nil {
+		return stat{}, fmt.Errorf("invalid pid: %w", err)
+	}
+
+	// Fields after the command, starting with the state (field 3)
+	fields := strings.Fields(content[end+1:])
+	if len(fields) < 22 {
+		return stat{}, fmt.Errorf("invalid stat: %q", content)
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return stat{}, fmt.Errorf("invalid ppid: %w", err)
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return stat{}, fmt.Errorf("invalid utime: %w", err)
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return stat{}, fmt.Errorf("invalid stime: %w", err)
+	}
+	rss, err := strconv.ParseInt(fields[21], 10, 64)
+	if err != nil {
+		return stat{}, fmt.Errorf("invalid rss: %w", err)
+	}
+
+	return stat{pid: pid, ppid: ppid, ticks: utime + stime, rss: rss}, nil
+}
@@ -0,0 +1,97 @@
+package procstat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeStat creates a fake /proc/<pid>/stat with the given ppid, utime,
+// stime and rss
+func writeStat(t *testing.T, procDir string, pid int, comm string, ppid int, utime, stime, rss int) {
+	t.Helper()
+	dir := filepath.Join(procDir, fmt.Sprint(pid))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := fmt.Sprintf("%d (%s) S %d %d %d 0 -1 4194560 100 0 0 0 %d %d 0 0 20 0 1 0 100 1000000 %d 18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n",
+		pid, comm, ppid, pid, pid, utime, stime, rss)
+	if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTree(t *testing.T) {
+	procDir := t.TempDir()
+	writeStat(t, procDir, 1, "init", 0, 500, 500, 100)
+	writeStat(t, procDir, 10, "mmdev", 1, 100, 50, 10)
+	writeStat(t, procDir, 11, "go build (x)", 10, 200, 50, 20)
+	writeStat(t, procDir, 12, "mattermost", 11, 100, 0, 30)
+	writeStat(t, procDir, 20, "other", 1, 900, 0, 40)
+	os.MkdirAll(filepath.Join(procDir, "self"), 0755)
+
+	page := int64(os.Getpagesize())
+	for name, tc := range map[string]struct {
+		pid      int
+		expected Usage
+		err      bool
+	}{
+		"tree": {
+			pid:      10,
+			expected: Usage{CPU: 5 * time.Second, RSS: 60 * page, Processes: 3},
+		},
+		"leaf": {
+			pid:      12,
+			expected: Usage{CPU: time.Second, RSS: 30 * page, Processes: 1},
+		},
+		"missing": {
+			pid: 99,
+			err: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			usage, err := tree(procDir, tc.pid)
+			if (err != nil) != tc.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if usage != tc.expected {
+				t.Logf("expected %+v, got %+v", tc.expected, usage)
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestParseStat(t *testing.T) {
+	for name, tc := range map[string]struct {
+		content  string
+		expected stat
+		err      bool
+	}{
+		"command with spaces and parentheses": {
+			content:  "42 (a) b (c)) S 7 42 42 0 -1 4194560 100 0 0 0 15 5 0 0 20 0 1 0 100 1000000 250 18446744073709551615",
+			expected: stat{pid: 42, ppid: 7, ticks: 20, rss: 250},
+		},
+		"truncated": {
+			content: "42 (a) S 7 42",
+			err:     true,
+		},
+		"garbage": {
+			content: "not a stat",
+			err:     true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, err := parseStat(tc.content)
+			if (err != nil) != tc.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s != tc.expected {
+				t.Logf("expected %+v, got %+v", tc.expected, s)
+				t.Fail()
+			}
+		})
+	}
+}
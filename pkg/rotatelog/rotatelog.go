@@ -0,0 +1,93 @@
+package rotatelog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Writer appends to a log file, renaming it to path.1, path.2... once it
+// reaches the maximum size and keeping at most maxFiles of them
+type Writer struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+// Open opens the log file for appending, creating its directory if needed.
+// A maxSize of zero disables the rotation.
+func Open(path string, maxSize int64, maxFiles int) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	w := &Writer{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Path returns the path of the current log file
+func (w *Writer) Path() string {
+	return w.path
+}
+
+// Write writes p to the log file, rotating it first if it doesn't fit
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close closes the log file
+func (w *Writer) Close() error {
+	return w.file.Close()
+}
+
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", w.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat %s: %w", w.path, err)
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// rotate shifts the rotated files, dropping the oldest, and starts a new
+// log file
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", w.path, err)
+	}
+
+	if w.maxFiles > 0 {
+		os.Remove(w.rotated(w.maxFiles))
+		for i := w.maxFiles - 1; i > 0; i-- {
+			os.Rename(w.rotated(i), w.rotated(i+1))
+		}
+		if err := os.Rename(w.path, w.rotated(1)); err != nil {
+			return fmt.Errorf("failed to rotate %s: %w", w.path, err)
+		}
+	} else if err := os.Remove(w.path); err != nil {
+		return fmt.Errorf("failed to rotate %s: %w", w.path, err)
+	}
+
+	return w.open()
+}
+
+func (w *Writer) rotated(n int) string {
+	return fmt.Sprintf("%s.%d", w.path, n)
+}
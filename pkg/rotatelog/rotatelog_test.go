@@ -0,0 +1,79 @@
+package rotatelog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	for name, tc := range map[string]struct {
+		maxSize  int64
+		maxFiles int
+		writes   []string
+		expected map[string]string
+	}{
+		"no rotation": {
+			maxSize:  0,
+			maxFiles: 2,
+			writes:   []string{"one\n", "two\n", "three\n"},
+			expected: map[string]string{"server.log": "one\ntwo\nthree\n"},
+		},
+		"rotates when full": {
+			maxSize:  8,
+			maxFiles: 2,
+			writes:   []string{"one\n", "two\n", "three\n"},
+			expected: map[string]string{"server.log": "three\n", "server.log.1": "one\ntwo\n"},
+		},
+		"drops the oldest": {
+			maxSize:  4,
+			maxFiles: 2,
+			writes:   []string{"one\n", "two\n", "six\n", "ten\n"},
+			expected: map[string]string{"server.log": "ten\n", "server.log.1": "six\n", "server.log.2": "two\n"},
+		},
+		"without rotated files": {
+			maxSize:  4,
+			maxFiles: 0,
+			writes:   []string{"one\n", "two\n"},
+			expected: map[string]string{"server.log": "two\n"},
+		},
+		"line longer than the size": {
+			maxSize:  4,
+			maxFiles: 1,
+			writes:   []string{"a very long line\n"},
+			expected: map[string]string{"server.log": "a very long line\n"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := Open(filepath.Join(dir, "server.log"), tc.maxSize, tc.maxFiles)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, line := range tc.writes {
+				if _, err := w.Write([]byte(line)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			w.Close()
+
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != len(tc.expected) {
+				var names []string
+				for _, entry := range entries {
+					names = append(names, entry.Name())
+				}
+				t.Logf("expected %d files, got %s", len(tc.expected), strings.Join(names, ", "))
+				t.Fail()
+			}
+			for file, content := range tc.expected {
+				data, err := os.ReadFile(filepath.Join(dir, file))
+				if err != nil || string(data) != content {
+					t.Logf("expected %s to contain %q, got %q (%v)", file, content, data, err)
+					t.Fail()
+				}
+			}
+		})
+	}
+}
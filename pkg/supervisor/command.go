@@ -0,0 +1,170 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// stopTimeout is how long a program has to exit after SIGTERM before being
+// killed
+const stopTimeout = 10 * time.Second
+
+// pollInterval is how often the ready URL of a process is checked
+const pollInterval = time.Second
+
+var pollClient = &http.Client{Timeout: time.Second}
+
+// Command is a process running a program
+type Command struct {
+	Args []string
+	Dir  string
+	Env  []string
+	// RestartSignal, when set, is sent to restart the program in place
+	RestartSignal syscall.Signal
+	// BuildingPattern and ReadyPattern report the program as building or
+	// ready when an output line matches them
+	BuildingPattern *regexp.Regexp
+	ReadyPattern    *regexp.Regexp
+	// ReadyURL reports the program as ready when it answers with a 2xx
+	ReadyURL string
+
+	mu  sync.Mutex
+	cmd *exec.Cmd
+}
+
+// Run runs the program until it exits or ctx is cancelled
+func (c *Command) Run(ctx context.Context, r *Reporter) error {
+	if len(c.Args) == 0 {
+		return fmt.Errorf("no command to run")
+	}
+	cmd := exec.Command(c.Args[0], c.Args[1:]...)
+	cmd.Dir = c.Dir
+	cmd.Env = append(os.Environ(), c.Env...)
+	cmd.Stdout = r.Output()
+	cmd.Stderr = r.Output()
+	// Don't wait forever for the output of children left behind
+	cmd.WaitDelay = stopTimeout
+
+	r.Watch(Patterns{Building: c.BuildingPattern, Ready: c.ReadyPattern})
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", strings.Join(c.Args, " "), err)
+	}
+	c.mu.Lock()
+	c.cmd = cmd
+	c.mu.Unlock()
+	r.Running(cmd.Process.Pid)
+
+	switch {
+	case c.ReadyURL != "":
+		go r.PollReady(ctx, c.ReadyURL)
+	case c.ReadyPattern == nil:
+		r.Ready()
+	}
+	return Wait(ctx, cmd)
+}
+
+// Restart sends the restart signal to the program, when there is one
+func (c *Command) Restart() error {
+	if c.RestartSignal == 0 {
+		return ErrRestartUnsupported
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cmd == nil || c.cmd.Process == nil {
+		return fmt.Errorf("not running")
+	}
+	return c.cmd.Process.Signal(c.RestartSignal)
+}
+
+// Wait waits for a started program to exit. When ctx is cancelled the
+// program is sent SIGTERM, and killed if it doesn't exit in time or the
+// supervisor running it is killed.
+func Wait(ctx context.Context, cmd *exec.Cmd) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Nil, blocking forever, outside of a supervisor
+	killed, _ := ctx.Value(killedKey{}).(chan struct{})
+	select {
+	case <-killed:
+		cmd.Process.Kill()
+		return <-done
+	default:
+	}
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		cmd.Process.Kill()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-killed:
+	case <-time.After(stopTimeout):
+	}
+	cmd.Process.Kill()
+	return <-done
+}
+
+// ExitStatus describes how the program that made a run fail ended, like
+// "exit 2", empty when the error isn't about its exit
+func ExitStatus(err error) string {
+	var exitErr *exec.ExitError
+	switch {
+	case !errors.As(err, &exitErr):
+		return ""
+	case exitErr.ExitCode() >= 0:
+		return fmt.Sprintf("exit %d", exitErr.ExitCode())
+	default:
+		return exitErr.String()
+	}
+}
+
+// PollReady checks the URL until ctx is cancelled, reporting the process as
+// ready when it answers with a 2xx, again after every restart
+func (r *Reporter) PollReady(ctx context.Context, url string) {
+	r.mu.Lock()
+	r.polling = true
+	r.mu.Unlock()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if ping(ctx, url) {
+			r.Ready()
+		}
+	}
+}
+
+func ping(ctx context.Context, url string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := pollClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
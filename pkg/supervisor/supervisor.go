@@ -0,0 +1,487 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/jespino/mmdev/pkg/mmlog"
+)
+
+// EventType is the kind of an Event
+type EventType int
+
+const (
+	// Output is a line written by the process
+	Output EventType = iota
+	// Starting is sent when a run of the process begins
+	Starting
+	// Running is sent when the process launched its program, with its pid
+	Running
+	// Building is sent when the process starts compiling
+	Building
+	// BuildFailed is sent when the compilation fails, with the error
+	BuildFailed
+	// Ready is sent when the process is ready to be used
+	Ready
+	// Restarting is sent when the process restarts without exiting
+	Restarting
+	// Exited is sent when the run ends, with the error it ended with
+	Exited
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Output:
+		return "output"
+	case Starting:
+		return "starting"
+	case Running:
+		return "running"
+	case Building:
+		return "building"
+	case BuildFailed:
+		return "build failed"
+	case Ready:
+		return "ready"
+	case Restarting:
+		return "restarting"
+	case Exited:
+		return "exited"
+	default:
+		return fmt.Sprintf("event %d", int(t))
+	}
+}
+
+// Event is something that happened to a supervised process
+type Event struct {
+	Process string
+	Type    EventType
+	Time    time.Time
+	// Run counts the runs of the process, starting at 1
+	Run int
+	// Line is the line of Output events
+	Line string
+	// Pid is the pid of the program of Running events
+	Pid int
+	// Err is the error of BuildFailed and Exited events
+	Err error
+	// Stopped is set in Exited events when the process was stopped by the
+	// supervisor
+	Stopped bool
+	// Status tells how the program of an Exited event ended, like "exit 1"
+	// or "signal: killed", empty when unknown
+	Status string
+}
+
+// Process is something run by the supervisor, like a server or a command
+type Process interface {
+	// Run runs the process until it ends or ctx is cancelled, reporting its
+	// output and state through r
+	Run(ctx context.Context, r *Reporter) error
+}
+
+// Restarter is implemented by the processes that can restart in place,
+// without ending the run. Restart returns ErrRestartUnsupported to be
+// stopped and started again instead.
+type Restarter interface {
+	Restart() error
+}
+
+// ErrRestartUnsupported is returned by a Restarter that can't restart in
+// place
+var ErrRestartUnsupported = errors.New("restart in place not supported")
+
+// Supervisor runs processes, reporting what happens to them as events
+type Supervisor struct {
+	mu        sync.Mutex
+	processes map[string]*managed
+	order     []string
+	events    chan Event
+	closed    bool
+	// killed is closed to kill the programs waited with Wait right away
+	killed   chan struct{}
+	killOnce sync.Once
+}
+
+// killedKey is the context key of the killed channel of the supervisor
+type killedKey struct{}
+
+type managed struct {
+	process Process
+	run     int
+	cancel  context.CancelFunc
+	done    chan struct{}
+	// ready is closed when the process reports it's ready, and replaced on
+	// the next run
+	ready    chan struct{}
+	reporter *Reporter
+}
+
+// New creates a supervisor without processes
+func New() *Supervisor {
+	return &Supervisor{
+		processes: make(map[string]*managed),
+		events:    make(chan Event, 100),
+		killed:    make(chan struct{}),
+	}
+}
+
+// Events returns the channel receiving the events of all the processes. It
+// must be drained, the processes block otherwise.
+func (s *Supervisor) Events() <-chan Event {
+	return s.events
+}
+
+// Add registers a process with a unique name, without starting it
+func (s *Supervisor) Add(name string, process Process) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.processes[name]; ok {
+		return fmt.Errorf("process %q already exists", name)
+	}
+	s.processes[name] = &managed{process: process, ready: make(chan struct{})}
+	s.order = append(s.order, name)
+	return nil
+}
+
+func (s *Supervisor) get(name string) (*managed, error) {
+	m, ok := s.processes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown process %q", name)
+	}
+	return m, nil
+}
+
+// Running checks if the process is running
+func (s *Supervisor) Running(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.get(name)
+	return err == nil && m.running()
+}
+
+func (m *managed) running() bool {
+	if m.done == nil {
+		return false
+	}
+	select {
+	case <-m.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Start starts a run of the process, unless it's already running
+func (s *Supervisor) Start(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("the supervisor is shutting down")
+	}
+	m, err := s.get(name)
+	if err != nil {
+		return err
+	}
+	if m.running() {
+		return fmt.Errorf("process %q is already running", name)
+	}
+
+	m.run++
+	select {
+	case <-m.ready:
+		m.ready = make(chan struct{})
+	default:
+	}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), killedKey{}, s.killed))
+	done := make(chan struct{})
+	m.cancel = cancel
+	m.done = done
+
+	r := &Reporter{s: s, name: name, run: m.run, ready: m.ready}
+	m.reporter = r
+	go func() {
+		defer close(done)
+		defer cancel()
+		r.emit(Event{Type: Starting})
+		err := m.process.Run(ctx, r)
+		r.flush()
+		r.emit(Event{Type: Exited, Err: err, Stopped: ctx.Err() != nil, Status: ExitStatus(err)})
+	}()
+	return nil
+}
+
+// Stop asks the process to stop, without waiting for it
+func (s *Supervisor) Stop(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.get(name)
+	if err != nil {
+		return err
+	}
+	if m.running() {
+		m.cancel()
+	}
+	return nil
+}
+
+// Restart restarts the process in place when it supports it, otherwise
+// stops it and starts a new run once it ends. A stopped process is started.
+func (s *Supervisor) Restart(name string) error {
+	s.mu.Lock()
+	m, err := s.get(name)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	if !m.running() {
+		s.mu.Unlock()
+		return s.Start(name)
+	}
+	defer s.mu.Unlock()
+
+	if restarter, ok := m.process.(Restarter); ok {
+		err := restarter.Restart()
+		if err == nil {
+			go m.reporter.restarted()
+			return nil
+		}
+		if !errors.Is(err, ErrRestartUnsupported) {
+			return fmt.Errorf("failed to restart %s: %w", name, err)
+		}
+	}
+
+	m.cancel()
+	done := m.done
+	go func() {
+		<-done
+		// Fails only when shutting down or started meanwhile
+		s.Start(name)
+	}()
+	return nil
+}
+
+// WaitReady waits until the process reports it's ready. It returns right
+// away when there is no such process.
+func (s *Supervisor) WaitReady(ctx context.Context, name string) error {
+	s.mu.Lock()
+	m, ok := s.processes[name]
+	var ready chan struct{}
+	if ok {
+		ready = m.ready
+	}
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Names returns the names of the processes, in the order they were added
+func (s *Supervisor) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.order...)
+}
+
+// Has checks if a process was added with the given name
+func (s *Supervisor) Has(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.processes[name]
+	return ok
+}
+
+// Shutdown stops all the processes, in the reverse order they were added,
+// and waits for them to end. No process can be started afterwards.
+func (s *Supervisor) Shutdown() {
+	s.mu.Lock()
+	s.closed = true
+	var running []*managed
+	for i := len(s.order) - 1; i >= 0; i-- {
+		if m := s.processes[s.order[i]]; m.running() {
+			running = append(running, m)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, m := range running {
+		m.cancel()
+		<-m.done
+	}
+}
+
+// Kill stops all the processes like Shutdown, but the programs waited with
+// Wait are killed right away instead of given time to exit. It also hurries
+// a Shutdown in progress.
+func (s *Supervisor) Kill() {
+	s.killOnce.Do(func() {
+		close(s.killed)
+	})
+	s.Shutdown()
+}
+
+// Reporter is how a process reports its output and state during a run
+type Reporter struct {
+	s     *Supervisor
+	name  string
+	run   int
+	ready chan struct{}
+
+	mu       sync.Mutex
+	partial  []byte
+	patterns Patterns
+	polling  bool
+	isReady  bool
+}
+
+// Patterns report the state of a process from its output lines, compared
+// without colors. Any of them can be nil.
+type Patterns struct {
+	Building    *regexp.Regexp
+	Ready       *regexp.Regexp
+	BuildFailed *regexp.Regexp
+}
+
+func (r *Reporter) emit(event Event) {
+	event.Process = r.name
+	event.Run = r.run
+	event.Time = time.Now()
+	r.s.events <- event
+}
+
+// Watch makes the output lines matching the patterns report the state of
+// the process
+func (r *Reporter) Watch(patterns Patterns) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.patterns = patterns
+}
+
+// Output returns a writer reporting every line written to it, safe to use
+// from several goroutines
+func (r *Reporter) Output() io.Writer {
+	return reporterOutput{r}
+}
+
+type reporterOutput struct {
+	r *Reporter
+}
+
+func (o reporterOutput) Write(p []byte) (int, error) {
+	r := o.r
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.partial = append(r.partial, p...)
+	for {
+		i := bytes.IndexByte(r.partial, '\n')
+		if i < 0 {
+			break
+		}
+		r.line(string(bytes.TrimSuffix(r.partial[:i], []byte("\r"))))
+		r.partial = r.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// line reports a line of output, and the state it reveals. Called with the
+// lock held.
+func (r *Reporter) line(text string) {
+	r.emit(Event{Type: Output, Line: text})
+	patterns := r.patterns
+	if patterns.Building == nil && patterns.Ready == nil && patterns.BuildFailed == nil {
+		return
+	}
+	plain := mmlog.StripANSI(text)
+	switch {
+	case patterns.BuildFailed != nil && patterns.BuildFailed.MatchString(plain):
+		r.isReady = false
+		r.emit(Event{Type: BuildFailed, Err: errors.New(plain)})
+	case patterns.Ready != nil && patterns.Ready.MatchString(plain):
+		r.setReady()
+	case patterns.Building != nil && patterns.Building.MatchString(plain):
+		r.isReady = false
+		r.emit(Event{Type: Building})
+	}
+}
+
+// flush reports the last line when it didn't end with a newline
+func (r *Reporter) flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.partial) > 0 {
+		r.line(string(r.partial))
+		r.partial = nil
+	}
+}
+
+// Printf reports a line of output
+func (r *Reporter) Printf(format string, args ...any) {
+	fmt.Fprintf(r.Output(), format+"\n", args...)
+}
+
+// Running reports the pid of the program launched by the process
+func (r *Reporter) Running(pid int) {
+	r.emit(Event{Type: Running, Pid: pid})
+}
+
+// Building reports the process started compiling
+func (r *Reporter) Building() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.isReady = false
+	r.emit(Event{Type: Building})
+}
+
+// restarted reports the process restarted in place, so it isn't ready
+// until it reports it again, or right away when nothing would report it
+func (r *Reporter) restarted() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.isReady = false
+	r.emit(Event{Type: Restarting})
+	if r.patterns.Ready == nil && !r.polling {
+		r.setReady()
+	}
+}
+
+// BuildFailed reports the compilation failed
+func (r *Reporter) BuildFailed(err error) {
+	r.emit(Event{Type: BuildFailed, Err: err})
+}
+
+// Ready reports the process is ready to be used
+func (r *Reporter) Ready() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.setReady()
+}
+
+// setReady reports the process is ready unless it already was. Called with
+// the lock held.
+func (r *Reporter) setReady() {
+	if r.isReady {
+		return
+	}
+	r.isReady = true
+	r.emit(Event{Type: Ready})
+	if r.ready != nil {
+		select {
+		case <-r.ready:
+		default:
+			close(r.ready)
+		}
+	}
+}
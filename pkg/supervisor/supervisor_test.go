@@ -0,0 +1,201 @@
+package supervisor
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// collect returns the events of a process until its run ends, as
+// "type[:line]" strings
+func collect(t *testing.T, s *Supervisor, name string) []string {
+	var events []string
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-s.Events():
+			if event.Process != name {
+				continue
+			}
+			switch event.Type {
+			case Output:
+				events = append(events, "output:"+event.Line)
+			case Exited:
+				if event.Stopped {
+					return append(events, "stopped")
+				}
+				if event.Status != "" {
+					return append(events, "exited:"+event.Err.Error()+" ("+event.Status+")")
+				}
+				if event.Err != nil {
+					return append(events, "exited:"+event.Err.Error())
+				}
+				return append(events, "exited")
+			default:
+				events = append(events, event.Type.String())
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for %s to exit, got %v", name, events)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	for name, tc := range map[string]struct {
+		command  *Command
+		expected []string
+	}{
+		"ready once running": {
+			command:  &Command{Args: []string{"sh", "-c", "echo hello; printf world"}},
+			expected: []string{"starting", "running", "ready", "output:hello", "output:world", "exited"},
+		},
+		"ready and building patterns": {
+			command: &Command{
+				Args:            []string{"sh", "-c", "echo Compiling...; echo '\x1b[32mcompiled\x1b[0m successfully'"},
+				BuildingPattern: regexp.MustCompile(`^Compiling`),
+				ReadyPattern:    regexp.MustCompile(`^compiled successfully`),
+			},
+			expected: []string{"starting", "running", "output:Compiling...", "building", "output:\x1b[32mcompiled\x1b[0m successfully", "ready", "exited"},
+		},
+		"failure": {
+			command:  &Command{Args: []string{"sh", "-c", "echo oops >&2; exit 3"}},
+			expected: []string{"starting", "running", "ready", "output:oops", "exited:exit status 3 (exit 3)"},
+		},
+		"missing program": {
+			command:  &Command{Args: []string{"mmdev-missing-program"}},
+			expected: []string{"starting", "exited:failed to start mmdev-missing-program: exec: \"mmdev-missing-program\": executable file not found in $PATH"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := New()
+			if err := s.Add("test", tc.command); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Start("test"); err != nil {
+				t.Fatal(err)
+			}
+			events := collect(t, s, "test")
+			if strings.Join(events, "\n") != strings.Join(tc.expected, "\n") {
+				t.Logf("expected %q, got %q", tc.expected, events)
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestRestart(t *testing.T) {
+	for name, tc := range map[string]struct {
+		signal   syscall.Signal
+		expected []string
+	}{
+		"in place": {
+			signal:   syscall.SIGUSR1,
+			expected: []string{"restarting", "ready", "output:restarted"},
+		},
+		"new run": {
+			signal:   0,
+			expected: []string{"stopped", "starting", "running", "ready"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := New()
+			s.Add("test", &Command{
+				Args:          []string{"sh", "-c", "trap 'echo restarted' USR1; echo up; while true; do sleep 0.1; done"},
+				RestartSignal: tc.signal,
+			})
+			if err := s.Start("test"); err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			var runs []int
+			for event := range s.Events() {
+				if event.Type == Output && event.Line == "up" {
+					runs = append(runs, event.Run)
+					break
+				}
+			}
+			if err := s.WaitReady(ctx, "test"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := s.Restart("test"); err != nil {
+				t.Fatal(err)
+			}
+			var events []string
+			for len(events) < len(tc.expected) {
+				select {
+				case event := <-s.Events():
+					switch event.Type {
+					case Output:
+						events = append(events, "output:"+event.Line)
+					case Exited:
+						events = append(events, "stopped")
+					default:
+						events = append(events, event.Type.String())
+					}
+					runs = append(runs, event.Run)
+				case <-ctx.Done():
+					t.Fatalf("timeout waiting for the restart, got %v", events)
+				}
+			}
+			s.Shutdown()
+
+			if strings.Join(events, "\n") != strings.Join(tc.expected, "\n") {
+				t.Logf("expected %q, got %q", tc.expected, events)
+				t.Fail()
+			}
+			if tc.signal == 0 && runs[len(runs)-1] != 2 {
+				t.Logf("expected a second run, got runs %v", runs)
+				t.Fail()
+			}
+			if s.Running("test") {
+				t.Log("expected the process to be stopped after the shutdown")
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestKill(t *testing.T) {
+	s := New()
+	// Ignores SIGTERM, without children keeping its output open
+	s.Add("test", &Command{Args: []string{"sh", "-c", "trap '' TERM; echo up; while :; do :; done"}})
+	if err := s.Start("test"); err != nil {
+		t.Fatal(err)
+	}
+	for event := range s.Events() {
+		if event.Type == Output && event.Line == "up" {
+			break
+		}
+	}
+
+	start := time.Now()
+	shutdown := make(chan struct{})
+	go func() {
+		s.Shutdown()
+		close(shutdown)
+	}()
+	time.Sleep(200 * time.Millisecond)
+	select {
+	case <-shutdown:
+		t.Fatal("expected the shutdown to wait for the program ignoring SIGTERM")
+	default:
+	}
+
+	// Hurries the shutdown in progress
+	s.Kill()
+	<-shutdown
+	if elapsed := time.Since(start); elapsed >= stopTimeout {
+		t.Logf("expected the program killed right away, took %s", elapsed)
+		t.Fail()
+	}
+	events := collect(t, s, "test")
+	if last := events[len(events)-1]; last != "stopped" {
+		t.Logf("expected the process stopped, got %q", events)
+		t.Fail()
+	}
+}
@@ -0,0 +1,204 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Tool is a Go tool installed with go install
+type Tool struct {
+	Name    string
+	Package string
+	Version string
+}
+
+// Tools used by mmdev, with the versions used when the Mattermost repository
+// doesn't pin them
+var (
+	Struct2Interface = Tool{Name: "struct2interface", Package: "github.com/reflog/struct2interface", Version: "v0.6.1"}
+	Mockery          = Tool{Name: "mockery", Package: "github.com/vektra/mockery/v2", Version: "v2.42.2"}
+	Mockgen          = Tool{Name: "mockgen", Package: "github.com/golang/mock/mockgen", Version: "v1.6.0"}
+	GolangciLint     = Tool{Name: "golangci-lint", Package: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.57.1"}
+)
+
+// installRegexp matches go install invocations in Makefiles
+var installRegexp = regexp.MustCompile(`install\s+(\S+)@(v[0-9A-Za-z.+\-]+)`)
+
+// Manager installs tools into an mmdev managed directory, one directory per
+// tool and version, so the user's GOPATH/bin is left untouched
+type Manager struct {
+	serverDir string
+	once      sync.Once
+	pinned    map[string]string
+	mu        sync.Mutex
+}
+
+// NewManager creates a tools manager reading pinned versions from the given
+// Mattermost server directory
+func NewManager(serverDir string) *Manager {
+	return &Manager{
+		serverDir: serverDir,
+	}
+}
+
+// Dir returns the directory where tools are installed
+func Dir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "mmdev", "tools"), nil
+}
+
+// Version returns the version of the tool to use, preferring the one pinned
+// by the Mattermost repository
+func (m *Manager) Version(tool Tool) string {
+	m.once.Do(m.loadPinned)
+	if version, ok := m.pinned[tool.Package]; ok {
+		return version
+	}
+	return tool.Version
+}
+
+// Path returns the path to the tool binary, installing it if needed
+func (m *Manager) Path(tool Tool) (string, error) {
+	// Avoid concurrent installs of the same tool
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	version := m.Version(tool)
+	binDir := filepath.Join(dir, tool.Name+"@"+version)
+	binary := filepath.Join(binDir, tool.Name)
+	if _, err := os.Stat(binary); err == nil {
+		return binary, nil
+	}
+	// Left by an interrupted install of an older mmdev
+	os.RemoveAll(binDir)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create tools directory: %w", err)
+	}
+
+	// Install into a temporary directory renamed into place once complete,
+	// so other mmdev processes never see a partial install
+	tmpDir, err := os.MkdirTemp(dir, tool.Name+"@"+version+".tmp-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create tools directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create tools directory: %w", err)
+	}
+
+	// Progress goes to stderr so it doesn't mix with machine readable output
+	fmt.Fprintf(os.Stderr, "Installing %s %s...\n", tool.Name, version)
+	installCmd := exec.Command("go", "install", tool.Package+"@"+version)
+	installCmd.Dir = tmpDir
+	installCmd.Stdout = os.Stderr
+	installCmd.Stderr = os.Stderr
+	installCmd.Env = append(os.Environ(), "GOBIN="+tmpDir, "GOWORK=off")
+	if err := installCmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to install %s: %w", tool.Name, err)
+	}
+
+	if err := os.Rename(tmpDir, binDir); err != nil {
+		// Another process may have installed it meanwhile
+		if _, statErr := os.Stat(binary); statErr == nil {
+			return binary, nil
+		}
+		return "", fmt.Errorf("failed to install %s: %w", tool.Name, err)
+	}
+	return binary, nil
+}
+
+// loadPinned reads the tool versions pinned by the Mattermost repository,
+// from go install calls in the Makefiles and tool directives in go.mod
+func (m *Manager) loadPinned() {
+	m.pinned = make(map[string]string)
+
+	makefiles, _ := filepath.Glob(filepath.Join(m.serverDir, "build", "*.mk"))
+	makefiles = append([]string{filepath.Join(m.serverDir, "Makefile")}, makefiles...)
+	for _, makefile := range makefiles {
+		data, err := os.ReadFile(makefile)
+		if err != nil {
+			continue
+		}
+		for _, match := range installRegexp.FindAllStringSubmatch(string(data), -1) {
+			pkg := strings.TrimSuffix(match[1], "/...")
+			m.pinned[pkg] = match[2]
+		}
+	}
+
+	m.loadGoModTools()
+}
+
+// loadGoModTools reads tool directives from go.mod, taking the versions
+// from the matching require directives
+func (m *Manager) loadGoModTools() {
+	f, err := os.Open(filepath.Join(m.serverDir, "go.mod"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var toolPackages []string
+	requires := make(map[string]string)
+	handle := func(directive string, args []string) {
+		switch {
+		case directive == "tool" && len(args) == 1:
+			toolPackages = append(toolPackages, args[0])
+		case directive == "require" && len(args) >= 2:
+			requires[args[0]] = args[1]
+		}
+	}
+
+	block := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if block != "" {
+			if fields[0] == ")" {
+				block = ""
+				continue
+			}
+			handle(block, fields)
+			continue
+		}
+
+		if len(fields) == 2 && fields[1] == "(" {
+			block = fields[0]
+			continue
+		}
+		handle(fields[0], fields[1:])
+	}
+
+	// A tool belongs to the longest module path containing it
+	for _, pkg := range toolPackages {
+		longest := ""
+		for module, version := range requires {
+			if (pkg == module || strings.HasPrefix(pkg, module+"/")) && len(module) > len(longest) {
+				longest = module
+				m.pinned[pkg] = version
+			}
+		}
+	}
+}
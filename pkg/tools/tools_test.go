@@ -0,0 +1,184 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInstallRegexp(t *testing.T) {
+	for line, expected := range map[string]string{
+		"\t$(GO) install github.com/vektra/mockery/v2/...@v2.40.1":                        "github.com/vektra/mockery/v2/... v2.40.1",
+		"\tGOBIN=$(PWD)/bin go install github.com/golang/mock/mockgen@v1.6.0":             "github.com/golang/mock/mockgen v1.6.0",
+		"go install github.com/golangci/golangci-lint/cmd/golangci-lint@v1.59.0-rc.1+dev": "github.com/golangci/golangci-lint/cmd/golangci-lint v1.59.0-rc.1+dev",
+		"\t$(GO) install github.com/reflog/struct2interface@latest":                       "",
+		"\tnpm install --save-dev eslint@8":                                               "",
+		"\t$(GO) get github.com/mattermost/morph@v1.0.5":                                  "",
+	} {
+		got := ""
+		if match := installRegexp.FindStringSubmatch(line); match != nil {
+			got = match[1] + " " + match[2]
+		}
+		if got != expected {
+			t.Logf("expected %q to match %q, got %q", line, expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestLoadPinned(t *testing.T) {
+	for name, tc := range map[string]struct {
+		files    map[string]string
+		expected map[Tool]string
+	}{
+		"defaults": {
+			files: map[string]string{},
+			expected: map[Tool]string{
+				Mockery:      Mockery.Version,
+				GolangciLint: GolangciLint.Version,
+			},
+		},
+		"makefiles": {
+			files: map[string]string{
+				"Makefile":        "mocks:\n\t$(GO) install github.com/vektra/mockery/v2/...@v2.40.1\n",
+				"build/setup.mk":  "tools:\n\tgo install github.com/golangci/golangci-lint/cmd/golangci-lint@v1.59.0\n",
+				"build/other.txt": "go install github.com/golang/mock/mockgen@v1.5.0\n",
+			},
+			expected: map[Tool]string{
+				Mockery:      "v2.40.1",
+				GolangciLint: "v1.59.0",
+				Mockgen:      Mockgen.Version,
+			},
+		},
+		"go.mod tools": {
+			files: map[string]string{
+				"go.mod": "module github.com/mattermost/mattermost/server/v8\n\n" +
+					"go 1.24\n\n" +
+					"require (\n" +
+					"\tgithub.com/golang/mock v1.6.1 // indirect\n" +
+					"\tgithub.com/vektra/mockery/v2 v2.53.0 // indirect\n" +
+					"\tgithub.com/vektra/mockery v1.1.2\n" +
+					")\n\n" +
+					"require github.com/reflog/struct2interface v0.7.0\n\n" +
+					"tool (\n" +
+					"\tgithub.com/golang/mock/mockgen\n" +
+					"\tgithub.com/vektra/mockery/v2 // mocks\n" +
+					")\n\n" +
+					"tool github.com/reflog/struct2interface\n",
+			},
+			expected: map[Tool]string{
+				Mockgen:          "v1.6.1",
+				Mockery:          "v2.53.0",
+				Struct2Interface: "v0.7.0",
+				GolangciLint:     GolangciLint.Version,
+			},
+		},
+		"go.mod tools take precedence": {
+			files: map[string]string{
+				"Makefile": "\t$(GO) install github.com/golang/mock/mockgen@v1.5.0\n",
+				"go.mod":   "module example.com/server\n\nrequire github.com/golang/mock v1.6.1\n\ntool github.com/golang/mock/mockgen\n",
+			},
+			expected: map[Tool]string{
+				Mockgen: "v1.6.1",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			m := NewManager(dir)
+			for tool, expected := range tc.expected {
+				if version := m.Version(tool); version != expected {
+					t.Logf("expected %s %s, got %s", tool.Name, expected, version)
+					t.Fail()
+				}
+			}
+		})
+	}
+}
+
+func TestPathConcurrentInstalls(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	goDir := t.TempDir()
+	log := filepath.Join(t.TempDir(), "installs")
+	// A fake go install writing the binary slowly, like a real build
+	writeFiles(t, goDir, map[string]string{
+		"go": "#!/bin/sh\necho \"$2\" >> " + log + "\n" +
+			"printf '#!/bin/sh\\n' > \"$GOBIN/mockgen\"\nsleep 0.2\nprintf 'echo mockgen\\n' >> \"$GOBIN/mockgen\"\nchmod +x \"$GOBIN/mockgen\"\n",
+	})
+	t.Setenv("PATH", goDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	// Separate managers, like separate mmdev processes, the second one
+	// starting while the first one is installing
+	var wg sync.WaitGroup
+	var firstErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, firstErr = NewManager(t.TempDir()).Path(Mockgen)
+	}()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(log); err == nil {
+			break
+		}
+	}
+
+	path, err := NewManager(t.TempDir()).Path(Mockgen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "#!/bin/sh\necho mockgen\n" {
+		t.Logf("expected a complete binary at %s, got %q", path, data)
+		t.Fail()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		t.Fatal(firstErr)
+	}
+
+	dir, err := Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "mockgen@"+Mockgen.Version {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Logf("expected only the mockgen install to be left, got %s", strings.Join(names, ", "))
+		t.Fail()
+	}
+
+	// Installed tools are reused
+	os.Remove(log)
+	if _, err := NewManager(t.TempDir()).Path(Mockgen); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(log); !os.IsNotExist(err) {
+		t.Log("expected the installed tool to be reused")
+		t.Fail()
+	}
+}
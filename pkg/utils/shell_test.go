@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	for arg, expected := range map[string]string{
+		"./channels/app":        "./channels/app",
+		"":                      "''",
+		"^TestA$/^sub case$":    "'^TestA$/^sub case$'",
+		"it's":                  `'it'"'"'s'`,
+		"$(rm -rf /)":           "'$(rm -rf /)'",
+		`^with\.\(regex\)$`:     `'^with\.\(regex\)$'`,
+		"github.com/x/y@v1.2.3": "github.com/x/y@v1.2.3",
+	} {
+		quoted := ShellQuote(arg)
+		if quoted != expected {
+			t.Logf("expected ShellQuote(%q) to be %s, got %s", arg, expected, quoted)
+			t.Fail()
+			continue
+		}
+		out, err := exec.Command("sh", "-c", "printf %s "+quoted).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != arg {
+			t.Logf("expected the shell to read %q back, got %q", arg, out)
+			t.Fail()
+		}
+	}
+}
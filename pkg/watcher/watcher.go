@@ -0,0 +1,349 @@
+package watcher
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Options configures which files trigger a change notification
+type Options struct {
+	// Include lists glob patterns for files that trigger a change
+	Include []string
+	// Exclude lists glob patterns for files that never trigger a change
+	Exclude []string
+	// Debounce is the quiet period to wait for before reporting a batch of changes
+	Debounce time.Duration
+}
+
+// Watcher recursively watches a directory tree honoring .gitignore rules
+type Watcher struct {
+	root     string
+	opts     Options
+	fsw      *fsnotify.Watcher
+	ignored  map[string]bool
+	useGit   bool
+	changes  chan []string
+	errors   chan error
+	done     chan struct{}
+	closeErr error
+	once     sync.Once
+}
+
+// New creates a watcher for the given root directory and starts watching it
+func New(root string, opts Options) (*Watcher, error) {
+	if opts.Debounce <= 0 {
+		opts.Debounce = 500 * time.Millisecond
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+
+	w := &Watcher{
+		root:    root,
+		opts:    opts,
+		fsw:     fsw,
+		ignored: make(map[string]bool),
+		changes: make(chan []string, 1),
+		errors:  make(chan error, 1),
+		done:    make(chan struct{}),
+	}
+
+	w.loadGitIgnored()
+
+	if err := w.addTree(root); err != nil {
+		fsw.Close()
+		return nil, fmt.Errorf("failed to add directories to watcher: %w", err)
+	}
+
+	go w.loop()
+	return w, nil
+}
+
+// Changes returns a channel receiving debounced batches of changed files
+func (w *Watcher) Changes() <-chan []string {
+	return w.changes
+}
+
+// Errors returns a channel receiving watcher errors
+func (w *Watcher) Errors() <-chan error {
+	return w.errors
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+		w.closeErr = w.fsw.Close()
+	})
+	return w.closeErr
+}
+
+// loadGitIgnored collects the ignored paths under root using git, which takes
+// care of nested .gitignore files and global excludes for us
+func (w *Watcher) loadGitIgnored() {
+	cmd := exec.Command("git", "ls-files", "--others", "--ignored", "--exclude-standard", "--directory")
+	cmd.Dir = w.root
+	output, err := cmd.Output()
+	if err != nil {
+		// Not a git checkout or git not available, fall back to the default skips
+		return
+	}
+	w.useGit = true
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		path := strings.TrimSuffix(scanner.Text(), "/")
+		if path != "" {
+			w.ignored[filepath.FromSlash(path)] = true
+		}
+	}
+}
+
+// isIgnored checks if a path relative to root is ignored
+func (w *Watcher) isIgnored(rel string, isDir bool) bool {
+	if isDir {
+		base := filepath.Base(rel)
+		if base == ".git" || base == "node_modules" || base == "vendor" {
+			return true
+		}
+	}
+
+	// The path or any of its parents may be in the ignored set
+	for p := rel; p != "." && p != string(filepath.Separator) && p != ""; p = filepath.Dir(p) {
+		if w.ignored[p] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isIgnoredByGit asks git about a path created after the watcher started
+func (w *Watcher) isIgnoredByGit(rel string) bool {
+	if !w.useGit {
+		return false
+	}
+	cmd := exec.Command("git", "check-ignore", "-q", rel)
+	cmd.Dir = w.root
+	return cmd.Run() == nil
+}
+
+// addTree adds a directory and all its non ignored subdirectories to the watcher
+func (w *Watcher) addTree(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// The directory may have vanished while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(w.root, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." && w.isIgnored(rel, true) {
+			return filepath.SkipDir
+		}
+
+		return w.fsw.Add(path)
+	})
+}
+
+// matches checks if a file relative to root passes the include/exclude filters
+func (w *Watcher) matches(rel string) bool {
+	rel = filepath.ToSlash(rel)
+
+	for _, pattern := range w.opts.Exclude {
+		if MatchGlob(pattern, rel) {
+			return false
+		}
+	}
+
+	if len(w.opts.Include) == 0 {
+		return true
+	}
+	for _, pattern := range w.opts.Include {
+		if MatchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// loop handles the events and delivers the batches of changes. Changes made
+// while a batch waits to be received are added to it, so batches arrive in
+// order and the events keep being read meanwhile.
+func (w *Watcher) loop() {
+	var (
+		pending  = make(map[string]bool)
+		batch    []string
+		debounce <-chan time.Time
+	)
+	for {
+		var changes chan<- []string
+		if batch != nil {
+			changes = w.changes
+		}
+
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if rel, ok := w.handleEvent(event); ok {
+				pending[rel] = true
+				debounce = time.After(w.opts.Debounce)
+			}
+		case <-debounce:
+			debounce = nil
+			// Join the batch still waiting to be received, if any
+			for _, file := range batch {
+				pending[file] = true
+			}
+			batch = w.batch(pending)
+			pending = make(map[string]bool)
+		case changes <- batch:
+			batch = nil
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			select {
+			case w.errors <- err:
+			default:
+			}
+		}
+	}
+}
+
+// handleEvent starts watching the new directories and returns the changed
+// file of an event, if it passes the filters
+func (w *Watcher) handleEvent(event fsnotify.Event) (string, bool) {
+	if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(w.root, event.Name)
+	if err != nil {
+		return "", false
+	}
+
+	// Start watching newly created directories
+	if event.Op&fsnotify.Create != 0 {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if w.isIgnored(rel, true) || w.isIgnoredByGit(rel) {
+				w.ignored[rel] = true
+				return "", false
+			}
+			if err := w.addTree(event.Name); err != nil {
+				select {
+				case w.errors <- fmt.Errorf("failed to watch %s: %w", event.Name, err):
+				default:
+				}
+			}
+			return "", false
+		}
+	}
+
+	if w.isIgnored(rel, false) || !w.matches(rel) {
+		return "", false
+	}
+	return rel, true
+}
+
+// batch returns the sorted changed files, leaving out the new files ignored
+// by git, or nil when there are none
+func (w *Watcher) batch(pending map[string]bool) []string {
+	files := make([]string, 0, len(pending))
+	for file := range pending {
+		files = append(files, file)
+	}
+	files = w.withoutGitIgnored(files)
+	if len(files) == 0 {
+		return nil
+	}
+	sort.Strings(files)
+	return files
+}
+
+// withoutGitIgnored filters out the files ignored by git with a single
+// git check-ignore call, as files created after the watcher started aren't
+// in the ignored set
+func (w *Watcher) withoutGitIgnored(files []string) []string {
+	if !w.useGit || len(files) == 0 {
+		return files
+	}
+	cmd := exec.Command("git", "check-ignore", "--stdin")
+	cmd.Dir = w.root
+	cmd.Stdin = strings.NewReader(strings.Join(files, "\n") + "\n")
+	// check-ignore exits with 1 when no file is ignored
+	output, _ := cmd.Output()
+
+	ignored := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		ignored[filepath.FromSlash(scanner.Text())] = true
+	}
+	kept := files[:0]
+	for _, file := range files {
+		if !ignored[file] {
+			kept = append(kept, file)
+		}
+	}
+	return kept
+}
+
+// MatchGlob reports whether a slash separated path matches the pattern. Besides
+// the usual path.Match syntax, "**" matches any number of path segments, and a
+// pattern without a slash matches the file name in any directory.
+func MatchGlob(pattern, path string) bool {
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		ok, err := filepath.Match(pattern[0], segments[0])
+		if err != nil || !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
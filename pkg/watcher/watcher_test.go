@@ -0,0 +1,190 @@
+package watcher
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMatchGlob(t *testing.T) {
+	for name, tc := range map[string]struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		"extension in root": {
+			pattern:  "*.go",
+			path:     "main.go",
+			expected: true,
+		},
+		"extension in subdirectory": {
+			pattern:  "*.go",
+			path:     "channels/app/app.go",
+			expected: true,
+		},
+		"double star prefix": {
+			pattern:  "**/*_test.go",
+			path:     "channels/app/app_test.go",
+			expected: true,
+		},
+		"double star prefix matches root": {
+			pattern:  "**/*_test.go",
+			path:     "main_test.go",
+			expected: true,
+		},
+		"double star does not match other extension": {
+			pattern:  "**/*.go",
+			path:     "channels/app/app.tmpl",
+			expected: false,
+		},
+		"anchored directory": {
+			pattern:  "channels/store/**",
+			path:     "channels/store/sqlstore/post_store.go",
+			expected: true,
+		},
+		"anchored directory mismatch": {
+			pattern:  "channels/store/**",
+			path:     "channels/app/post.go",
+			expected: false,
+		},
+		"double star in the middle": {
+			pattern:  "channels/**/mocks/*.go",
+			path:     "channels/store/storetest/mocks/Store.go",
+			expected: true,
+		},
+		"anchored pattern is not matched in subdirectories": {
+			pattern:  "cmd/*.go",
+			path:     "channels/cmd/main.go",
+			expected: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := MatchGlob(tc.pattern, tc.path); got != tc.expected {
+				t.Logf("expected MatchGlob(%q, %q) to be %v, got %v", tc.pattern, tc.path, tc.expected, got)
+				t.Fail()
+			}
+		})
+	}
+}
+
+const testDebounce = 100 * time.Millisecond
+
+// testWatcher watches a new git checkout with the given files
+func testWatcher(t *testing.T, files map[string]string) (string, *Watcher) {
+	root := t.TempDir()
+	cmd := exec.Command("git", "init", "-q")
+	cmd.Dir = root
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v: %s", err, out)
+	}
+	for name, content := range files {
+		writeFile(t, root, name, content)
+	}
+
+	w, err := New(root, Options{Include: []string{"**/*.go"}, Debounce: testDebounce})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return root, w
+}
+
+func writeFile(t *testing.T, root, name, content string) {
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectBatch waits for the next batch of changes
+func expectBatch(t *testing.T, w *Watcher, expected ...string) {
+	t.Helper()
+	select {
+	case files := <-w.Changes():
+		if !reflect.DeepEqual(files, expected) {
+			t.Logf("expected changes %q, got %q", expected, files)
+			t.Fail()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected changes %q, got none", expected)
+	}
+}
+
+// expectNoBatch checks no batch of changes arrives
+func expectNoBatch(t *testing.T, w *Watcher) {
+	t.Helper()
+	select {
+	case files := <-w.Changes():
+		t.Logf("expected no changes, got %q", files)
+		t.Fail()
+	case <-time.After(5 * testDebounce):
+	}
+}
+
+func TestWatcherGitIgnore(t *testing.T) {
+	root, w := testWatcher(t, map[string]string{
+		".gitignore":     "build/\n*_gen.go\n",
+		"app/app.go":     "package app\n",
+		"build/build.go": "package build\n",
+		"old_gen.go":     "package main\n",
+	})
+
+	writeFile(t, root, "build/build.go", "package build\n\nvar x int\n")
+	writeFile(t, root, "old_gen.go", "package main\n\nvar x int\n")
+	writeFile(t, root, "new_gen.go", "package main\n")
+	writeFile(t, root, "app/README.md", "not go\n")
+	expectNoBatch(t, w)
+
+	writeFile(t, root, "app/app.go", "package app\n\nvar x int\n")
+	writeFile(t, root, "another_gen.go", "package main\n")
+	expectBatch(t, w, filepath.Join("app", "app.go"))
+}
+
+func TestWatcherNewDirectories(t *testing.T) {
+	root, w := testWatcher(t, map[string]string{
+		".gitignore": "build/\n",
+	})
+
+	for _, dir := range []string{"feature/store", "build/out"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Give the watcher time to add the new directories
+	time.Sleep(testDebounce)
+
+	writeFile(t, root, "build/out/out.go", "package out\n")
+	writeFile(t, root, "feature/store/store.go", "package store\n")
+	expectBatch(t, w, filepath.Join("feature", "store", "store.go"))
+}
+
+func TestWatcherDebounce(t *testing.T) {
+	root, w := testWatcher(t, map[string]string{
+		"a.go": "package main\n",
+		"b.go": "package main\n",
+		"c.go": "package main\n",
+	})
+
+	writeFile(t, root, "b.go", "package main\n\nvar b int\n")
+	writeFile(t, root, "a.go", "package main\n\nvar a int\n")
+	writeFile(t, root, "b.go", "package main\n\nvar b, bb int\n")
+	expectBatch(t, w, "a.go", "b.go")
+
+	// Batches made while the previous one isn't received wait for it, the
+	// following ones joining together
+	writeFile(t, root, "c.go", "package main\n\nvar c int\n")
+	time.Sleep(3 * testDebounce)
+	writeFile(t, root, "a.go", "package main\n\nvar a, aa int\n")
+	time.Sleep(3 * testDebounce)
+	writeFile(t, root, "b.go", "package main\n\nvar b, bbb int\n")
+	time.Sleep(3 * testDebounce)
+	expectBatch(t, w, "c.go")
+	expectBatch(t, w, "a.go", "b.go")
+	expectNoBatch(t, w)
+}
@@ -0,0 +1,279 @@
+package webapp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jespino/mmdev/pkg/gitchanges"
+)
+
+// installStampFile records, inside node_modules, the hashes of the inputs of
+// the last successful install, per workspace. Removing node_modules removes
+// it too.
+const installStampFile = ".mmdev-install.json"
+
+// projectStamp is the key of the stamp of the whole project
+const projectStamp = "."
+
+// SetForceInstall makes the next dependency install run even if the lockfile
+// didn't change
+func (m *Manager) SetForceInstall(force bool) {
+	m.forceInstall = force
+}
+
+// installDependencies installs the dependencies writing the npm output to
+// out. The install is skipped when the lockfile, the package.json files of
+// the project and of the selected workspace, or all of them when none is,
+// and the Node version are the same as in the last install. npm ci is used
+// when the lockfile is the source of truth, that is, none of them has local
+// changes.
+func (m *Manager) installDependencies(out io.Writer) error {
+	rt, err := m.runtime()
+	if err != nil {
+		return err
+	}
+
+	stampPath := filepath.Join(m.baseDir, "node_modules", installStampFile)
+	if !m.forceInstall {
+		key := m.workspace
+		if key == "" {
+			key = projectStamp
+		}
+		hash, err := m.dependenciesHash(rt.Version, m.workspace)
+		if err != nil {
+			return err
+		}
+		if readStamps(stampPath)[key] == hash {
+			fmt.Fprintln(out, "Dependencies are up to date, skipping npm install")
+			return nil
+		}
+	}
+
+	args := []string{"install"}
+	if m.lockfileIsSourceOfTruth() {
+		args = []string{"ci"}
+	}
+	fmt.Fprintf(out, "Running npm %s...\n", args[0])
+
+	cmd := rt.Command(m.baseDir, "npm", args...)
+	cmd.Stdout = out
+	cmd.Stderr = m.stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("npm %s failed: %w", args[0], err)
+	}
+
+	// Hashed once installed, as npm install may rewrite the lockfile. The
+	// install covers the whole project, so every workspace is up to date.
+	stamps := make(map[string]string)
+	workspaces, err := m.Workspaces()
+	if err != nil {
+		return err
+	}
+	for _, workspace := range append([]string{""}, workspaces...) {
+		hash, err := m.dependenciesHash(rt.Version, workspace)
+		if err != nil {
+			return err
+		}
+		if workspace == "" {
+			workspace = projectStamp
+		}
+		stamps[workspace] = hash
+	}
+	data, err := json.MarshalIndent(stamps, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode install stamp: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(stampPath), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(stampPath), err)
+	}
+	if err := os.WriteFile(stampPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write install stamp: %w", err)
+	}
+	return nil
+}
+
+// readStamps returns the hashes of the last install by workspace, none when
+// there is no valid stamp
+func readStamps(path string) map[string]string {
+	stamps := make(map[string]string)
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &stamps)
+	}
+	return stamps
+}
+
+// dependenciesHash hashes the inputs of the install of a workspace, or of
+// the whole project when empty: the lockfile, the package.json files of the
+// project and of the workspace, or of all of them, and the Node version
+func (m *Manager) dependenciesHash(nodeVersion, workspace string) (string, error) {
+	files := []string{"package-lock.json", "package.json"}
+	if workspace != "" {
+		files = append(files, filepath.Join(workspace, "package.json"))
+	} else {
+		workspaces, err := m.Workspaces()
+		if err != nil {
+			return "", err
+		}
+		for _, workspace := range workspaces {
+			files = append(files, filepath.Join(workspace, "package.json"))
+		}
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "node %s\n", nodeVersion)
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(m.baseDir, file))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		fmt.Fprintf(h, "%s %d\n", filepath.ToSlash(file), len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// lockfileIsSourceOfTruth checks if there is a lockfile and neither it nor
+// any package.json has local changes, so the install must match it exactly
+func (m *Manager) lockfileIsSourceOfTruth() bool {
+	if _, err := os.Stat(filepath.Join(m.baseDir, "package-lock.json")); err != nil {
+		return false
+	}
+
+	changed, err := gitchanges.ChangedFiles(m.baseDir)
+	if err != nil {
+		return false
+	}
+	for _, file := range changed {
+		if base := filepath.Base(file); base == "package.json" || base == "package-lock.json" {
+			return false
+		}
+	}
+	return true
+}
+
+// SetWorkspace limits the npm scripts to a single workspace, given by its
+// directory, the last element of it or its package name
+func (m *Manager) SetWorkspace(name string) error {
+	if name == "" {
+		m.workspace = ""
+		return nil
+	}
+
+	workspaces, err := m.Workspaces()
+	if err != nil {
+		return err
+	}
+	for _, workspace := range workspaces {
+		if name == workspace || name == path.Base(workspace) || name == m.packageName(workspace) {
+			m.workspace = workspace
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown workspace %q, available: %s", name, strings.Join(workspaces, ", "))
+}
+
+// Workspace returns the selected workspace, empty for the whole project
+func (m *Manager) Workspace() string {
+	return m.workspace
+}
+
+// WorkspaceOf returns the workspace containing a file relative to the webapp
+// directory
+func (m *Manager) WorkspaceOf(file string) (string, bool) {
+	workspaces, err := m.Workspaces()
+	if err != nil {
+		return "", false
+	}
+	file = filepath.ToSlash(file)
+	best := ""
+	for _, workspace := range workspaces {
+		if strings.HasPrefix(file, workspace+"/") && len(workspace) > len(best) {
+			best = workspace
+		}
+	}
+	return best, best != ""
+}
+
+// workspaceArgs adds the selected workspace, if any, to npm arguments
+func (m *Manager) workspaceArgs(args ...string) []string {
+	if m.workspace == "" {
+		return args
+	}
+	return append(args, "--workspace", m.workspace)
+}
+
+func (m *Manager) packageName(workspace string) string {
+	data, err := os.ReadFile(filepath.Join(m.baseDir, workspace, "package.json"))
+	if err != nil {
+		return ""
+	}
+	var pkg struct {
+		Name string `json:"name"`
+	}
+	if json.Unmarshal(data, &pkg) != nil {
+		return ""
+	}
+	return pkg.Name
+}
+
+// Workspaces returns the npm workspaces of the project, as directories
+// relative to the webapp directory
+func (m *Manager) Workspaces() ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(m.baseDir, "package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+
+	var pkg struct {
+		Workspaces json.RawMessage `json:"workspaces"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse package.json: %w", err)
+	}
+	if len(pkg.Workspaces) == 0 {
+		return nil, nil
+	}
+
+	// Workspaces are either a list of patterns or an object with a packages
+	// list, the yarn style
+	var patterns []string
+	if err := json.Unmarshal(pkg.Workspaces, &patterns); err != nil {
+		var object struct {
+			Packages []string `json:"packages"`
+		}
+		if err := json.Unmarshal(pkg.Workspaces, &object); err != nil {
+			return nil, fmt.Errorf("failed to parse package.json workspaces: %w", err)
+		}
+		patterns = object.Packages
+	}
+
+	var workspaces []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(m.baseDir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace pattern %q: %w", pattern, err)
+		}
+		for _, match := range matches {
+			if _, err := os.Stat(filepath.Join(match, "package.json")); err != nil {
+				continue
+			}
+			rel, err := filepath.Rel(m.baseDir, match)
+			if err != nil {
+				return nil, err
+			}
+			workspaces = append(workspaces, filepath.ToSlash(rel))
+		}
+	}
+	sort.Strings(workspaces)
+	return workspaces, nil
+}
@@ -0,0 +1,145 @@
+package webapp
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jespino/mmdev/pkg/node"
+)
+
+// fakeNpm makes the manager run a fake npm logging its arguments. With
+// NPM_REWRITE set it rewrites the lockfile, like npm install does.
+func fakeNpm(t *testing.T, m *Manager) func() []string {
+	binDir := t.TempDir()
+	log := filepath.Join(t.TempDir(), "npm.log")
+	script := "#!/bin/sh\n" +
+		"echo \"$*\" >> " + log + "\n" +
+		"mkdir -p node_modules\n" +
+		"if [ -n \"$NPM_REWRITE\" ]; then echo '{\"lockfileVersion\": 3}' > package-lock.json; fi\n"
+	if err := os.WriteFile(filepath.Join(binDir, "npm"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	m.node = &node.Runtime{Version: "v20.11.0", BinDir: binDir, Source: "test"}
+	m.SetOutput(io.Discard)
+
+	return func() []string {
+		data, err := os.ReadFile(log)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(log)
+		return strings.Fields(string(data))
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func git(t *testing.T, dir string, args ...string) {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@test", "-c", "commit.gpgsign=false"}, args...)...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v: %s", args, err, out)
+	}
+}
+
+func TestInstallDependencies(t *testing.T) {
+	for name, tc := range map[string]struct {
+		// steps change the project and select a workspace before an install,
+		// expecting the npm command run, or none when skipped
+		steps []func(t *testing.T, dir string, m *Manager)
+		npm   []string
+	}{
+		"skipped when unchanged": {
+			steps: []func(*testing.T, string, *Manager){nil, nil},
+			npm:   []string{"install", ""},
+		},
+		"skipped after npm rewrote the lockfile": {
+			steps: []func(*testing.T, string, *Manager){
+				func(t *testing.T, dir string, m *Manager) {
+					t.Setenv("NPM_REWRITE", "1")
+				},
+				nil,
+			},
+			npm: []string{"install", ""},
+		},
+		"installed when the lockfile changes": {
+			steps: []func(*testing.T, string, *Manager){
+				nil,
+				func(t *testing.T, dir string, m *Manager) {
+					writeFile(t, dir, "package-lock.json", `{"lockfileVersion": 3, "packages": {}}`)
+				},
+			},
+			npm: []string{"install", "install"},
+		},
+		"installed when forced": {
+			steps: []func(*testing.T, string, *Manager){
+				nil,
+				func(t *testing.T, dir string, m *Manager) {
+					m.SetForceInstall(true)
+				},
+			},
+			npm: []string{"install", "install"},
+		},
+		"workspace unaffected by another one": {
+			steps: []func(*testing.T, string, *Manager){
+				nil,
+				func(t *testing.T, dir string, m *Manager) {
+					writeFile(t, dir, "platform/types/package.json", `{"name": "@mattermost/types", "version": "2.0.0"}`)
+					if err := m.SetWorkspace("channels"); err != nil {
+						t.Fatal(err)
+					}
+				},
+				func(t *testing.T, dir string, m *Manager) {
+					m.SetWorkspace("")
+				},
+			},
+			npm: []string{"install", "", "install"},
+		},
+		"ci when the lockfile is committed": {
+			steps: []func(*testing.T, string, *Manager){
+				func(t *testing.T, dir string, m *Manager) {
+					git(t, dir, "init", "-q")
+					git(t, dir, "add", ".")
+					git(t, dir, "commit", "-q", "-m", "initial")
+				},
+				func(t *testing.T, dir string, m *Manager) {
+					writeFile(t, dir, "channels/package.json", `{"name": "mattermost-webapp", "dependencies": {"react": "18"}}`)
+				},
+			},
+			npm: []string{"ci", "install"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := testWebapp(t)
+			writeFile(t, dir, "package-lock.json", `{"lockfileVersion": 3}`+"\n")
+			writeFile(t, dir, ".gitignore", "node_modules\n")
+			m := NewManager(dir)
+			npmCalls := fakeNpm(t, m)
+
+			var got []string
+			for _, step := range tc.steps {
+				if step != nil {
+					step(t, dir, m)
+				}
+				if err := m.installDependencies(io.Discard); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, strings.Join(npmCalls(), " "))
+			}
+			if strings.Join(got, "|") != strings.Join(tc.npm, "|") {
+				t.Logf("expected npm runs %q, got %q", tc.npm, got)
+				t.Fail()
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package webapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// serverPaths are the path prefixes served by the Mattermost server instead
+// of the webpack dev server
+var serverPaths = []string{
+	"/api/",
+	"/plugins/",
+	"/static/plugins/",
+	"/oauth/",
+	"/login/sso/",
+	"/signup/gitlab/",
+	"/signup/office365/",
+	"/hooks/",
+	"/.well-known/",
+}
+
+// DevServerOptions configures the webpack dev server and the proxy in front
+// of it
+type DevServerOptions struct {
+	// Port is where the proxy listens, the URL to open in the browser
+	Port int
+	// DevServerPort is where the webpack dev server listens
+	DevServerPort int
+	// ServerURL is the URL of the Mattermost server
+	ServerURL string
+}
+
+// DevServer runs the webpack dev server with hot reload behind a reverse
+// proxy, which forwards the API, websocket and plugin traffic to the
+// Mattermost server. It runs until interrupted or the dev server exits.
+func (m *Manager) DevServer(opts DevServerOptions) error {
+	if err := m.validateBaseDir(); err != nil {
+		return err
+	}
+
+	serverURL, err := url.Parse(opts.ServerURL)
+	if err != nil {
+		return fmt.Errorf("invalid server URL %q: %w", opts.ServerURL, err)
+	}
+	devServerURL := &url.URL{Scheme: "http", Host: fmt.Sprintf("localhost:%d", opts.DevServerPort)}
+
+	// Install dependencies if needed
+	if err := m.ensureDependencies(); err != nil {
+		return fmt.Errorf("failed to ensure dependencies: %w", err)
+	}
+
+	cmd, err := m.command("npm", "run", "dev-server", "--workspace", m.appWorkspace(), "--",
+		"--port", fmt.Sprint(opts.DevServerPort))
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start webpack dev server: %w", err)
+	}
+
+	proxy := &http.Server{
+		Addr:    fmt.Sprintf(":%d", opts.Port),
+		Handler: newDevProxy(serverURL, devServerURL),
+	}
+	proxyErr := make(chan error, 1)
+	go func() {
+		proxyErr <- proxy.ListenAndServe()
+	}()
+	fmt.Printf("Webapp available at http://localhost:%d (server at %s)\n", opts.Port, serverURL)
+
+	devServerDone := make(chan error, 1)
+	go func() {
+		devServerDone <- cmd.Wait()
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	var runErr error
+	select {
+	case <-sigChan:
+		fmt.Println("\nReceived interrupt signal. Shutting down...")
+		stopProcess(cmd, devServerDone)
+	case err := <-proxyErr:
+		runErr = fmt.Errorf("proxy failed: %w", err)
+		stopProcess(cmd, devServerDone)
+	case err := <-devServerDone:
+		if err != nil {
+			runErr = fmt.Errorf("webpack dev server failed: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := proxy.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("Warning: failed to stop proxy: %v\n", err)
+	}
+	return runErr
+}
+
+// newDevProxy creates the handler sending the server paths to the server and
+// everything else, including the hot reload websocket, to the dev server
+func newDevProxy(serverURL, devServerURL *url.URL) http.Handler {
+	toServer := httputil.NewSingleHostReverseProxy(serverURL)
+	director := toServer.Director
+	toServer.Director = func(req *http.Request) {
+		director(req)
+		// The server checks the websocket origin against its site URL
+		req.Host = serverURL.Host
+		if req.Header.Get("Origin") != "" {
+			req.Header.Set("Origin", serverURL.Scheme+"://"+serverURL.Host)
+		}
+	}
+
+	toDevServer := httputil.NewSingleHostReverseProxy(devServerURL)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isServerPath(r.URL.Path) {
+			toServer.ServeHTTP(w, r)
+			return
+		}
+		toDevServer.ServeHTTP(w, r)
+	})
+}
+
+func isServerPath(path string) bool {
+	for _, prefix := range serverPaths {
+		if strings.HasPrefix(path, prefix) || path == strings.TrimSuffix(prefix, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// stopProcess terminates a process, killing it if it doesn't exit in time
+func stopProcess(cmd *exec.Cmd, done <-chan error) {
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		cmd.Process.Kill()
+		return
+	}
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,59 @@
+package webapp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestDevProxy(t *testing.T) {
+	backend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name+" "+r.Header.Get("Origin"))
+		}))
+	}
+	server := backend("server")
+	defer server.Close()
+	devServer := backend("dev-server")
+	defer devServer.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	devServerURL, _ := url.Parse(devServer.URL)
+	proxy := httptest.NewServer(newDevProxy(serverURL, devServerURL))
+	defer proxy.Close()
+
+	for name, tc := range map[string]struct {
+		path     string
+		origin   string
+		expected string
+	}{
+		"index":          {path: "/", expected: "dev-server "},
+		"bundle":         {path: "/static/main.js", expected: "dev-server "},
+		"hot reload":     {path: "/ws", expected: "dev-server "},
+		"api":            {path: "/api/v4/users/me", expected: "server "},
+		"plugin assets":  {path: "/static/plugins/com.mattermost.calls/main.js", expected: "server "},
+		"origin rewrite": {path: "/api/v4/websocket", origin: proxy.URL, expected: "server " + server.URL},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, proxy.URL+tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tc.expected {
+				t.Logf("expected: %q, got %q", tc.expected, body)
+				t.Fail()
+			}
+		})
+	}
+}
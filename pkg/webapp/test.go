@@ -0,0 +1,158 @@
+package webapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TestOptions configures a jest run
+type TestOptions struct {
+	// Paths are files or directories, relative to the webapp directory, whose
+	// tests are run
+	Paths []string
+	// Related are source files, relative to the webapp directory, whose related
+	// tests are run
+	Related []string
+	// NamePattern only runs the tests with a matching name
+	NamePattern string
+	// Watch keeps jest running, re-running the tests on changes
+	Watch bool
+}
+
+// Test runs the jest tests of the workspaces, or of the selected workspace,
+// through their test script
+func (m *Manager) Test(opts TestOptions) error {
+	if err := m.validateBaseDir(); err != nil {
+		return err
+	}
+
+	runs, err := m.testRuns(opts)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		return fmt.Errorf("no workspace with a test script to run")
+	}
+
+	if err := m.ensureDependencies(); err != nil {
+		return fmt.Errorf("failed to ensure dependencies: %w", err)
+	}
+
+	workspaces := make([]string, 0, len(runs))
+	for workspace := range runs {
+		workspaces = append(workspaces, workspace)
+	}
+	sort.Strings(workspaces)
+
+	var failed []string
+	for _, workspace := range workspaces {
+		args := []string{"run", "test", "--workspace", workspace, "--"}
+		if opts.Watch {
+			args = append(args, "--watch")
+		}
+		if opts.NamePattern != "" {
+			args = append(args, "--testNamePattern", opts.NamePattern)
+		}
+		args = append(args, runs[workspace]...)
+
+		fmt.Printf("Running %s tests...\n", workspace)
+		cmd, err := m.command("npm", args...)
+		if err != nil {
+			return err
+		}
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			failed = append(failed, workspace)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("tests failed in %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// testRuns maps each workspace to test to the jest arguments selecting its
+// tests
+func (m *Manager) testRuns(opts TestOptions) (map[string][]string, error) {
+	runs := make(map[string][]string)
+
+	// add groups a file into its workspace, with its path relative to it
+	add := func(file string, related bool) error {
+		file = filepath.ToSlash(filepath.Clean(file))
+		workspace, ok := m.WorkspaceOf(file)
+		if !ok {
+			if m.hasTestScript(file) && (m.workspace == "" || file == m.workspace) {
+				// The path is a whole workspace, run all its tests
+				if _, ok := runs[file]; !ok {
+					runs[file] = nil
+				}
+				return nil
+			}
+			if related {
+				return nil
+			}
+			return fmt.Errorf("%s is not inside a workspace", file)
+		}
+		if m.workspace != "" && workspace != m.workspace {
+			return nil
+		}
+		rel := strings.TrimPrefix(file, workspace+"/")
+		if related && len(runs[workspace]) == 0 {
+			runs[workspace] = []string{"--findRelatedTests"}
+		}
+		runs[workspace] = append(runs[workspace], rel)
+		return nil
+	}
+
+	switch {
+	case len(opts.Related) > 0:
+		for _, file := range opts.Related {
+			if err := add(file, true); err != nil {
+				return nil, err
+			}
+		}
+	case len(opts.Paths) > 0:
+		for _, path := range opts.Paths {
+			if err := add(path, false); err != nil {
+				return nil, err
+			}
+		}
+	case m.workspace != "":
+		runs[m.workspace] = nil
+	default:
+		workspaces, err := m.Workspaces()
+		if err != nil {
+			return nil, err
+		}
+		for _, workspace := range workspaces {
+			if m.hasTestScript(workspace) {
+				runs[workspace] = nil
+			}
+		}
+	}
+
+	return runs, nil
+}
+
+// hasTestScript checks if a workspace defines a test script
+func (m *Manager) hasTestScript(workspace string) bool {
+	data, err := os.ReadFile(filepath.Join(m.baseDir, workspace, "package.json"))
+	if err != nil {
+		return false
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if json.Unmarshal(data, &pkg) != nil {
+		return false
+	}
+	_, ok := pkg.Scripts["test"]
+	return ok
+}
@@ -2,32 +2,60 @@ package webapp
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+
+	"github.com/jespino/mmdev/pkg/lintreport"
+	"github.com/jespino/mmdev/pkg/node"
 )
 
 // Manager handles webapp operations
 type Manager struct {
-	baseDir string
+	baseDir      string
+	node         *node.Runtime
+	forceInstall bool
+	workspace    string
+	stdout       io.Writer
+	stderr       io.Writer
 }
 
 // NewManager creates a new webapp manager
 func NewManager(baseDir string) *Manager {
 	return &Manager{
 		baseDir: baseDir,
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
 	}
 }
 
-// Start starts the webapp development server
+// SetOutput sets where the output of Start and Run, the dependency install
+// included, is written, stdout and stderr by default
+func (m *Manager) SetOutput(w io.Writer) {
+	m.stdout = w
+	m.stderr = w
+}
+
+// Start starts the webapp development server and waits for it to exit
 func (m *Manager) Start(watch bool) error {
-	if err := m.validateBaseDir(); err != nil {
+	cmd, err := m.Run(watch)
+	if err != nil {
 		return err
 	}
+	return cmd.Wait()
+}
+
+// Run installs the dependencies if needed and starts the build, watching for
+// changes when watch is set, returning the command
+func (m *Manager) Run(watch bool) (*exec.Cmd, error) {
+	if err := m.validateBaseDir(); err != nil {
+		return nil, err
+	}
 
 	// Install dependencies if needed
 	if err := m.ensureDependencies(); err != nil {
-		return fmt.Errorf("failed to ensure dependencies: %w", err)
+		return nil, fmt.Errorf("failed to ensure dependencies: %w", err)
 	}
 
 	// Start the development server or build
@@ -35,17 +63,46 @@ func (m *Manager) Start(watch bool) error {
 	if watch {
 		npmCmd = "run"
 	}
-	cmd := exec.Command("bash", "-c", "source ~/.nvm/nvm.sh && nvm use && npm run "+npmCmd)
-	cmd.Dir = m.baseDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Env = os.Environ()
+	cmd, err := m.command("npm", m.workspaceArgs("run", npmCmd)...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = m.stdout
+	cmd.Stderr = m.stderr
 
-	return cmd.Run()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start npm: %w", err)
+	}
+	return cmd, nil
 }
 
-// Lint runs ESLint on the webapp code
+// Build builds the webapp
+func (m *Manager) Build() error {
+	return m.Start(false)
+}
+
+// DistDir returns the build output directory of the app workspace
+func (m *Manager) DistDir() string {
+	return filepath.Join(m.baseDir, m.appWorkspace(), "dist")
+}
+
+// appWorkspace returns the selected workspace, or channels, the webapp
+// itself, when none is selected
+func (m *Manager) appWorkspace() string {
+	if m.workspace == "" {
+		return "channels"
+	}
+	return m.workspace
+}
+
+// Lint runs ESLint on the selected workspace, or the whole webapp
 func (m *Manager) Lint() error {
+	return m.LintFiles(nil)
+}
+
+// LintFiles runs ESLint on the given files, relative to the webapp directory,
+// or like Lint when none are given
+func (m *Manager) LintFiles(files []string) error {
 	if err := m.validateBaseDir(); err != nil {
 		return err
 	}
@@ -55,12 +112,12 @@ func (m *Manager) Lint() error {
 		return fmt.Errorf("failed to ensure dependencies: %w", err)
 	}
 
-	// Run ESLint once
-	cmd := exec.Command("bash", "-c", "source ~/.nvm/nvm.sh && nvm use && npm check --no-cache")
-	cmd.Dir = m.baseDir
+	cmd, err := m.command("npx", append([]string{"eslint"}, m.lintTargets(files)...)...)
+	if err != nil {
+		return err
+	}
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	cmd.Env = os.Environ()
 
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("eslint check failed: %w", err)
@@ -68,6 +125,59 @@ func (m *Manager) Lint() error {
 	return nil
 }
 
+// LintReport runs ESLint like LintFiles and returns the issues found, with
+// file paths relative to the current directory
+func (m *Manager) LintReport(files []string) ([]lintreport.Issue, error) {
+	if err := m.validateBaseDir(); err != nil {
+		return nil, err
+	}
+
+	// Install dependencies if needed, keeping stdout clean for the report
+	if err := m.installDependencies(os.Stderr); err != nil {
+		return nil, fmt.Errorf("failed to ensure dependencies: %w", err)
+	}
+
+	cmd, err := m.command("npx", append([]string{"eslint", "--format", "json"}, m.lintTargets(files)...)...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = os.Stderr
+
+	// ESLint exits with an error when issues are found, so only fail if the
+	// output can't be parsed
+	output, runErr := cmd.Output()
+	issues, err := lintreport.ParseESLint(output)
+	if err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("eslint check failed: %w", runErr)
+		}
+		return nil, err
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current directory: %w", err)
+	}
+	for i := range issues {
+		if rel, err := filepath.Rel(cwd, issues[i].File); err == nil {
+			issues[i].File = rel
+		}
+	}
+	return issues, nil
+}
+
+// lintTargets returns the files to lint, or the selected workspace or the
+// whole webapp when none are given
+func (m *Manager) lintTargets(files []string) []string {
+	if len(files) > 0 {
+		return files
+	}
+	if m.workspace != "" {
+		return []string{m.workspace}
+	}
+	return []string{"."}
+}
+
 // Fix runs ESLint fix on the webapp code
 func (m *Manager) Fix() error {
 	if err := m.validateBaseDir(); err != nil {
@@ -80,11 +190,12 @@ func (m *Manager) Fix() error {
 	}
 
 	// Run ESLint fix
-	cmd := exec.Command("bash", "-c", "source ~/.nvm/nvm.sh && nvm use && npm run fix")
-	cmd.Dir = m.baseDir
+	cmd, err := m.command("npm", m.workspaceArgs("run", "fix")...)
+	if err != nil {
+		return err
+	}
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	cmd.Env = os.Environ()
 
 	return cmd.Run()
 }
@@ -98,12 +209,29 @@ func (m *Manager) validateBaseDir() error {
 }
 
 func (m *Manager) ensureDependencies() error {
-	// Install dependencies
-	cmd := exec.Command("bash", "-c", "source ~/.nvm/nvm.sh && nvm use && npm install")
-	cmd.Dir = m.baseDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Env = os.Environ()
+	return m.installDependencies(m.stdout)
+}
 
-	return cmd.Run()
+// command creates a command running a binary of the Node runtime of the
+// webapp
+func (m *Manager) command(name string, args ...string) (*exec.Cmd, error) {
+	rt, err := m.runtime()
+	if err != nil {
+		return nil, err
+	}
+	return rt.Command(m.baseDir, name, args...), nil
+}
+
+// runtime returns the Node runtime of the webapp, resolved from its .nvmrc on
+// first use
+func (m *Manager) runtime() (*node.Runtime, error) {
+	if m.node == nil {
+		rt, err := node.Resolve(m.baseDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find node: %w", err)
+		}
+		fmt.Fprintf(m.stderr, "Using %s\n", rt)
+		m.node = rt
+	}
+	return m.node, nil
 }
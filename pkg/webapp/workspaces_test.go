@@ -0,0 +1,114 @@
+package webapp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func testWebapp(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"package.json":                 `{"workspaces": ["channels", "platform/*"]}`,
+		"channels/package.json":        `{"name": "mattermost-webapp", "scripts": {"test": "jest"}}`,
+		"platform/client/package.json": `{"name": "@mattermost/client", "scripts": {"test": "jest"}}`,
+		"platform/types/package.json":  `{"name": "@mattermost/types"}`,
+		"platform/README.md":           "",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestSetWorkspace(t *testing.T) {
+	m := NewManager(testWebapp(t))
+
+	for name, tc := range map[string]struct {
+		workspace string
+		expected  string
+	}{
+		"directory":    {workspace: "platform/client", expected: "platform/client"},
+		"last element": {workspace: "channels", expected: "channels"},
+		"package name": {workspace: "@mattermost/types", expected: "platform/types"},
+		"unknown":      {workspace: "boards", expected: "error"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var got string
+			if err := m.SetWorkspace(tc.workspace); err != nil {
+				got = "error"
+			} else {
+				got = m.Workspace()
+			}
+			if got != tc.expected {
+				t.Logf("expected: %v, got %v", tc.expected, got)
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestTestRuns(t *testing.T) {
+	dir := testWebapp(t)
+
+	for name, tc := range map[string]struct {
+		workspace string
+		opts      TestOptions
+		expected  string
+	}{
+		"all workspaces with tests": {
+			expected: "channels: | platform/client:",
+		},
+		"selected workspace": {
+			workspace: "client",
+			expected:  "platform/client:",
+		},
+		"paths": {
+			opts:     TestOptions{Paths: []string{"channels/src/components/post", "platform/client/src/client4.ts"}},
+			expected: "channels: src/components/post | platform/client: src/client4.ts",
+		},
+		"whole workspace path": {
+			opts:     TestOptions{Paths: []string{"platform/client/"}},
+			expected: "platform/client:",
+		},
+		"related files": {
+			opts:     TestOptions{Related: []string{"channels/src/a.ts", "channels/src/b.tsx", "e2e/c.ts"}},
+			expected: "channels: --findRelatedTests src/a.ts src/b.tsx",
+		},
+		"related files outside the selected workspace": {
+			workspace: "channels",
+			opts:      TestOptions{Related: []string{"platform/client/src/client4.ts"}},
+			expected:  "",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := NewManager(dir)
+			if err := m.SetWorkspace(tc.workspace); err != nil {
+				t.Fatal(err)
+			}
+
+			runs, err := m.testRuns(tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for workspace, args := range runs {
+				got = append(got, strings.TrimSpace(fmt.Sprintf("%s: %s", workspace, strings.Join(args, " "))))
+			}
+			sort.Strings(got)
+			if strings.Join(got, " | ") != tc.expected {
+				t.Logf("expected: %v, got %v", tc.expected, strings.Join(got, " | "))
+				t.Fail()
+			}
+		})
+	}
+}